@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"bytebros.ti/database"
+	"bytebros.ti/handlers"
+)
+
+// executarComando trata os subcomandos de linha de comando (ex.: `./server criar-superadmin`).
+// Retorna false quando nenhum subcomando foi informado e o servidor deve ser iniciado.
+func executarComando(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "criar-superadmin":
+		return true, criarSuperAdmin(args[1:])
+	default:
+		return true, fmt.Errorf("comando desconhecido: %s (disponíveis: criar-superadmin)", args[0])
+	}
+}
+
+func criarSuperAdmin(args []string) error {
+	fs := flag.NewFlagSet("criar-superadmin", flag.ContinueOnError)
+	nome := fs.String("nome", "", "nome do administrador")
+	email := fs.String("email", "", "email de acesso")
+	senha := fs.String("senha", os.Getenv("BOOTSTRAP_ADMIN_SENHA"), "senha de acesso (padrão: variável BOOTSTRAP_ADMIN_SENHA)")
+	forcar := fs.Bool("forcar", false, "cria o administrador mesmo que já exista um administrador superior ativo")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *nome == "" || *email == "" || *senha == "" {
+		return fmt.Errorf("uso: criar-superadmin -nome \"Nome\" -email admin@exemplo.com [-senha ...]")
+	}
+
+	existentes, err := handlers.ContarSuperAdminsAtivos(database.DB)
+	if err != nil {
+		return fmt.Errorf("erro ao verificar administradores existentes: %w", err)
+	}
+	if existentes > 0 && !*forcar {
+		return fmt.Errorf("já existe(m) %d administrador(es) superior(es) ativo(s); use -forcar para criar outro", existentes)
+	}
+
+	id, err := handlers.CriarSuperAdmin(database.DB, *nome, *email, *senha)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Administrador superior %s criado com ID %d\n", *email, id)
+	return nil
+}
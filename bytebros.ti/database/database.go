@@ -75,7 +75,10 @@ func CreateTables() error {
 				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 			);
-			CREATE INDEX IF NOT EXISTS idx_usuarios_email ON usuarios(email);`,
+			CREATE INDEX IF NOT EXISTS idx_usuarios_email ON usuarios(email);
+			ALTER TABLE usuarios ADD COLUMN IF NOT EXISTS anonimizado_em TIMESTAMP;
+			ALTER TABLE usuarios ADD COLUMN IF NOT EXISTS cpf VARCHAR(11);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_usuarios_cpf ON usuarios(cpf) WHERE cpf IS NOT NULL;`,
 		},
 		{
 			name: "funcionarios",
@@ -89,7 +92,9 @@ func CreateTables() error {
 				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 			);
 			CREATE INDEX IF NOT EXISTS idx_funcionarios_email ON funcionarios(email);
-			CREATE INDEX IF NOT EXISTS idx_funcionarios_cargo ON funcionarios(cargo);`,
+			CREATE INDEX IF NOT EXISTS idx_funcionarios_cargo ON funcionarios(cargo);
+			ALTER TABLE funcionarios ADD COLUMN IF NOT EXISTS ativo BOOLEAN NOT NULL DEFAULT true;
+			ALTER TABLE funcionarios ADD COLUMN IF NOT EXISTS atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP;`,
 		},
 		{
 			name: "produtos",
@@ -104,7 +109,10 @@ func CreateTables() error {
                 imagem VARCHAR(255) -- NOVO CAMPO (URL da imagem)
 			);
 			CREATE INDEX IF NOT EXISTS idx_produtos_oferta ON produtos(oferta);
-			CREATE INDEX IF NOT EXISTS idx_produtos_nome ON produtos(nome);`,
+			CREATE INDEX IF NOT EXISTS idx_produtos_nome ON produtos(nome);
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+			CREATE INDEX IF NOT EXISTS idx_produtos_preco ON produtos(preco, id);
+			CREATE INDEX IF NOT EXISTS idx_produtos_criado_em ON produtos(criado_em, id);`,
 		},
 		{
 			name: "noticias",
@@ -148,7 +156,10 @@ func CreateTables() error {
    			CREATE INDEX IF NOT EXISTS idx_suporte_status ON suporte(status);
     		CREATE INDEX IF NOT EXISTS idx_suporte_email ON suporte(email);
     		CREATE INDEX IF NOT EXISTS idx_suporte_cliente_email ON suporte(cliente_email);
-    		CREATE INDEX IF NOT EXISTS idx_suporte_tipo_interacao ON suporte(tipo_interacao);`,
+    		CREATE INDEX IF NOT EXISTS idx_suporte_tipo_interacao ON suporte(tipo_interacao);
+    		ALTER TABLE suporte ADD COLUMN IF NOT EXISTS atendido_por INTEGER REFERENCES funcionarios(id) ON DELETE SET NULL;
+    		ALTER TABLE suporte ADD COLUMN IF NOT EXISTS atendido_em TIMESTAMP;
+    		CREATE INDEX IF NOT EXISTS idx_suporte_atendido_por ON suporte(atendido_por);`,
 		},
 
 		{
@@ -163,7 +174,8 @@ func CreateTables() error {
         	criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
         	atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     	);
-    	CREATE INDEX IF NOT EXISTS idx_administradores_email ON admin(email);`,
+    	CREATE INDEX IF NOT EXISTS idx_administradores_email ON admin(email);
+    	ALTER TABLE admin ADD COLUMN IF NOT EXISTS ativo BOOLEAN NOT NULL DEFAULT true;`,
 		},
 		{
 			name: "pedidos",
@@ -182,7 +194,8 @@ func CreateTables() error {
                 criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 			);
 			CREATE INDEX IF NOT EXISTS idx_pedidos_cliente_email ON pedidos(cliente_email);
-			CREATE INDEX IF NOT EXISTS idx_pedidos_status ON pedidos(status);`,
+			CREATE INDEX IF NOT EXISTS idx_pedidos_status ON pedidos(status);
+			ALTER TABLE pedidos ADD COLUMN IF NOT EXISTS endereco_id INTEGER;`,
 		},
 		{
 			name: "pedido_itens",
@@ -215,7 +228,642 @@ func CreateTables() error {
 			);
 			CREATE INDEX IF NOT EXISTS idx_orcamentos_email_cliente ON orcamentos(email_cliente);
 			CREATE INDEX IF NOT EXISTS idx_orcamentos_status ON orcamentos(status);
-			CREATE INDEX IF NOT EXISTS idx_orcamentos_criado_em ON orcamentos(criado_em);`,
+			CREATE INDEX IF NOT EXISTS idx_orcamentos_criado_em ON orcamentos(criado_em);
+			ALTER TABLE orcamentos ADD COLUMN IF NOT EXISTS processado_por INTEGER REFERENCES funcionarios(id) ON DELETE SET NULL;
+			ALTER TABLE orcamentos ADD COLUMN IF NOT EXISTS processado_em TIMESTAMP;
+			CREATE INDEX IF NOT EXISTS idx_orcamentos_processado_por ON orcamentos(processado_por);`,
+		},
+		{
+			name: "consentimentos",
+			query: `
+			CREATE TABLE IF NOT EXISTS consentimentos (
+				id SERIAL PRIMARY KEY,
+				usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+				email VARCHAR(100) NOT NULL,
+				finalidade VARCHAR(50) NOT NULL,
+				concedido BOOLEAN NOT NULL,
+				ip VARCHAR(45),
+				user_agent TEXT,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_consentimentos_usuario_id ON consentimentos(usuario_id);`,
+		},
+		{
+			name: "solicitacoes_lgpd",
+			query: `
+			CREATE TABLE IF NOT EXISTS solicitacoes_lgpd (
+				id SERIAL PRIMARY KEY,
+				usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+				email VARCHAR(100) NOT NULL,
+				tipo VARCHAR(20) NOT NULL, -- exportacao | exclusao
+				status VARCHAR(20) NOT NULL DEFAULT 'pendente', -- pendente | aprovada | rejeitada | concluida
+				motivo TEXT,
+				observacao_admin TEXT,
+				processado_por INTEGER,
+				processado_em TIMESTAMP,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_solicitacoes_lgpd_usuario_id ON solicitacoes_lgpd(usuario_id);
+			CREATE INDEX IF NOT EXISTS idx_solicitacoes_lgpd_status ON solicitacoes_lgpd(status);`,
+		},
+		{
+			name: "api_chaves",
+			query: `
+			CREATE TABLE IF NOT EXISTS api_chaves (
+				id SERIAL PRIMARY KEY,
+				nome VARCHAR(100) NOT NULL,
+				prefixo VARCHAR(16) NOT NULL UNIQUE,
+				chave_hash VARCHAR(64) NOT NULL,
+				escopos TEXT[] NOT NULL,
+				criado_por INTEGER REFERENCES admin(id) ON DELETE SET NULL,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				expira_em TIMESTAMP,
+				ultimo_uso_em TIMESTAMP,
+				ultimo_uso_ip VARCHAR(45),
+				revogada_em TIMESTAMP
+			);`,
+		},
+		{
+			name: "identidades_externas",
+			query: `
+			CREATE TABLE IF NOT EXISTS identidades_externas (
+				id SERIAL PRIMARY KEY,
+				usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+				provedor VARCHAR(50) NOT NULL,
+				sujeito VARCHAR(255) NOT NULL,
+				email VARCHAR(100),
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				ultimo_login_em TIMESTAMP,
+				UNIQUE (provedor, sujeito)
+			);
+			CREATE INDEX IF NOT EXISTS idx_identidades_externas_usuario_id ON identidades_externas(usuario_id);`,
+		},
+		{
+			name: "oidc_estados",
+			query: `
+			CREATE TABLE IF NOT EXISTS oidc_estados (
+				state VARCHAR(64) PRIMARY KEY,
+				provedor VARCHAR(50) NOT NULL,
+				code_verifier VARCHAR(128) NOT NULL,
+				nonce VARCHAR(64) NOT NULL,
+				expira_em TIMESTAMP NOT NULL
+			);`,
+		},
+		{
+			name: "sessoes",
+			query: `
+			CREATE TABLE IF NOT EXISTS sessoes (
+				id SERIAL PRIMARY KEY,
+				identificador VARCHAR(64) UNIQUE NOT NULL,
+				usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+				user_agent TEXT,
+				dispositivo VARCHAR(100),
+				ip VARCHAR(45),
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				ultima_atividade_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				expira_em TIMESTAMP NOT NULL,
+				encerrada_em TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_sessoes_usuario_id ON sessoes(usuario_id);`,
+		},
+		{
+			name: "enderecos",
+			query: `
+			CREATE TABLE IF NOT EXISTS enderecos (
+				id SERIAL PRIMARY KEY,
+				usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+				apelido VARCHAR(50),
+				destinatario VARCHAR(100),
+				cep VARCHAR(8) NOT NULL,
+				logradouro VARCHAR(200) NOT NULL,
+				numero VARCHAR(20) NOT NULL,
+				complemento VARCHAR(100),
+				bairro VARCHAR(100) NOT NULL,
+				cidade VARCHAR(100) NOT NULL,
+				estado CHAR(2) NOT NULL,
+				padrao BOOLEAN NOT NULL DEFAULT FALSE,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_enderecos_usuario_id ON enderecos(usuario_id);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_enderecos_padrao ON enderecos(usuario_id) WHERE padrao;
+			DO $$
+			BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'pedidos_endereco_id_fkey') THEN
+					ALTER TABLE pedidos ADD CONSTRAINT pedidos_endereco_id_fkey
+						FOREIGN KEY (endereco_id) REFERENCES enderecos(id) ON DELETE SET NULL;
+				END IF;
+			END $$;`,
+		},
+		{
+			name: "cep_cache",
+			query: `
+			CREATE TABLE IF NOT EXISTS cep_cache (
+				cep VARCHAR(8) PRIMARY KEY,
+				encontrado BOOLEAN NOT NULL,
+				logradouro VARCHAR(200),
+				complemento VARCHAR(200),
+				bairro VARCHAR(100),
+				cidade VARCHAR(100),
+				estado CHAR(2),
+				ibge VARCHAR(10),
+				provedor VARCHAR(30) NOT NULL,
+				atualizado_em TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);`,
+		},
+		{
+			name: "auditoria",
+			query: `
+			CREATE TABLE IF NOT EXISTS auditoria (
+				id BIGSERIAL PRIMARY KEY,
+				ator_tipo VARCHAR(20) NOT NULL,
+				ator_id INTEGER,
+				ator_email VARCHAR(100),
+				papel VARCHAR(50),
+				metodo VARCHAR(10) NOT NULL,
+				rota VARCHAR(200) NOT NULL,
+				caminho VARCHAR(500) NOT NULL,
+				entidade VARCHAR(50) NOT NULL,
+				entidade_id VARCHAR(50),
+				status INTEGER NOT NULL,
+				antes JSONB,
+				depois JSONB,
+				diferencas JSONB,
+				ip VARCHAR(45),
+				request_id VARCHAR(64),
+				criado_em TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_auditoria_criado_em ON auditoria(criado_em);
+			CREATE INDEX IF NOT EXISTS idx_auditoria_ator ON auditoria(ator_tipo, ator_id);
+			CREATE INDEX IF NOT EXISTS idx_auditoria_entidade ON auditoria(entidade, entidade_id);
+			CREATE OR REPLACE FUNCTION auditoria_somente_insercao() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'a tabela auditoria aceita apenas inserções';
+			END;
+			$$ LANGUAGE plpgsql;
+			DROP TRIGGER IF EXISTS trg_auditoria_somente_insercao ON auditoria;
+			CREATE TRIGGER trg_auditoria_somente_insercao
+				BEFORE UPDATE OR DELETE ON auditoria
+				FOR EACH ROW EXECUTE FUNCTION auditoria_somente_insercao();
+			DROP TRIGGER IF EXISTS trg_auditoria_sem_truncate ON auditoria;
+			CREATE TRIGGER trg_auditoria_sem_truncate
+				BEFORE TRUNCATE ON auditoria
+				FOR EACH STATEMENT EXECUTE FUNCTION auditoria_somente_insercao();`,
+		},
+		{
+			name: "categorias",
+			query: `
+			CREATE TABLE IF NOT EXISTS categorias (
+				id SERIAL PRIMARY KEY,
+				nome VARCHAR(100) NOT NULL,
+				slug VARCHAR(120) NOT NULL UNIQUE,
+				descricao TEXT,
+				categoria_pai_id INTEGER REFERENCES categorias(id) ON DELETE RESTRICT,
+				ordem INTEGER NOT NULL DEFAULT 0,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				CHECK (categoria_pai_id IS NULL OR categoria_pai_id <> id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_categorias_pai ON categorias(categoria_pai_id);`,
+		},
+		{
+			name: "produto_categorias",
+			query: `
+			CREATE TABLE IF NOT EXISTS produto_categorias (
+				produto_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				categoria_id INTEGER NOT NULL REFERENCES categorias(id) ON DELETE CASCADE,
+				PRIMARY KEY (produto_id, categoria_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_produto_categorias_categoria ON produto_categorias(categoria_id);`,
+		},
+		{
+			name: "produto_variantes",
+			query: `
+			CREATE TABLE IF NOT EXISTS produto_variantes (
+				id SERIAL PRIMARY KEY,
+				produto_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				sku VARCHAR(64) UNIQUE NOT NULL,
+				atributos JSONB NOT NULL DEFAULT '{}',
+				preco DECIMAL(10,2),
+				quantidade INTEGER NOT NULL DEFAULT 0 CHECK (quantidade >= 0),
+				imagem VARCHAR(255),
+				ativo BOOLEAN NOT NULL DEFAULT TRUE,
+				ordem INTEGER NOT NULL DEFAULT 0,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_produto_variantes_produto ON produto_variantes(produto_id);
+			ALTER TABLE pedido_itens ADD COLUMN IF NOT EXISTS variante_id INTEGER;
+			ALTER TABLE pedido_itens ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+			DO $$
+			BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'pedido_itens_variante_id_fkey') THEN
+					ALTER TABLE pedido_itens ADD CONSTRAINT pedido_itens_variante_id_fkey
+						FOREIGN KEY (variante_id) REFERENCES produto_variantes(id) ON DELETE SET NULL;
+				END IF;
+			END $$;`,
+		},
+		{
+			name: "produto_imagens",
+			query: `
+			CREATE TABLE IF NOT EXISTS produto_imagens (
+				id SERIAL PRIMARY KEY,
+				produto_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				url VARCHAR(500) NOT NULL,
+				miniaturas JSONB NOT NULL DEFAULT '{}',
+				texto_alternativo VARCHAR(255),
+				tipo_conteudo VARCHAR(50) NOT NULL,
+				largura INTEGER NOT NULL,
+				altura INTEGER NOT NULL,
+				tamanho_bytes INTEGER NOT NULL,
+				ordem INTEGER NOT NULL DEFAULT 0,
+				arquivos TEXT[] NOT NULL DEFAULT '{}',
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_produto_imagens_produto ON produto_imagens(produto_id, ordem);
+			ALTER TABLE produtos ALTER COLUMN imagem TYPE VARCHAR(500);`,
+		},
+		{
+			// Busca textual: configuração portuguesa que ignora acentos, documentos
+			// tsvector mantidos por gatilhos e índices de trigramas para erros de digitação.
+			name: "busca",
+			query: `
+			CREATE EXTENSION IF NOT EXISTS unaccent;
+			CREATE EXTENSION IF NOT EXISTS pg_trgm;
+			DO $$
+			BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'portugues_sem_acento') THEN
+					CREATE TEXT SEARCH CONFIGURATION portugues_sem_acento (COPY = portuguese);
+					ALTER TEXT SEARCH CONFIGURATION portugues_sem_acento
+						ALTER MAPPING FOR hword, hword_part, word WITH unaccent, portuguese_stem;
+				END IF;
+			END $$;
+
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS busca_documento TSVECTOR;
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS busca_texto TEXT;
+			CREATE OR REPLACE FUNCTION produtos_atualizar_busca() RETURNS TRIGGER AS $$
+			BEGIN
+				NEW.busca_documento :=
+					setweight(to_tsvector('portugues_sem_acento', COALESCE(NEW.nome, '')), 'A') ||
+					setweight(to_tsvector('portugues_sem_acento', COALESCE(NEW.detalhes, '')), 'B');
+				NEW.busca_texto := lower(unaccent(COALESCE(NEW.nome, '')));
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+			DROP TRIGGER IF EXISTS trg_produtos_busca ON produtos;
+			CREATE TRIGGER trg_produtos_busca BEFORE INSERT OR UPDATE OF nome, detalhes ON produtos
+				FOR EACH ROW EXECUTE FUNCTION produtos_atualizar_busca();
+			UPDATE produtos SET nome = nome WHERE busca_documento IS NULL;
+			CREATE INDEX IF NOT EXISTS idx_produtos_busca ON produtos USING GIN (busca_documento);
+			CREATE INDEX IF NOT EXISTS idx_produtos_busca_trgm ON produtos USING GIN (busca_texto gin_trgm_ops);
+
+			ALTER TABLE servicos ADD COLUMN IF NOT EXISTS busca_documento TSVECTOR;
+			ALTER TABLE servicos ADD COLUMN IF NOT EXISTS busca_texto TEXT;
+			CREATE OR REPLACE FUNCTION servicos_atualizar_busca() RETURNS TRIGGER AS $$
+			BEGIN
+				NEW.busca_documento :=
+					setweight(to_tsvector('portugues_sem_acento', COALESCE(NEW.nome, '')), 'A') ||
+					setweight(to_tsvector('portugues_sem_acento', COALESCE(NEW.detalhes, '')), 'B');
+				NEW.busca_texto := lower(unaccent(COALESCE(NEW.nome, '')));
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+			DROP TRIGGER IF EXISTS trg_servicos_busca ON servicos;
+			CREATE TRIGGER trg_servicos_busca BEFORE INSERT OR UPDATE OF nome, detalhes ON servicos
+				FOR EACH ROW EXECUTE FUNCTION servicos_atualizar_busca();
+			UPDATE servicos SET nome = nome WHERE busca_documento IS NULL;
+			CREATE INDEX IF NOT EXISTS idx_servicos_busca ON servicos USING GIN (busca_documento);
+			CREATE INDEX IF NOT EXISTS idx_servicos_busca_trgm ON servicos USING GIN (busca_texto gin_trgm_ops);
+
+			ALTER TABLE noticias ADD COLUMN IF NOT EXISTS busca_documento TSVECTOR;
+			ALTER TABLE noticias ADD COLUMN IF NOT EXISTS busca_texto TEXT;
+			CREATE OR REPLACE FUNCTION noticias_atualizar_busca() RETURNS TRIGGER AS $$
+			BEGIN
+				NEW.busca_documento :=
+					setweight(to_tsvector('portugues_sem_acento', COALESCE(NEW.titulo, '')), 'A') ||
+					setweight(to_tsvector('portugues_sem_acento', COALESCE(NEW.subtitulo, '')), 'B') ||
+					setweight(to_tsvector('portugues_sem_acento', COALESCE(NEW.conteudo, '')), 'C');
+				NEW.busca_texto := lower(unaccent(COALESCE(NEW.titulo, '')));
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+			DROP TRIGGER IF EXISTS trg_noticias_busca ON noticias;
+			CREATE TRIGGER trg_noticias_busca BEFORE INSERT OR UPDATE OF titulo, subtitulo, conteudo ON noticias
+				FOR EACH ROW EXECUTE FUNCTION noticias_atualizar_busca();
+			UPDATE noticias SET titulo = titulo WHERE busca_documento IS NULL;
+			CREATE INDEX IF NOT EXISTS idx_noticias_busca ON noticias USING GIN (busca_documento);
+			CREATE INDEX IF NOT EXISTS idx_noticias_busca_trgm ON noticias USING GIN (busca_texto gin_trgm_ops);`,
+		},
+		{
+			// Livro de estoque: toda alteração de quantidade de produtos e variantes
+			// gera um movimento. Os saldos anteriores ao livro entram como saldo_inicial.
+			// Excluir o produto ou a variante não apaga o histórico: as referências
+			// viram NULL e o SKU e o indicador de_variante continuam no movimento.
+			name: "estoque_movimentos",
+			query: `
+			CREATE TABLE IF NOT EXISTS estoque_movimentos (
+				id SERIAL PRIMARY KEY,
+				produto_id INTEGER REFERENCES produtos(id) ON DELETE SET NULL,
+				variante_id INTEGER REFERENCES produto_variantes(id) ON DELETE SET NULL,
+				de_variante BOOLEAN NOT NULL DEFAULT FALSE,
+				sku VARCHAR(64),
+				tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('saldo_inicial', 'entrada', 'venda', 'devolucao', 'ajuste', 'perda')),
+				quantidade INTEGER NOT NULL CHECK (quantidade <> 0),
+				saldo_apos INTEGER NOT NULL,
+				motivo TEXT,
+				pedido_id INTEGER REFERENCES pedidos(id) ON DELETE SET NULL,
+				ator_tipo VARCHAR(20) NOT NULL,
+				ator_id INTEGER,
+				ator_email VARCHAR(100),
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_estoque_movimentos_produto ON estoque_movimentos(produto_id, criado_em);
+			CREATE INDEX IF NOT EXISTS idx_estoque_movimentos_pedido ON estoque_movimentos(pedido_id);
+			ALTER TABLE estoque_movimentos ADD COLUMN IF NOT EXISTS de_variante BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE estoque_movimentos ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+			DO $$
+			BEGIN
+				IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'estoque_movimentos_produto_id_fkey' AND confdeltype = 'c') THEN
+					UPDATE estoque_movimentos m SET de_variante = TRUE, sku = v.sku
+					FROM produto_variantes v WHERE v.id = m.variante_id;
+					ALTER TABLE estoque_movimentos ALTER COLUMN produto_id DROP NOT NULL;
+					ALTER TABLE estoque_movimentos DROP CONSTRAINT estoque_movimentos_produto_id_fkey;
+					ALTER TABLE estoque_movimentos ADD CONSTRAINT estoque_movimentos_produto_id_fkey
+						FOREIGN KEY (produto_id) REFERENCES produtos(id) ON DELETE SET NULL;
+					ALTER TABLE estoque_movimentos DROP CONSTRAINT IF EXISTS estoque_movimentos_variante_id_fkey;
+					ALTER TABLE estoque_movimentos ADD CONSTRAINT estoque_movimentos_variante_id_fkey
+						FOREIGN KEY (variante_id) REFERENCES produto_variantes(id) ON DELETE SET NULL;
+				END IF;
+			END $$;
+			INSERT INTO estoque_movimentos (produto_id, tipo, quantidade, saldo_apos, motivo, ator_tipo)
+			SELECT p.id, 'saldo_inicial', p.quantidade, p.quantidade, 'Saldo existente antes do livro de estoque', 'sistema'
+			FROM produtos p
+			WHERE p.quantidade <> 0
+				AND NOT EXISTS (SELECT 1 FROM estoque_movimentos m WHERE m.produto_id = p.id AND NOT m.de_variante);
+			INSERT INTO estoque_movimentos (produto_id, variante_id, de_variante, sku, tipo, quantidade, saldo_apos, motivo, ator_tipo)
+			SELECT v.produto_id, v.id, TRUE, v.sku, 'saldo_inicial', v.quantidade, v.quantidade, 'Saldo existente antes do livro de estoque', 'sistema'
+			FROM produto_variantes v
+			WHERE v.quantidade <> 0
+				AND NOT EXISTS (SELECT 1 FROM estoque_movimentos m WHERE m.variante_id = v.id);`,
+		},
+		{
+			// Notificações internas (painel) para admins, funcionários e clientes.
+			name: "notificacoes",
+			query: `
+			CREATE TABLE IF NOT EXISTS notificacoes (
+				id SERIAL PRIMARY KEY,
+				destinatario_tipo VARCHAR(20) NOT NULL CHECK (destinatario_tipo IN ('admin', 'funcionario', 'cliente')),
+				destinatario_id INTEGER NOT NULL,
+				tipo VARCHAR(50) NOT NULL,
+				titulo VARCHAR(200) NOT NULL,
+				mensagem TEXT NOT NULL,
+				dados JSONB,
+				lida_em TIMESTAMP,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_notificacoes_destinatario ON notificacoes(destinatario_tipo, destinatario_id, criado_em DESC);`,
+		},
+		{
+			// Estoque mínimo por produto (0 desativa). alerta_estoque_em marca o
+			// alerta já enviado e é limpo quando o estoque volta acima do mínimo.
+			name: "produtos_estoque_minimo",
+			query: `
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS estoque_minimo INTEGER NOT NULL DEFAULT 0 CHECK (estoque_minimo >= 0);
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS alerta_estoque_em TIMESTAMP;
+			CREATE INDEX IF NOT EXISTS idx_pedidos_data ON pedidos(data_pedido);`,
+		},
+		{
+			// Código de produto usado pela importação de planilhas (upsert por SKU).
+			name: "produtos_sku",
+			query: `
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_produtos_sku ON produtos(sku);`,
+		},
+		{
+			name: "importacoes",
+			query: `
+			CREATE TABLE IF NOT EXISTS importacoes (
+				id SERIAL PRIMARY KEY,
+				arquivo_nome VARCHAR(255) NOT NULL,
+				formato VARCHAR(10) NOT NULL,
+				simulacao BOOLEAN NOT NULL DEFAULT false,
+				status VARCHAR(20) NOT NULL DEFAULT 'pendente' CHECK (status IN ('pendente', 'processando', 'concluida', 'falhou')),
+				mapeamento JSONB,
+				total_linhas INTEGER NOT NULL DEFAULT 0,
+				processadas INTEGER NOT NULL DEFAULT 0,
+				criados INTEGER NOT NULL DEFAULT 0,
+				atualizados INTEGER NOT NULL DEFAULT 0,
+				com_erro INTEGER NOT NULL DEFAULT 0,
+				erros JSONB,
+				mensagem TEXT,
+				ator_tipo VARCHAR(20) NOT NULL,
+				ator_id INTEGER,
+				ator_email VARCHAR(100),
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				iniciado_em TIMESTAMP,
+				concluido_em TIMESTAMP
+			);`,
+		},
+		{
+			// Promoções agendadas. O período usa TIMESTAMPTZ porque vem do
+			// cliente com fuso; alvos apontam para produtos, serviços ou categorias.
+			name: "promocoes",
+			query: `
+			CREATE TABLE IF NOT EXISTS promocoes (
+				id SERIAL PRIMARY KEY,
+				nome VARCHAR(150) NOT NULL,
+				descricao TEXT,
+				tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('preco', 'percentual')),
+				valor DECIMAL(10,2) NOT NULL CHECK (valor > 0),
+				inicio TIMESTAMPTZ NOT NULL,
+				fim TIMESTAMPTZ NOT NULL,
+				ativo BOOLEAN NOT NULL DEFAULT true,
+				iniciada_em TIMESTAMPTZ,
+				encerrada_em TIMESTAMPTZ,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				CHECK (fim > inicio),
+				CHECK (tipo <> 'percentual' OR valor < 100)
+			);
+			CREATE INDEX IF NOT EXISTS idx_promocoes_periodo ON promocoes(inicio, fim) WHERE ativo;
+			CREATE TABLE IF NOT EXISTS promocao_alvos (
+				promocao_id INTEGER NOT NULL REFERENCES promocoes(id) ON DELETE CASCADE,
+				tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('produto', 'servico', 'categoria')),
+				alvo_id INTEGER NOT NULL,
+				PRIMARY KEY (promocao_id, tipo, alvo_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_promocao_alvos_alvo ON promocao_alvos(tipo, alvo_id);`,
+		},
+		{
+			// Histórico de preços: uma linha sempre que o preço normal ou o
+			// efetivo muda. Produtos existentes começam com o preço atual.
+			name: "produto_precos",
+			query: `
+			CREATE TABLE IF NOT EXISTS produto_precos (
+				id SERIAL PRIMARY KEY,
+				produto_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				preco DECIMAL(10,2) NOT NULL,
+				preco_efetivo DECIMAL(10,2) NOT NULL,
+				origem VARCHAR(20) NOT NULL,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_produto_precos_produto ON produto_precos(produto_id, criado_em);
+			INSERT INTO produto_precos (produto_id, preco, preco_efetivo, origem)
+			SELECT p.id, p.preco, p.preco, 'inicial' FROM produtos p
+			WHERE NOT EXISTS (SELECT 1 FROM produto_precos h WHERE h.produto_id = p.id);`,
+		},
+		{
+			// Uma avaliação por cliente e produto; só as aprovadas aparecem
+			// na loja e entram na média. pedidos.usuario_id guarda o dono do
+			// pedido para confirmar a compra; os pedidos antigos são ligados
+			// pelo email uma única vez, ao criar a coluna.
+			name: "avaliacoes",
+			query: `
+			CREATE TABLE IF NOT EXISTS avaliacoes (
+				id SERIAL PRIMARY KEY,
+				produto_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+				nota SMALLINT NOT NULL CHECK (nota BETWEEN 1 AND 5),
+				comentario TEXT NOT NULL,
+				status VARCHAR(20) NOT NULL DEFAULT 'pendente' CHECK (status IN ('pendente', 'aprovada', 'rejeitada')),
+				motivo_rejeicao TEXT,
+				moderado_por INTEGER REFERENCES admin(id) ON DELETE SET NULL,
+				moderado_em TIMESTAMP,
+				resposta TEXT,
+				respondido_em TIMESTAMP,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (produto_id, usuario_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_avaliacoes_produto ON avaliacoes(produto_id) WHERE status = 'aprovada';
+			CREATE INDEX IF NOT EXISTS idx_avaliacoes_status ON avaliacoes(status, criado_em);
+			DO $$
+			BEGIN
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'pedidos' AND column_name = 'usuario_id') THEN
+					ALTER TABLE pedidos ADD COLUMN usuario_id INTEGER REFERENCES usuarios(id) ON DELETE SET NULL;
+					UPDATE pedidos pe SET usuario_id = u.id FROM usuarios u WHERE u.email = pe.cliente_email;
+				END IF;
+			END $$;
+			CREATE INDEX IF NOT EXISTS idx_pedidos_usuario_id ON pedidos(usuario_id);`,
+		},
+		{
+			// aviso_pendente é ligado quando o estoque sai do zero e desligado
+			// pela tarefa que envia os avisos.
+			name: "lista_desejos",
+			query: `
+			CREATE TABLE IF NOT EXISTS lista_desejos (
+				usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+				produto_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				avisar_disponibilidade BOOLEAN NOT NULL DEFAULT false,
+				aviso_pendente BOOLEAN NOT NULL DEFAULT false,
+				avisado_em TIMESTAMP,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (usuario_id, produto_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_lista_desejos_produto ON lista_desejos(produto_id) WHERE avisar_disponibilidade;
+			CREATE INDEX IF NOT EXISTS idx_lista_desejos_pendentes ON lista_desejos(produto_id) WHERE aviso_pendente;`,
+		},
+		{
+			// Pares de produtos comprados no mesmo pedido, recalculados por
+			// uma tarefa periódica (ver handlers/relacionados.go).
+			name: "produtos_relacionados",
+			query: `
+			CREATE TABLE IF NOT EXISTS produtos_relacionados (
+				produto_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				relacionado_id INTEGER NOT NULL REFERENCES produtos(id) ON DELETE CASCADE,
+				pedidos INTEGER NOT NULL,
+				atualizado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (produto_id, relacionado_id)
+			);`,
+		},
+		{
+			// Slugs de produtos, serviços e notícias. Os registros existentes
+			// recebem o slug na inicialização, pelo mesmo código da aplicação
+			// (handlers.PreencherSlugsPendentes); slugs_antigos guarda os slugs
+			// substituídos para redirecionar links antigos.
+			name: "slugs",
+			query: `
+			DROP FUNCTION IF EXISTS slug_de(TEXT);
+
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS slug VARCHAR(130);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_produtos_slug ON produtos (slug);
+
+			ALTER TABLE servicos ADD COLUMN IF NOT EXISTS slug VARCHAR(130);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_servicos_slug ON servicos (slug);
+
+			ALTER TABLE noticias ADD COLUMN IF NOT EXISTS slug VARCHAR(130);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_noticias_slug ON noticias (slug);
+
+			CREATE TABLE IF NOT EXISTS slugs_antigos (
+				tabela VARCHAR(30) NOT NULL,
+				slug VARCHAR(130) NOT NULL,
+				registro_id INTEGER NOT NULL,
+				criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (tabela, slug)
+			);`,
+		},
+		{
+			// Especificações técnicas tipadas: definidas por categoria (e herdadas
+			// pelas subcategorias), com os valores de cada produto em JSONB.
+			name: "categoria_especificacoes",
+			query: `
+			CREATE TABLE IF NOT EXISTS categoria_especificacoes (
+				id SERIAL PRIMARY KEY,
+				categoria_id INTEGER NOT NULL REFERENCES categorias(id) ON DELETE CASCADE,
+				chave VARCHAR(50) NOT NULL,
+				nome VARCHAR(100) NOT NULL,
+				tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('texto', 'numero', 'booleano', 'opcao')),
+				unidade VARCHAR(20),
+				opcoes TEXT[] NOT NULL DEFAULT '{}',
+				obrigatoria BOOLEAN NOT NULL DEFAULT false,
+				ordem INTEGER NOT NULL DEFAULT 0,
+				UNIQUE (categoria_id, chave)
+			);
+			ALTER TABLE produtos ADD COLUMN IF NOT EXISTS especificacoes JSONB NOT NULL DEFAULT '{}';`,
+		},
+		{
+			// Correção única dos registros de auditoria gravados antes de os dados
+			// pessoais serem ocultados (camposPessoais em handlers/auditoria.go).
+			// A marca em migracoes_dados impede que rode de novo: é a única
+			// alteração permitida na tabela, com o gatilho desligado só nesta transação.
+			name: "auditoria_dados_pessoais",
+			query: `
+			CREATE TABLE IF NOT EXISTS migracoes_dados (
+				nome VARCHAR(100) PRIMARY KEY,
+				executada_em TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			DO $$
+			BEGIN
+				IF EXISTS (SELECT 1 FROM migracoes_dados WHERE nome = 'auditoria_dados_pessoais') THEN
+					RETURN;
+				END IF;
+
+				ALTER TABLE auditoria DISABLE TRIGGER trg_auditoria_somente_insercao;
+				UPDATE auditoria a
+				SET antes = CASE WHEN jsonb_typeof(a.antes) = 'object' THEN a.antes || COALESCE((
+						SELECT jsonb_object_agg(c, '"[omitido]"'::jsonb) FROM unnest(p.campos) c WHERE a.antes ? c), '{}') ELSE a.antes END,
+					depois = CASE WHEN jsonb_typeof(a.depois) = 'object' THEN a.depois || COALESCE((
+						SELECT jsonb_object_agg(c, '"[omitido]"'::jsonb) FROM unnest(p.campos) c WHERE a.depois ? c), '{}') ELSE a.depois END,
+					diferencas = CASE WHEN jsonb_typeof(a.diferencas) = 'object' THEN a.diferencas || COALESCE((
+						SELECT jsonb_object_agg(c, '{"antes": "[omitido]", "depois": "[omitido]"}'::jsonb) FROM unnest(p.campos) c WHERE a.diferencas ? c), '{}') ELSE a.diferencas END
+				FROM (VALUES
+					('usuarios', ARRAY['nome_completo', 'email', 'telefone', 'cpf']),
+					('pedidos', ARRAY['cliente_email', 'endereco_entrega']),
+					('orcamentos', ARRAY['nome_cliente', 'email_cliente', 'telefone', 'descricao']),
+					('suporte', ARRAY['nome', 'email', 'mensagem', 'cliente_email']),
+					('lgpd', ARRAY['email', 'motivo']),
+					('avaliacoes', ARRAY['usuario_id', 'comentario']),
+					('enderecos', ARRAY['usuario_id', 'apelido', 'destinatario', 'cep', 'logradouro', 'numero', 'complemento', 'bairro', 'cidade', 'estado'])
+				) AS p(entidade, campos)
+				WHERE a.entidade = p.entidade
+					AND (a.antes ?| p.campos OR a.depois ?| p.campos OR a.diferencas ?| p.campos);
+				ALTER TABLE auditoria ENABLE TRIGGER trg_auditoria_somente_insercao;
+
+				INSERT INTO migracoes_dados (nome) VALUES ('auditoria_dados_pessoais');
+			END;
+			$$;
+			DROP FUNCTION IF EXISTS auditoria_ocultar(JSONB, TEXT[], JSONB);`,
 		},
 	}
 
@@ -232,6 +880,31 @@ func CreateTables() error {
 
 func DropTables() error {
 	tables := []string{
+		"migracoes_dados",
+		"categoria_especificacoes",
+		"slugs_antigos",
+		"produtos_relacionados",
+		"lista_desejos",
+		"avaliacoes",
+		"produto_precos",
+		"promocao_alvos",
+		"promocoes",
+		"importacoes",
+		"notificacoes",
+		"estoque_movimentos",
+		"produto_imagens",
+		"produto_variantes",
+		"produto_categorias",
+		"categorias",
+		"auditoria",
+		"cep_cache",
+		"enderecos",
+		"sessoes",
+		"oidc_estados",
+		"identidades_externas",
+		"api_chaves",
+		"solicitacoes_lgpd",
+		"consentimentos",
 		"pedido_itens",
 		"pedidos",
 		"suporte",
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"bytebros.ti/models"
@@ -28,19 +29,23 @@ func CriarAdministrador(c *gin.Context) {
 		return
 	}
 
+	if !senhaAtendePolitica(c, admin.Senha, admin.Email) {
+		return
+	}
+
 	db := c.MustGet("db").(*sql.DB)
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(admin.Senha), bcrypt.DefaultCost)
+	hashedPassword, err := gerarHashSenha(admin.Senha)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criptografar senha"})
 		return
 	}
 
 	err = db.QueryRow(`
-        INSERT INTO admin (nome, email, senha, is_admin)
+        INSERT INTO admin (nome, email, senha_hash, is_admin)
         VALUES ($1, $2, $3, $4)
         RETURNING id, criado_em, atualizado_em`,
-		admin.Nome, admin.Email, string(hashedPassword), admin.IsAdmin).
+		admin.Nome, admin.Email, hashedPassword, admin.IsAdmin).
 		Scan(&admin.ID, &admin.CriadoEm, &admin.Atualizado)
 
 	if err != nil {
@@ -67,11 +72,12 @@ func LoginAdmin(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 	var admin models.Administrador
 
+	var ativo bool
 	err := db.QueryRow(`
-        SELECT id, nome, email, senha, is_admin 
-        FROM admin 
+        SELECT id, nome, email, senha_hash, is_admin, ativo
+        FROM admin
         WHERE email = $1`, login.Email).
-		Scan(&admin.ID, &admin.Nome, &admin.Email, &admin.Senha, &admin.IsAdmin)
+		Scan(&admin.ID, &admin.Nome, &admin.Email, &admin.Senha, &admin.IsAdmin, &ativo)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -87,6 +93,12 @@ func LoginAdmin(c *gin.Context) {
 		return
 	}
 
+	if !ativo {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Administrador desativado"})
+		return
+	}
+	atualizarHashSenha(db, "admin", admin.ID, admin.Senha, login.Senha)
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"admin_id": admin.ID,
 		"email":    admin.Email,
@@ -109,8 +121,36 @@ func LoginAdmin(c *gin.Context) {
 	})
 }
 
+func obterAdminID(c *gin.Context) (int, bool) {
+	claims, exists := c.Get("jwt_claims")
+	if !exists {
+		return 0, false
+	}
+	jwtClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+	adminID, ok := jwtClaims["admin_id"].(float64)
+	if !ok {
+		return 0, false
+	}
+	return int(adminID), true
+}
+
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Chaves de API já tiveram o escopo validado pelo AuthMiddleware; aqui
+		// só passam as rotas com escopo explícito em escoposRotasChaveAPI.
+		if _, viaChaveAPI := c.Get("api_chave_id"); viaChaveAPI {
+			if escopoNecessario(c) == "" {
+				c.JSON(http.StatusForbidden, gin.H{"erro": "Rota não disponível para chaves de API"})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		claims, exists := c.Get("jwt_claims")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"erro": "Token inválido"})
@@ -119,7 +159,34 @@ func AdminMiddleware() gin.HandlerFunc {
 		}
 
 		jwtClaims, ok := claims.(jwt.MapClaims)
-		if !ok || !jwtClaims["is_admin"].(bool) {
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"erro": "Acesso restrito a administradores"})
+			c.Abort()
+			return
+		}
+		isAdmin, _ := jwtClaims["is_admin"].(bool)
+		adminID, temAdminID := jwtClaims["admin_id"].(float64)
+		if !isAdmin || !temAdminID {
+			c.JSON(http.StatusForbidden, gin.H{"erro": "Acesso restrito a administradores"})
+			c.Abort()
+			return
+		}
+
+		// O token vale por 8 horas; consulta o cadastro para que desativações e
+		// rebaixamentos tenham efeito imediato.
+		db := c.MustGet("db").(*sql.DB)
+		var ativo bool
+		err := db.QueryRow(`SELECT is_admin, ativo FROM admin WHERE id = $1`, int(adminID)).Scan(&isAdmin, &ativo)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusForbidden, gin.H{"erro": "Administrador não encontrado"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar administrador"})
+			}
+			c.Abort()
+			return
+		}
+		if !ativo || !isAdmin {
 			c.JSON(http.StatusForbidden, gin.H{"erro": "Acesso restrito a administradores"})
 			c.Abort()
 			return
@@ -129,20 +196,189 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
-func DeletarAdministrador(c *gin.Context) {
+func scanAdministradorResumo(row linhaSQL) (models.AdministradorResumo, error) {
+	var a models.AdministradorResumo
+	err := row.Scan(&a.ID, &a.Nome, &a.Email, &a.IsAdmin, &a.Ativo, &a.CriadoEm, &a.AtualizadoEm)
+	return a, err
+}
+
+func ListarAdministradores(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
-	adminID := c.Param("id")
 
-	claims, _ := c.Get("jwt_claims")
-	jwtClaims := claims.(jwt.MapClaims)
-	if !jwtClaims["is_admin"].(bool) {
-		c.JSON(http.StatusForbidden, gin.H{"erro": "Acesso negado. Apenas administradores superiores podem deletar outros administradores."})
+	busca := c.Query("busca")
+	ativo := c.Query("ativo")
+	isAdmin := c.Query("is_admin")
+
+	query := `SELECT id, nome, email, is_admin, ativo, criado_em, atualizado_em FROM admin`
+	args := []interface{}{}
+	whereClauses := []string{}
+	argCounter := 1
+
+	if busca != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf("(nome ILIKE $%d OR email ILIKE $%d)", argCounter, argCounter))
+		args = append(args, "%"+busca+"%")
+		argCounter++
+	}
+	if ativo == "true" || ativo == "false" {
+		whereClauses = append(whereClauses, fmt.Sprintf("ativo = $%d", argCounter))
+		args = append(args, ativo == "true")
+		argCounter++
+	}
+	if isAdmin == "true" || isAdmin == "false" {
+		whereClauses = append(whereClauses, fmt.Sprintf("is_admin = $%d", argCounter))
+		args = append(args, isAdmin == "true")
+		argCounter++
+	}
+
+	if len(whereClauses) > 0 {
+		query += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	query += " ORDER BY nome ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar administradores", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	administradores := make([]models.AdministradorResumo, 0)
+	for rows.Next() {
+		a, err := scanAdministradorResumo(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler administradores", "detalhes": err.Error()})
+			return
+		}
+		administradores = append(administradores, a)
+	}
+
+	c.JSON(http.StatusOK, administradores)
+}
+
+func ObterAdministrador(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	admin, err := scanAdministradorResumo(db.QueryRow(`
+		SELECT id, nome, email, is_admin, ativo, criado_em, atualizado_em
+		FROM admin
+		WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Administrador não encontrado."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar administrador", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, admin)
+}
+
+func AtualizarAdministrador(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var req models.AtualizarAdministradorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM admin WHERE email = $1 AND id != $2`, req.Email, id).Scan(&count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar email."})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Este email já está em uso por outro administrador."})
+		return
+	}
+
+	admin, err := scanAdministradorResumo(db.QueryRow(`
+		UPDATE admin
+		SET nome = $1, email = $2, atualizado_em = $3
+		WHERE id = $4
+		RETURNING id, nome, email, is_admin, ativo, criado_em, atualizado_em`,
+		req.Nome, req.Email, time.Now(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Administrador não encontrado."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar administrador.", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, admin)
+}
+
+func RedefinirSenhaAdministrador(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var req models.RedefinirSenhaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	var email string
+	if err := db.QueryRow(`SELECT email FROM admin WHERE id = $1`, id).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Administrador não encontrado."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar administrador.", "detalhes": err.Error()})
+		}
+		return
+	}
+	if !senhaAtendePolitica(c, req.NovaSenha, email) {
+		return
+	}
+
+	hashedPassword, err := gerarHashSenha(req.NovaSenha)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criptografar senha"})
+		return
+	}
+
+	result, err := db.Exec(`UPDATE admin SET senha_hash = $1, atualizado_em = $2 WHERE id = $3`, hashedPassword, time.Now(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao redefinir senha.", "detalhes": err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Administrador não encontrado."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Senha do administrador redefinida com sucesso!"})
+}
+
+func AlterarStatusAdministrador(c *gin.Context) {
+	var req models.AlterarStatusAdministradorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
 		return
 	}
 
-	requesterAdminID := int(jwtClaims["admin_id"].(float64))
-	if fmt.Sprintf("%d", requesterAdminID) == adminID {
-		c.JSON(http.StatusForbidden, gin.H{"erro": "Você não pode deletar sua própria conta de administrador."})
+	definirAtivoAdministrador(c, *req.Ativo)
+}
+
+// DeletarAdministrador desativa o administrador em vez de removê-lo, preservando
+// o histórico das ações que ele realizou.
+func DeletarAdministrador(c *gin.Context) {
+	definirAtivoAdministrador(c, false)
+}
+
+func definirAtivoAdministrador(c *gin.Context, ativo bool) {
+	db := c.MustGet("db").(*sql.DB)
+	adminID := c.Param("id")
+
+	requesterAdminID, _ := obterAdminID(c)
+	if !ativo && fmt.Sprintf("%d", requesterAdminID) == adminID {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Você não pode desativar sua própria conta de administrador."})
 		return
 	}
 
@@ -156,22 +392,87 @@ func DeletarAdministrador(c *gin.Context) {
 		}
 		return
 	}
-	if isTargetSuperAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"erro": "Não é possível deletar um administrador superior."})
+	if !ativo && isTargetSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Não é possível desativar um administrador superior. Rebaixe-o antes."})
 		return
 	}
 
-	result, err := db.Exec(`DELETE FROM admin WHERE id = $1`, adminID)
+	_, err = db.Exec(`UPDATE admin SET ativo = $1, atualizado_em = $2 WHERE id = $3`, ativo, time.Now(), adminID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao deletar administrador.", "detalhes": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao alterar status do administrador.", "detalhes": err.Error()})
+		return
+	}
+
+	if ativo {
+		c.JSON(http.StatusOK, gin.H{"mensagem": "Administrador ativado com sucesso!"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"mensagem": "Administrador desativado com sucesso!"})
+	}
+}
+
+func AlterarPapelAdministrador(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	adminID := c.Param("id")
+
+	var req models.AlterarPapelAdministradorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	requesterAdminID, _ := obterAdminID(c)
+	if !*req.IsAdmin && fmt.Sprintf("%d", requesterAdminID) == adminID {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Você não pode rebaixar sua própria conta de administrador."})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"erro": "Administrador não encontrado ou já deletado."})
+	admin, err := scanAdministradorResumo(db.QueryRow(`
+		UPDATE admin
+		SET is_admin = $1, atualizado_em = $2
+		WHERE id = $3
+		RETURNING id, nome, email, is_admin, ativo, criado_em, atualizado_em`,
+		*req.IsAdmin, time.Now(), adminID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Administrador não encontrado."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao alterar papel do administrador.", "detalhes": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"mensagem": "Administrador deletado com sucesso!"})
+	c.JSON(http.StatusOK, admin)
+}
+
+// CriarSuperAdmin cadastra (ou promove e reativa, se o email já existir) um
+// administrador superior. É usado pelo comando de linha `criar-superadmin`
+// para criar o primeiro acesso ao painel.
+func CriarSuperAdmin(db *sql.DB, nome, email, senha string) (int, error) {
+	if problemas := validarSenha(senha, email); len(problemas) > 0 {
+		return 0, fmt.Errorf("senha recusada pela política de senhas: %s", strings.Join(problemas, "; "))
+	}
+
+	hashedPassword, err := gerarHashSenha(senha)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao criptografar senha: %w", err)
+	}
+
+	var id int
+	err = db.QueryRow(`
+		INSERT INTO admin (nome, email, senha_hash, is_admin, ativo)
+		VALUES ($1, $2, $3, true, true)
+		ON CONFLICT (email) DO UPDATE
+		SET nome = EXCLUDED.nome, senha_hash = EXCLUDED.senha_hash, is_admin = true, ativo = true, atualizado_em = CURRENT_TIMESTAMP
+		RETURNING id`,
+		nome, email, hashedPassword).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao gravar administrador: %w", err)
+	}
+	return id, nil
+}
+
+func ContarSuperAdminsAtivos(db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM admin WHERE is_admin = true AND ativo = true`).Scan(&count)
+	return count, err
 }
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const prefixoChaveAPI = "bbk"
+
+// escoposRotasChaveAPI define, rota a rota ("MÉTODO caminho" como registrado
+// no gin), o escopo que uma chave de API precisa ter. Rotas fora do mapa nunca
+// aceitam chaves: credenciais, cargos e status da equipe, administradores,
+// clientes, orçamentos, suporte, LGPD e as próprias chaves.
+var escoposRotasChaveAPI = map[string]string{
+	"POST /api/produtos":                                                "produtos:write",
+	"PUT /api/produtos/:id":                                             "produtos:write",
+	"DELETE /api/produtos/:id":                                          "produtos:write",
+	"PUT /api/produtos/:id/categorias":                                  "produtos:write",
+	"POST /api/produtos/:id/variantes":                                  "produtos:write",
+	"PUT /api/produtos/:id/variantes/:variante_id":                      "produtos:write",
+	"DELETE /api/produtos/:id/variantes/:variante_id":                   "produtos:write",
+	"POST /api/produtos/:id/imagens":                                    "produtos:write",
+	"PUT /api/produtos/:id/imagens":                                     "produtos:write",
+	"PUT /api/produtos/:id/imagens/:imagem_id":                          "produtos:write",
+	"DELETE /api/produtos/:id/imagens/:imagem_id":                       "produtos:write",
+	"GET /api/produtos/:id/estoque":                                     "produtos:read",
+	"POST /api/produtos/:id/estoque":                                    "produtos:write",
+	"GET /api/produtos/:id/estoque/movimentos":                          "produtos:read",
+	"POST /api/admin/produtos/importar":                                 "produtos:write",
+	"GET /api/admin/produtos/importacoes":                               "produtos:read",
+	"GET /api/admin/produtos/importacoes/:id":                           "produtos:read",
+	"GET /api/admin/produtos/exportar":                                  "produtos:read",
+	"GET /api/admin/estoque/reposicao":                                  "produtos:read",
+	"GET /api/admin/estoque/divergencias":                               "produtos:read",
+	"POST /api/admin/categorias":                                        "categorias:write",
+	"PUT /api/admin/categorias/:id":                                     "categorias:write",
+	"DELETE /api/admin/categorias/:id":                                  "categorias:write",
+	"POST /api/admin/categorias/:id/especificacoes":                     "categorias:write",
+	"PUT /api/admin/categorias/:id/especificacoes/:especificacao_id":    "categorias:write",
+	"DELETE /api/admin/categorias/:id/especificacoes/:especificacao_id": "categorias:write",
+	"POST /api/servicos/":                                               "servicos:write",
+	"PUT /api/servicos/:id":                                             "servicos:write",
+	"DELETE /api/servicos/:id":                                          "servicos:write",
+	"POST /api/admin/noticias":                                          "noticias:write",
+	"PUT /api/admin/noticias/:id":                                       "noticias:write",
+	"DELETE /api/admin/noticias/:id":                                    "noticias:write",
+	// Pedidos trazem o endereço de entrega, necessário para o ERP expedir.
+	"GET /api/admin/pedidos":            "pedidos:read",
+	"PUT /api/admin/pedidos/:id/status": "pedidos:write",
+}
+
+// escoposChaveAPI são os escopos que podem ser atribuídos a uma chave.
+var escoposChaveAPI = func() map[string]bool {
+	escopos := map[string]bool{}
+	for _, escopo := range escoposRotasChaveAPI {
+		escopos[escopo] = true
+	}
+	return escopos
+}()
+
+func escopoValido(escopo string) bool {
+	return escoposChaveAPI[escopo]
+}
+
+// escopoNecessario devolve o escopo exigido pela rota atual, ou "" quando a
+// rota não aceita chaves de API.
+func escopoNecessario(c *gin.Context) string {
+	return escoposRotasChaveAPI[c.Request.Method+" "+c.FullPath()]
+}
+
+func hashChaveAPI(segredo string) string {
+	soma := sha256.Sum256([]byte(segredo))
+	return hex.EncodeToString(soma[:])
+}
+
+func gerarChaveAPI() (prefixo, chave string, err error) {
+	bytesPrefixo := make([]byte, 4)
+	bytesSegredo := make([]byte, 24)
+	if _, err := rand.Read(bytesPrefixo); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(bytesSegredo); err != nil {
+		return "", "", err
+	}
+	prefixo = hex.EncodeToString(bytesPrefixo)
+	chave = fmt.Sprintf("%s_%s_%s", prefixoChaveAPI, prefixo, hex.EncodeToString(bytesSegredo))
+	return prefixo, chave, nil
+}
+
+// autenticarChaveAPI valida o cabeçalho X-API-Key e o escopo exigido pela rota.
+// Em caso de falha a resposta já é escrita e a requisição abortada.
+func autenticarChaveAPI(c *gin.Context, chave string) bool {
+	partes := strings.Split(chave, "_")
+	if len(partes) != 3 || partes[0] != prefixoChaveAPI {
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Chave de API inválida"})
+		c.Abort()
+		return false
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+
+	var id int
+	var nome, hashDB string
+	var escopos []string
+	var expiraEm, revogadaEm sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, nome, chave_hash, escopos, expira_em, revogada_em
+		FROM api_chaves
+		WHERE prefixo = $1`, partes[1]).
+		Scan(&id, &nome, &hashDB, pq.Array(&escopos), &expiraEm, &revogadaEm)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("ERRO BD: Falha ao buscar chave de API: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Chave de API inválida"})
+		c.Abort()
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashDB), []byte(hashChaveAPI(chave))) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Chave de API inválida"})
+		c.Abort()
+		return false
+	}
+
+	if revogadaEm.Valid || (expiraEm.Valid && expiraEm.Time.Before(time.Now())) {
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Chave de API revogada ou expirada"})
+		c.Abort()
+		return false
+	}
+
+	escopo := escopoNecessario(c)
+	permitido := false
+	for _, e := range escopos {
+		if escopo != "" && e == escopo {
+			permitido = true
+			break
+		}
+	}
+	if !permitido {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Chave de API sem permissão para este recurso", "escopo_necessario": escopo})
+		c.Abort()
+		return false
+	}
+
+	if _, err := db.Exec(`UPDATE api_chaves SET ultimo_uso_em = $1, ultimo_uso_ip = $2 WHERE id = $3`, time.Now(), c.ClientIP(), id); err != nil {
+		log.Printf("ERRO BD: Falha ao registrar uso da chave de API %d: %v", id, err)
+	}
+
+	c.Set("api_chave_id", id)
+	c.Set("api_chave_nome", nome)
+	c.Set("api_escopos", escopos)
+	return true
+}
+
+func scanChaveAPI(row linhaSQL) (models.ChaveAPI, error) {
+	var k models.ChaveAPI
+	var criadoPor sql.NullInt64
+	var expiraEm, ultimoUsoEm, revogadaEm sql.NullTime
+	var ultimoUsoIP sql.NullString
+	err := row.Scan(&k.ID, &k.Nome, &k.Prefixo, pq.Array(&k.Escopos), &criadoPor, &k.CriadoEm, &expiraEm, &ultimoUsoEm, &ultimoUsoIP, &revogadaEm)
+	if err != nil {
+		return k, err
+	}
+	if criadoPor.Valid {
+		id := int(criadoPor.Int64)
+		k.CriadoPor = &id
+	}
+	if expiraEm.Valid {
+		k.ExpiraEm = &expiraEm.Time
+	}
+	if ultimoUsoEm.Valid {
+		k.UltimoUsoEm = &ultimoUsoEm.Time
+	}
+	if revogadaEm.Valid {
+		k.RevogadaEm = &revogadaEm.Time
+	}
+	k.UltimoUsoIP = ultimoUsoIP.String
+	return k, nil
+}
+
+func CriarChaveAPI(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	var req models.CriarChaveAPIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	for _, escopo := range req.Escopos {
+		if !escopoValido(escopo) {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("Escopo inválido: %s", escopo)})
+			return
+		}
+	}
+	if req.ExpiraEm != nil && req.ExpiraEm.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "A data de expiração deve estar no futuro"})
+		return
+	}
+
+	prefixo, chave, err := gerarChaveAPI()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar chave de API"})
+		return
+	}
+
+	adminID, _ := obterAdminID(c)
+
+	chaveAPI, err := scanChaveAPI(db.QueryRow(`
+		INSERT INTO api_chaves (nome, prefixo, chave_hash, escopos, criado_por, expira_em)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, nome, prefixo, escopos, criado_por, criado_em, expira_em, ultimo_uso_em, ultimo_uso_ip, revogada_em`,
+		req.Nome, prefixo, hashChaveAPI(chave), pq.Array(req.Escopos), sql.NullInt64{Int64: int64(adminID), Valid: adminID != 0}, req.ExpiraEm))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criar chave de API", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ChaveAPICriadaResponse{ChaveAPI: chaveAPI, Chave: chave})
+}
+
+func ListarChavesAPI(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	query := `
+		SELECT id, nome, prefixo, escopos, criado_por, criado_em, expira_em, ultimo_uso_em, ultimo_uso_ip, revogada_em
+		FROM api_chaves`
+	if c.Query("incluir_revogadas") != "true" {
+		query += " WHERE revogada_em IS NULL"
+	}
+	query += " ORDER BY criado_em DESC"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar chaves de API", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	chaves := make([]models.ChaveAPI, 0)
+	for rows.Next() {
+		k, err := scanChaveAPI(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler chaves de API", "detalhes": err.Error()})
+			return
+		}
+		chaves = append(chaves, k)
+	}
+
+	c.JSON(http.StatusOK, chaves)
+}
+
+func RevogarChaveAPI(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	result, err := db.Exec(`UPDATE api_chaves SET revogada_em = $1 WHERE id = $2 AND revogada_em IS NULL`, time.Now(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao revogar chave de API", "detalhes": err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Chave de API não encontrada ou já revogada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Chave de API revogada com sucesso"})
+}
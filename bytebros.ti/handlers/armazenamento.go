@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Armazenamento guarda os arquivos enviados (imagens de produtos) e devolve a
+// URL pública de cada um. A chave é um caminho relativo com "/".
+type Armazenamento interface {
+	Nome() string
+	Salvar(ctx context.Context, chave string, conteudo []byte, tipoConteudo string) (string, error)
+	Remover(ctx context.Context, chave string) error
+}
+
+var (
+	armazenamentoOnce  sync.Once
+	armazenamentoAtual Armazenamento
+	armazenamentoErro  error
+)
+
+// obterArmazenamento escolhe o backend por ARMAZENAMENTO_PROVEDOR ("local",
+// padrão, ou "s3"). A configuração é conferida na inicialização por
+// ValidarArmazenamento, então aqui o backend é sempre válido.
+func obterArmazenamento() Armazenamento {
+	armazenamentoOnce.Do(func() {
+		armazenamentoAtual, armazenamentoErro = configurarArmazenamento()
+	})
+	return armazenamentoAtual
+}
+
+// ValidarArmazenamento falha quando o provedor pedido está mal configurado,
+// em vez de gravar silenciosamente em outro lugar.
+func ValidarArmazenamento() error {
+	obterArmazenamento()
+	return armazenamentoErro
+}
+
+func configurarArmazenamento() (Armazenamento, error) {
+	switch provedor := strings.ToLower(os.Getenv("ARMAZENAMENTO_PROVEDOR")); provedor {
+	case "", "local":
+		return novoArmazenamentoLocal(), nil
+	case "s3":
+		s3, err := novoArmazenamentoS3()
+		if err != nil {
+			return nil, fmt.Errorf("armazenamento S3 mal configurado: %w", err)
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("ARMAZENAMENTO_PROVEDOR inválido: %q (use local ou s3)", provedor)
+	}
+}
+
+// DiretorioUploadsLocal informa o prefixo de URL e o diretório que o servidor
+// deve publicar quando os arquivos ficam no disco local.
+func DiretorioUploadsLocal() (prefixo, diretorio string, ok bool) {
+	local, ok := obterArmazenamento().(*armazenamentoLocal)
+	if !ok || !strings.HasPrefix(local.urlBase, "/") {
+		return "", "", false
+	}
+	return local.urlBase, local.diretorio, true
+}
+
+type armazenamentoLocal struct {
+	diretorio string
+	urlBase   string
+}
+
+func novoArmazenamentoLocal() *armazenamentoLocal {
+	diretorio := os.Getenv("ARMAZENAMENTO_DIRETORIO")
+	if diretorio == "" {
+		diretorio = "uploads"
+	}
+	urlBase := os.Getenv("ARMAZENAMENTO_URL_BASE")
+	if urlBase == "" {
+		urlBase = "/uploads"
+	}
+	return &armazenamentoLocal{diretorio: diretorio, urlBase: strings.TrimSuffix(urlBase, "/")}
+}
+
+func (a *armazenamentoLocal) Nome() string { return "local" }
+
+func (a *armazenamentoLocal) caminho(chave string) (string, error) {
+	limpa := filepath.Clean(filepath.FromSlash(chave))
+	if filepath.IsAbs(limpa) || limpa == "." || strings.HasPrefix(limpa, "..") {
+		return "", fmt.Errorf("chave de arquivo inválida: %q", chave)
+	}
+	return filepath.Join(a.diretorio, limpa), nil
+}
+
+func (a *armazenamentoLocal) Salvar(ctx context.Context, chave string, conteudo []byte, tipoConteudo string) (string, error) {
+	caminho, err := a.caminho(chave)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(caminho), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(caminho, conteudo, 0o644); err != nil {
+		return "", err
+	}
+	return a.urlBase + "/" + chave, nil
+}
+
+func (a *armazenamentoLocal) Remover(ctx context.Context, chave string) error {
+	caminho, err := a.caminho(chave)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(caminho); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// armazenamentoS3 fala com qualquer serviço compatível com S3 (AWS, MinIO,
+// R2...) usando endereçamento por caminho e assinatura AWS Signature V4.
+type armazenamentoS3 struct {
+	endpoint    *url.URL
+	bucket      string
+	regiao      string
+	chaveAcesso string
+	segredo     string
+	urlPublica  string
+	cliente     *http.Client
+}
+
+func novoArmazenamentoS3() (*armazenamentoS3, error) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	bucket := os.Getenv("S3_BUCKET")
+	chaveAcesso := os.Getenv("S3_ACCESS_KEY")
+	segredo := os.Getenv("S3_SECRET_KEY")
+	if endpoint == "" || bucket == "" || chaveAcesso == "" || segredo == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY e S3_SECRET_KEY são obrigatórios")
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT inválido: %q", endpoint)
+	}
+
+	regiao := os.Getenv("S3_REGIAO")
+	if regiao == "" {
+		regiao = "us-east-1"
+	}
+	urlPublica := os.Getenv("S3_URL_PUBLICA")
+	if urlPublica == "" {
+		urlPublica = u.String() + "/" + bucket
+	}
+
+	return &armazenamentoS3{
+		endpoint:    u,
+		bucket:      bucket,
+		regiao:      regiao,
+		chaveAcesso: chaveAcesso,
+		segredo:     segredo,
+		urlPublica:  strings.TrimSuffix(urlPublica, "/"),
+		cliente:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (a *armazenamentoS3) Nome() string { return "s3" }
+
+func (a *armazenamentoS3) Salvar(ctx context.Context, chave string, conteudo []byte, tipoConteudo string) (string, error) {
+	if err := a.enviar(ctx, http.MethodPut, chave, conteudo, tipoConteudo); err != nil {
+		return "", err
+	}
+	return a.urlPublica + "/" + chave, nil
+}
+
+func (a *armazenamentoS3) Remover(ctx context.Context, chave string) error {
+	return a.enviar(ctx, http.MethodDelete, chave, nil, "")
+}
+
+func (a *armazenamentoS3) enviar(ctx context.Context, metodo, chave string, conteudo []byte, tipoConteudo string) error {
+	destino := *a.endpoint
+	destino.Path = strings.TrimSuffix(destino.Path, "/") + "/" + a.bucket + "/" + chave
+
+	req, err := http.NewRequestWithContext(ctx, metodo, destino.String(), bytes.NewReader(conteudo))
+	if err != nil {
+		return err
+	}
+	if tipoConteudo != "" {
+		req.Header.Set("Content-Type", tipoConteudo)
+	}
+	a.assinar(req, conteudo, time.Now().UTC())
+
+	resp, err := a.cliente.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		corpo, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("S3 respondeu %d em %s %s: %s", resp.StatusCode, metodo, chave, strings.TrimSpace(string(corpo)))
+	}
+	return nil
+}
+
+func sha256Hex(dados []byte) string {
+	soma := sha256.Sum256(dados)
+	return hex.EncodeToString(soma[:])
+}
+
+func hmacSHA256(chave []byte, dados string) []byte {
+	mac := hmac.New(sha256.New, chave)
+	mac.Write([]byte(dados))
+	return mac.Sum(nil)
+}
+
+// assinar adiciona os cabeçalhos x-amz-* e Authorization (Signature V4).
+func (a *armazenamentoS3) assinar(req *http.Request, conteudo []byte, agora time.Time) {
+	dataHora := agora.Format("20060102T150405Z")
+	data := agora.Format("20060102")
+	hashConteudo := sha256Hex(conteudo)
+
+	req.Header.Set("x-amz-date", dataHora)
+	req.Header.Set("x-amz-content-sha256", hashConteudo)
+
+	cabecalhos := map[string]string{"host": req.URL.Host}
+	for nome := range req.Header {
+		cabecalhos[strings.ToLower(nome)] = strings.TrimSpace(req.Header.Get(nome))
+	}
+	nomes := make([]string, 0, len(cabecalhos))
+	for nome := range cabecalhos {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+
+	var canonicos strings.Builder
+	for _, nome := range nomes {
+		canonicos.WriteString(nome + ":" + cabecalhos[nome] + "\n")
+	}
+	assinados := strings.Join(nomes, ";")
+
+	requisicaoCanonica := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicos.String(),
+		assinados,
+		hashConteudo,
+	}, "\n")
+
+	escopo := data + "/" + a.regiao + "/s3/aws4_request"
+	textoAssinar := strings.Join([]string{"AWS4-HMAC-SHA256", dataHora, escopo, sha256Hex([]byte(requisicaoCanonica))}, "\n")
+
+	chave := hmacSHA256([]byte("AWS4"+a.segredo), data)
+	chave = hmacSHA256(chave, a.regiao)
+	chave = hmacSHA256(chave, "s3")
+	chave = hmacSHA256(chave, "aws4_request")
+	assinatura := hex.EncodeToString(hmacSHA256(chave, textoAssinar))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.chaveAcesso, escopo, assinados, assinatura))
+}
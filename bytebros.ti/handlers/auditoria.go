@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// tabelasAuditoria associa o recurso da rota (primeiro segmento após /api/,
+// /api/admin/ ou /api/equipe/) à tabela usada nos retratos antes/depois.
+var tabelasAuditoria = map[string]string{
+	"administradores": "admin",
+	"funcionarios":    "funcionarios",
+	"usuarios":        "usuarios",
+	"produtos":        "produtos",
+	"categorias":      "categorias",
+	"promocoes":       "promocoes",
+	"avaliacoes":      "avaliacoes",
+	"servicos":        "servicos",
+	"noticias":        "noticias",
+	"pedidos":         "pedidos",
+	"orcamentos":      "orcamentos",
+	"suporte":         "suporte",
+	"api-chaves":      "api_chaves",
+	"lgpd":            "solicitacoes_lgpd",
+}
+
+// subrecursosAuditoria cobre rotas aninhadas (ex.: /api/produtos/:id/variantes),
+// registradas com a tabela do sub-recurso e o parâmetro do seu próprio ID.
+var subrecursosAuditoria = map[string]struct{ tabela, parametro string }{
+	"variantes":      {"produto_variantes", "variante_id"},
+	"imagens":        {"produto_imagens", "imagem_id"},
+	"especificacoes": {"categoria_especificacoes", "especificacao_id"},
+	// A importação responde com o ID do registro em importacoes.
+	"importar": {"importacoes", "id"},
+}
+
+// camposSigilosos nunca são gravados na auditoria; quando mudam, a diferença
+// aparece apenas como "[omitido]".
+var camposSigilosos = map[string]bool{
+	"senha_hash": true,
+	"chave_hash": true,
+}
+
+const valorOmitido = "[omitido]"
+
+// camposPessoais são dados pessoais de clientes, por entidade da rota. Como a
+// auditoria não aceita alterações, eles nunca são gravados (a anonimização da
+// LGPD não teria como apagá-los); as diferenças só indicam que o campo mudou.
+// A migração "auditoria_dados_pessoais" aplicou a mesma lista, uma única vez,
+// aos registros antigos.
+var camposPessoais = map[string]map[string]bool{
+	"usuarios":   {"nome_completo": true, "email": true, "telefone": true, "cpf": true},
+	"pedidos":    {"cliente_email": true, "endereco_entrega": true},
+	"orcamentos": {"nome_cliente": true, "email_cliente": true, "telefone": true, "descricao": true},
+	"suporte":    {"nome": true, "email": true, "mensagem": true, "cliente_email": true},
+	"lgpd":       {"email": true, "motivo": true},
+	"avaliacoes": {"usuario_id": true, "comentario": true},
+	"enderecos": {"usuario_id": true, "apelido": true, "destinatario": true, "cep": true, "logradouro": true,
+		"numero": true, "complemento": true, "bairro": true, "cidade": true, "estado": true},
+}
+
+func campoOmitido(entidade, campo string) bool {
+	return camposSigilosos[campo] || camposPessoais[entidade][campo]
+}
+
+// camposDerivados são calculados pelo banco (índices de busca) e não entram nos retratos.
+var camposDerivados = map[string]bool{
+	"busca_documento": true,
+	"busca_texto":     true,
+}
+
+// RequestIDMiddleware reaproveita o cabeçalho X-Request-ID do cliente (ou do
+// proxy) ou gera um novo, devolvendo-o na resposta para correlacionar logs.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if !requestIDValido(requestID) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err == nil {
+				requestID = hex.EncodeToString(b)
+			}
+		}
+		c.Set("request_id", requestID)
+		c.Header("X-Request-ID", requestID)
+		c.Next()
+	}
+}
+
+func requestIDValido(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// respostaCapturada guarda uma cópia do corpo da resposta para descobrir o ID
+// de registros recém-criados.
+type respostaCapturada struct {
+	gin.ResponseWriter
+	corpo *bytes.Buffer
+}
+
+func (w respostaCapturada) Write(b []byte) (int, error) {
+	if w.corpo.Len() < 64*1024 {
+		w.corpo.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// AuditoriaMiddleware grava em `auditoria` toda requisição que altera dados
+// (POST, PUT, PATCH, DELETE) nas rotas administrativas e da equipe, com o
+// estado do registro antes e depois da alteração.
+func AuditoriaMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		db := c.MustGet("db").(*sql.DB)
+
+		entidade := entidadeDaRota(c.FullPath())
+		tabela := tabelasAuditoria[entidade]
+		entidadeID := c.Param("id")
+		for _, segmento := range strings.Split(c.FullPath(), "/") {
+			if sub, ok := subrecursosAuditoria[segmento]; ok {
+				entidade, tabela, entidadeID = segmento, sub.tabela, c.Param(sub.parametro)
+			}
+		}
+
+		antes := retratoRegistro(db, tabela, entidadeID)
+
+		captura := respostaCapturada{ResponseWriter: c.Writer, corpo: &bytes.Buffer{}}
+		c.Writer = captura
+		c.Next()
+
+		status := c.Writer.Status()
+		if entidadeID == "" && status < 400 {
+			entidadeID = idDaResposta(captura.corpo.Bytes())
+		}
+
+		var depois map[string]interface{}
+		if status < 400 {
+			depois = retratoRegistro(db, tabela, entidadeID)
+		}
+
+		registrarAuditoria(db, c, entidade, entidadeID, status, antes, depois)
+	}
+}
+
+func entidadeDaRota(rota string) string {
+	caminho := strings.TrimPrefix(rota, "/api/")
+	caminho = strings.TrimPrefix(caminho, "admin/")
+	caminho = strings.TrimPrefix(caminho, "equipe/")
+	return strings.SplitN(strings.Trim(caminho, "/"), "/", 2)[0]
+}
+
+func idDaResposta(corpo []byte) string {
+	var resposta map[string]interface{}
+	if err := json.Unmarshal(corpo, &resposta); err != nil {
+		return ""
+	}
+	switch id := resposta["id"].(type) {
+	case float64:
+		return strconv.Itoa(int(id))
+	case string:
+		return id
+	}
+	return ""
+}
+
+// retratoRegistro devolve a linha da tabela como mapa JSON, sem campos sigilosos.
+// Os dados pessoais ficam no retrato para o cálculo das diferenças e são
+// ocultados por ocultarCampos antes da gravação.
+func retratoRegistro(db *sql.DB, tabela, id string) map[string]interface{} {
+	if tabela == "" || id == "" {
+		return nil
+	}
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil
+	}
+
+	var linha []byte
+	err := db.QueryRow(fmt.Sprintf(`SELECT row_to_json(t) FROM %s t WHERE id = $1`, tabela), id).Scan(&linha)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("ERRO BD: Falha ao obter retrato de %s %s para auditoria: %v", tabela, id, err)
+		}
+		return nil
+	}
+
+	var retrato map[string]interface{}
+	if err := json.Unmarshal(linha, &retrato); err != nil {
+		return nil
+	}
+	// Campos sigilosos viram uma impressão digital curta, suficiente para
+	// detectar a troca de senha sem guardar o hash; ela é removida antes da gravação.
+	for campo, valor := range retrato {
+		if camposDerivados[campo] {
+			delete(retrato, campo)
+			continue
+		}
+		if camposSigilosos[campo] {
+			soma := sha256.Sum256([]byte(fmt.Sprint(valor)))
+			retrato[campo] = hex.EncodeToString(soma[:8])
+		}
+	}
+	return retrato
+}
+
+func ocultarCampos(entidade string, retrato map[string]interface{}) {
+	for campo := range retrato {
+		if campoOmitido(entidade, campo) {
+			retrato[campo] = valorOmitido
+		}
+	}
+}
+
+// diferencasRegistro lista apenas os campos que mudaram, no formato
+// {"campo": {"antes": ..., "depois": ...}}.
+func diferencasRegistro(entidade string, antes, depois map[string]interface{}) map[string]interface{} {
+	diferencas := map[string]interface{}{}
+	campos := map[string]bool{}
+	for campo := range antes {
+		campos[campo] = true
+	}
+	for campo := range depois {
+		campos[campo] = true
+	}
+	for campo := range campos {
+		if campo == "atualizado_em" {
+			continue
+		}
+		valorAntes, valorDepois := antes[campo], depois[campo]
+		if reflect.DeepEqual(valorAntes, valorDepois) {
+			continue
+		}
+		if campoOmitido(entidade, campo) {
+			diferencas[campo] = gin.H{"antes": valorOmitido, "depois": valorOmitido}
+			continue
+		}
+		diferencas[campo] = gin.H{"antes": valorAntes, "depois": valorDepois}
+	}
+	return diferencas
+}
+
+// identificarAtor descreve quem fez a requisição: administrador, funcionário,
+// cliente ou integração via chave de API.
+func identificarAtor(c *gin.Context) (tipo string, id int, email, papel string) {
+	if chaveID, ok := c.Get("api_chave_id"); ok {
+		id, _ = chaveID.(int)
+		return "api_chave", id, "", c.GetString("api_chave_nome")
+	}
+
+	claims, _ := c.Get("jwt_claims")
+	jwtClaims, _ := claims.(jwt.MapClaims)
+	email, _ = jwtClaims["email"].(string)
+
+	if adminID, ok := obterAdminID(c); ok {
+		papel = "admin"
+		if isAdmin, _ := jwtClaims["is_admin"].(bool); isAdmin {
+			papel = "super_admin"
+		}
+		return "admin", adminID, email, papel
+	}
+	if funcionarioID, ok := obterFuncionarioLogado(c); ok {
+		cargo, _ := jwtClaims["cargo"].(string)
+		return "funcionario", funcionarioID, email, cargo
+	}
+	if usuarioID, _, ok := obterUsuarioLogado(c); ok {
+		return "cliente", usuarioID, email, ""
+	}
+	return "desconhecido", 0, email, ""
+}
+
+func registrarAuditoria(db *sql.DB, c *gin.Context, entidade, entidadeID string, status int, antes, depois map[string]interface{}) {
+	atorTipo, atorID, atorEmail, papel := identificarAtor(c)
+
+	var antesJSON, depoisJSON, diferencasJSON []byte
+	if antes != nil || depois != nil {
+		diferencasJSON, _ = json.Marshal(diferencasRegistro(entidade, antes, depois))
+	}
+	if antes != nil {
+		ocultarCampos(entidade, antes)
+		antesJSON, _ = json.Marshal(antes)
+	}
+	if depois != nil {
+		ocultarCampos(entidade, depois)
+		depoisJSON, _ = json.Marshal(depois)
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO auditoria (ator_tipo, ator_id, ator_email, papel, metodo, rota, caminho, entidade, entidade_id, status, antes, depois, diferencas, ip, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		atorTipo, sql.NullInt64{Int64: int64(atorID), Valid: atorID != 0}, atorEmail, papel,
+		c.Request.Method, c.FullPath(), c.Request.URL.Path, entidade, entidadeID, status,
+		nullJSON(antesJSON), nullJSON(depoisJSON), nullJSON(diferencasJSON), c.ClientIP(), c.GetString("request_id"))
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao gravar auditoria (%s %s): %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+}
+
+func nullJSON(dados []byte) interface{} {
+	if dados == nil {
+		return nil
+	}
+	return string(dados)
+}
+
+func ListarAuditoria(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	query := `
+		SELECT id, ator_tipo, ator_id, ator_email, papel, metodo, rota, caminho, entidade, entidade_id, status,
+			antes, depois, diferencas, ip, request_id, criado_em
+		FROM auditoria`
+
+	args := []interface{}{}
+	whereClauses := []string{}
+	argCounter := 1
+
+	filtros := []struct{ parametro, coluna string }{
+		{"ator_tipo", "ator_tipo"},
+		{"ator_id", "ator_id"},
+		{"ator_email", "ator_email"},
+		{"entidade", "entidade"},
+		{"entidade_id", "entidade_id"},
+		{"request_id", "request_id"},
+	}
+	for _, f := range filtros {
+		if valor := c.Query(f.parametro); valor != "" {
+			whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", f.coluna, argCounter))
+			args = append(args, valor)
+			argCounter++
+		}
+	}
+
+	if de := c.Query("de"); de != "" {
+		inicio, err := time.Parse("2006-01-02", de)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Parâmetro 'de' deve estar no formato AAAA-MM-DD"})
+			return
+		}
+		whereClauses = append(whereClauses, fmt.Sprintf("criado_em >= $%d", argCounter))
+		args = append(args, inicio)
+		argCounter++
+	}
+	if ate := c.Query("ate"); ate != "" {
+		fim, err := time.Parse("2006-01-02", ate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Parâmetro 'ate' deve estar no formato AAAA-MM-DD"})
+			return
+		}
+		whereClauses = append(whereClauses, fmt.Sprintf("criado_em < $%d", argCounter))
+		args = append(args, fim.AddDate(0, 0, 1))
+		argCounter++
+	}
+
+	if len(whereClauses) > 0 {
+		query += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	limite := 50
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= 200 {
+		limite = v
+	}
+	pagina := 1
+	if v, err := strconv.Atoi(c.Query("pagina")); err == nil && v > 0 {
+		pagina = v
+	}
+	query += fmt.Sprintf(" ORDER BY criado_em DESC, id DESC LIMIT $%d OFFSET $%d", argCounter, argCounter+1)
+	args = append(args, limite, (pagina-1)*limite)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar auditoria", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	registros := make([]models.RegistroAuditoria, 0)
+	for rows.Next() {
+		var r models.RegistroAuditoria
+		var atorID sql.NullInt64
+		var atorEmail, papel, entidadeID, ip, requestID sql.NullString
+		var antes, depois, diferencas []byte
+		if err := rows.Scan(&r.ID, &r.AtorTipo, &atorID, &atorEmail, &papel, &r.Metodo, &r.Rota, &r.Caminho, &r.Entidade, &entidadeID, &r.Status,
+			&antes, &depois, &diferencas, &ip, &requestID, &r.CriadoEm); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler auditoria", "detalhes": err.Error()})
+			return
+		}
+		if atorID.Valid {
+			id := int(atorID.Int64)
+			r.AtorID = &id
+		}
+		r.AtorEmail = atorEmail.String
+		r.Papel = papel.String
+		r.EntidadeID = entidadeID.String
+		r.IP = ip.String
+		r.RequestID = requestID.String
+		r.Antes = antes
+		r.Depois = depois
+		r.Diferencas = diferencas
+		registros = append(registros, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"registros": registros, "pagina": pagina, "limite": limite})
+}
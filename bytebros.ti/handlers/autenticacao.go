@@ -31,7 +31,11 @@ func RegistrarUsuario(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Senha), bcrypt.DefaultCost)
+	if !senhaAtendePolitica(c, user.Senha, user.Email) {
+		return
+	}
+
+	hashedPassword, err := gerarHashSenha(user.Senha)
 	if err != nil {
 		log.Printf("ERRO: Falha ao criptografar senha: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criptografar senha"})
@@ -46,7 +50,7 @@ func RegistrarUsuario(c *gin.Context) {
 		INSERT INTO usuarios (nome_completo, email, senha_hash, telefone)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id, nome_completo, email, telefone`,
-		user.Nome, user.Email, string(hashedPassword), user.Telefone).
+		user.Nome, user.Email, hashedPassword, user.Telefone).
 		Scan(&newUser.ID, &newUser.Nome, &newUser.Email, &newUser.Telefone)
 
 	if err != nil {
@@ -56,7 +60,7 @@ func RegistrarUsuario(c *gin.Context) {
 	}
 	log.Printf("DEBUG: Usuário registrado com ID: %d. Nome após DB: '%s', Telefone após DB: '%s'", newUser.ID, newUser.Nome, newUser.Telefone)
 
-	token, err := generateJWTToken(newUser.ID, newUser.Email, "")
+	token, err := iniciarSessaoUsuario(c, db, newUser.ID, newUser.Email)
 	if err != nil {
 		log.Printf("ERRO: Falha ao gerar token JWT para usuário %s: %v", newUser.Email, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar token"})
@@ -120,8 +124,9 @@ func LoginUsuario(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Credenciais inválidas"})
 		return
 	}
+	atualizarHashSenha(db, "usuarios", user.ID, senhaHashDB, login.Senha)
 
-	token, err := generateJWTToken(user.ID, user.Email, "")
+	token, err := iniciarSessaoUsuario(c, db, user.ID, user.Email)
 	if err != nil {
 		log.Printf("ERRO: Falha ao gerar token JWT para usuário %s: %v", user.Email, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar token"})
@@ -153,16 +158,27 @@ func checkEmailExists(c *gin.Context, email, table string) error {
 	return nil
 }
 
+const validadeTokenJWT = 8 * time.Hour
+
 func generateJWTToken(id int, email, cargo string) (string, error) {
+	return generateJWTTokenSessao(id, email, cargo, "")
+}
+
+// generateJWTTokenSessao inclui o claim "sid", que o AuthMiddleware usa para
+// recusar tokens de sessões encerradas.
+func generateJWTTokenSessao(id int, email, cargo, sessao string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": id,
 		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 8).Unix(),
+		"exp":     time.Now().Add(validadeTokenJWT).Unix(),
 	}
 
 	if cargo != "" {
 		claims["cargo"] = cargo
 	}
+	if sessao != "" {
+		claims["sid"] = sessao
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
@@ -176,6 +192,31 @@ func handleAuthError(c *gin.Context, err error) {
 	}
 }
 
+// obterUsuarioLogado retorna o ID e o email do cliente autenticado, rejeitando
+// tokens de funcionários e administradores.
+func obterUsuarioLogado(c *gin.Context) (int, string, bool) {
+	claims, exists := c.Get("jwt_claims")
+	if !exists {
+		return 0, "", false
+	}
+	jwtClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", false
+	}
+	if _, isFuncionario := jwtClaims["cargo"]; isFuncionario {
+		return 0, "", false
+	}
+	if _, isAdmin := jwtClaims["is_admin"]; isAdmin {
+		return 0, "", false
+	}
+	userID, ok := jwtClaims["user_id"].(float64)
+	if !ok {
+		return 0, "", false
+	}
+	email, _ := jwtClaims["email"].(string)
+	return int(userID), email, true
+}
+
 func ObterPerfil(c *gin.Context) {
 	claims, exists := c.Get("jwt_claims")
 	if !exists {
@@ -199,11 +240,27 @@ func ObterPerfil(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":    jwtClaims["user_id"],
-		"email": jwtClaims["email"],
-		"tipo":  "usuario",
-	})
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"id":    jwtClaims["user_id"],
+			"email": jwtClaims["email"],
+			"tipo":  "usuario",
+		})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	perfil, err := obterPerfilUsuario(db, usuarioID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Usuário não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar perfil", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, perfil)
 }
 
 func AtualizarEmailUsuario(c *gin.Context) {
@@ -268,7 +325,10 @@ func AtualizarEmailUsuario(c *gin.Context) {
 		return
 	}
 
-	newToken, err := generateJWTToken(userID, req.NovoEmail, "")
+	// O novo token mantém a sessão atual, para que ela continue aparecendo
+	// (e possa ser encerrada) na lista de sessões do cliente.
+	sessao, _ := jwtClaims["sid"].(string)
+	newToken, err := generateJWTTokenSessao(userID, req.NovoEmail, "", sessao)
 	if err != nil {
 		log.Printf("ERRO: Falha ao gerar novo token JWT para usuário %d: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Email alterado, mas falha ao gerar novo token."})
@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sqlMediaAvaliacoes e sqlTotalAvaliacoes agregam as avaliações aprovadas do
+// produto p. A média é arredondada para servir de cursor sem perder precisão.
+const (
+	sqlMediaAvaliacoes = `(SELECT ROUND(AVG(a.nota), 2) FROM avaliacoes a WHERE a.produto_id = p.id AND a.status = 'aprovada')`
+	sqlTotalAvaliacoes = `(SELECT COUNT(*) FROM avaliacoes a WHERE a.produto_id = p.id AND a.status = 'aprovada')`
+)
+
+// sqlCompraEntregue confirma que o cliente $1 recebeu o produto $2: algum
+// pedido do cliente, com status "Entregue", contém o item. O pedido é do
+// cliente pelo usuario_id; só pedidos sem dono registrado caem no email atual.
+const sqlCompraEntregue = `
+	SELECT EXISTS (
+		SELECT 1
+		FROM pedido_itens pi
+		JOIN pedidos pe ON pe.id = pi.pedido_id
+		WHERE pi.produto_id = $2 AND LOWER(pe.status) = 'entregue'
+			AND (pe.usuario_id = $1 OR (pe.usuario_id IS NULL
+				AND pe.cliente_email = (SELECT email FROM usuarios WHERE id = $1))))`
+
+// colunasAvaliacao mostra apenas o primeiro nome do autor.
+const colunasAvaliacao = `
+	a.id, a.produto_id, pr.nome, a.usuario_id, split_part(u.nome_completo, ' ', 1), a.nota, a.comentario,
+	a.status, a.motivo_rejeicao, a.resposta, a.respondido_em, a.criado_em, a.atualizado_em
+	FROM avaliacoes a
+	JOIN produtos pr ON pr.id = a.produto_id
+	JOIN usuarios u ON u.id = a.usuario_id`
+
+func scanAvaliacao(row linhaSQL) (models.Avaliacao, error) {
+	var a models.Avaliacao
+	var motivo, resposta sql.NullString
+	var respondidoEm sql.NullTime
+	err := row.Scan(&a.ID, &a.ProdutoID, &a.ProdutoNome, &a.UsuarioID, &a.Autor, &a.Nota, &a.Comentario,
+		&a.Status, &motivo, &resposta, &respondidoEm, &a.CriadoEm, &a.AtualizadoEm)
+	if err != nil {
+		return a, err
+	}
+	a.MotivoRejeicao = motivo.String
+	if resposta.Valid {
+		a.Resposta = &resposta.String
+	}
+	if respondidoEm.Valid {
+		a.RespondidoEm = &respondidoEm.Time
+	}
+	return a, nil
+}
+
+// listarAvaliacoes executa a consulta com o filtro e a ordenação informados;
+// os argumentos de LIMIT/OFFSET vêm por último em args.
+func listarAvaliacoes(db *sql.DB, filtro, ordem string, args ...interface{}) ([]models.Avaliacao, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT %s%s ORDER BY %s LIMIT $%d OFFSET $%d`,
+		colunasAvaliacao, filtro, ordem, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	avaliacoes := make([]models.Avaliacao, 0)
+	for rows.Next() {
+		a, err := scanAvaliacao(rows)
+		if err != nil {
+			return nil, err
+		}
+		avaliacoes = append(avaliacoes, a)
+	}
+	return avaliacoes, rows.Err()
+}
+
+func paginacaoAvaliacoes(c *gin.Context) (pagina, limite int) {
+	limite = 50
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= 200 {
+		limite = v
+	}
+	pagina = 1
+	if v, err := strconv.Atoi(c.Query("pagina")); err == nil && v > 0 {
+		pagina = v
+	}
+	return pagina, limite
+}
+
+// AvaliarProduto cria ou substitui a avaliação do cliente logado. Uma
+// avaliação editada volta para a fila de moderação.
+func AvaliarProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes podem avaliar produtos"})
+		return
+	}
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.AvaliacaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	req.Comentario = strings.TrimSpace(req.Comentario)
+	if req.Comentario == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "O comentário não pode ficar em branco"})
+		return
+	}
+
+	var existe bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM produtos WHERE id = $1)`, produtoID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+
+	var comprou bool
+	if err := db.QueryRow(sqlCompraEntregue, usuarioID, produtoID).Scan(&comprou); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar compras do cliente", "detalhes": err.Error()})
+		return
+	}
+	if !comprou {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Só é possível avaliar produtos de pedidos já entregues"})
+		return
+	}
+
+	var avaliacaoID int
+	var criada bool
+	err = db.QueryRow(`
+		INSERT INTO avaliacoes (produto_id, usuario_id, nota, comentario)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (produto_id, usuario_id) DO UPDATE
+		SET nota = EXCLUDED.nota, comentario = EXCLUDED.comentario, status = 'pendente',
+			motivo_rejeicao = NULL, moderado_por = NULL, moderado_em = NULL, atualizado_em = CURRENT_TIMESTAMP
+		RETURNING id, xmax = 0`, produtoID, usuarioID, req.Nota, req.Comentario).Scan(&avaliacaoID, &criada)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar avaliação", "detalhes": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if criada {
+		status = http.StatusCreated
+	}
+	avaliacao, err := scanAvaliacao(db.QueryRow(`SELECT `+colunasAvaliacao+` WHERE a.id = $1`, avaliacaoID))
+	if err != nil {
+		c.JSON(status, gin.H{"id": avaliacaoID, "mensagem": "Avaliação enviada para moderação"})
+		return
+	}
+	c.JSON(status, avaliacao)
+}
+
+// ListarAvaliacoesProduto devolve as avaliações aprovadas e o resumo das notas.
+// ?nota= filtra por nota; ?ordenar= aceita recentes (padrão), melhores e piores.
+func ListarAvaliacoesProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var existe bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM produtos WHERE id = $1)`, produtoID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+
+	ordens := map[string]string{
+		"recentes": "a.criado_em DESC, a.id DESC",
+		"melhores": "a.nota DESC, a.criado_em DESC, a.id DESC",
+		"piores":   "a.nota ASC, a.criado_em DESC, a.id DESC",
+	}
+	ordem, ok := ordens[c.DefaultQuery("ordenar", "recentes")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Ordenação inválida", "opcoes": []string{"recentes", "melhores", "piores"}})
+		return
+	}
+
+	resumo := models.ResumoAvaliacoes{Distribuicao: map[string]int{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}}
+	rows, err := db.Query(`
+		SELECT nota, COUNT(*) FROM avaliacoes
+		WHERE produto_id = $1 AND status = 'aprovada'
+		GROUP BY nota`, produtoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao resumir avaliações", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+	soma := 0
+	for rows.Next() {
+		var nota, total int
+		if err := rows.Scan(&nota, &total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao resumir avaliações", "detalhes": err.Error()})
+			return
+		}
+		resumo.Distribuicao[strconv.Itoa(nota)] = total
+		resumo.Total += total
+		soma += nota * total
+	}
+	if resumo.Total > 0 {
+		media := math.Round(float64(soma)/float64(resumo.Total)*100) / 100
+		resumo.Media = &media
+	}
+
+	filtro := ` WHERE a.produto_id = $1 AND a.status = 'aprovada'`
+	args := []interface{}{produtoID}
+	if texto := c.Query("nota"); texto != "" {
+		nota, err := strconv.Atoi(texto)
+		if err != nil || nota < 1 || nota > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "'nota' deve ser um número de 1 a 5"})
+			return
+		}
+		args = append(args, nota)
+		filtro += fmt.Sprintf(` AND a.nota = $%d`, len(args))
+	}
+
+	pagina, limite := paginacaoAvaliacoes(c)
+	avaliacoes, err := listarAvaliacoes(db, filtro, ordem, append(args, limite, (pagina-1)*limite)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar avaliações", "detalhes": err.Error()})
+		return
+	}
+	// A loja não expõe o ID do cliente; o status é sempre "aprovada".
+	for i := range avaliacoes {
+		avaliacoes[i].UsuarioID = 0
+		avaliacoes[i].Status = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"resumo":     resumo,
+		"avaliacoes": avaliacoes,
+		"pagina":     pagina,
+		"limite":     limite,
+	})
+}
+
+// ListarMinhasAvaliacoes inclui as avaliações pendentes e rejeitadas do cliente.
+func ListarMinhasAvaliacoes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes possuem avaliações"})
+		return
+	}
+
+	pagina, limite := paginacaoAvaliacoes(c)
+	avaliacoes, err := listarAvaliacoes(db, ` WHERE a.usuario_id = $1`, "a.criado_em DESC, a.id DESC", usuarioID, limite, (pagina-1)*limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar avaliações", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"avaliacoes": avaliacoes, "pagina": pagina, "limite": limite})
+}
+
+func DeletarMinhaAvaliacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes possuem avaliações"})
+		return
+	}
+
+	result, err := db.Exec(`DELETE FROM avaliacoes WHERE id = $1 AND usuario_id = $2`, c.Param("id"), usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir avaliação", "detalhes": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Avaliação não encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Avaliação excluída com sucesso"})
+}
+
+// ListarAvaliacoes é a fila de moderação: ?status= (pendente, aprovada,
+// rejeitada) e ?produto_id=; as mais antigas primeiro.
+func ListarAvaliacoes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	var clausulas []string
+	var args []interface{}
+	if status := c.Query("status"); status != "" {
+		if status != "pendente" && status != "aprovada" && status != "rejeitada" {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Status inválido", "opcoes": []string{"pendente", "aprovada", "rejeitada"}})
+			return
+		}
+		args = append(args, status)
+		clausulas = append(clausulas, fmt.Sprintf("a.status = $%d", len(args)))
+	}
+	if texto := c.Query("produto_id"); texto != "" {
+		produtoID, err := strconv.Atoi(texto)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "'produto_id' inválido"})
+			return
+		}
+		args = append(args, produtoID)
+		clausulas = append(clausulas, fmt.Sprintf("a.produto_id = $%d", len(args)))
+	}
+	filtro := ""
+	if len(clausulas) > 0 {
+		filtro = " WHERE " + strings.Join(clausulas, " AND ")
+	}
+
+	pagina, limite := paginacaoAvaliacoes(c)
+	avaliacoes, err := listarAvaliacoes(db, filtro, "a.criado_em ASC, a.id ASC", append(args, limite, (pagina-1)*limite)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar avaliações", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"avaliacoes": avaliacoes, "pagina": pagina, "limite": limite})
+}
+
+// ModerarAvaliacao aprova ou rejeita a avaliação e avisa o autor.
+func ModerarAvaliacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	avaliacaoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.ModerarAvaliacaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	req.Motivo = strings.TrimSpace(req.Motivo)
+	if req.Status == "rejeitada" && req.Motivo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Informe o motivo da rejeição"})
+		return
+	}
+	motivo := sql.NullString{String: req.Motivo, Valid: req.Status == "rejeitada"}
+
+	adminID, _ := obterAdminID(c)
+
+	var usuarioID, produtoID int
+	err = db.QueryRow(`
+		UPDATE avaliacoes
+		SET status = $1, motivo_rejeicao = $2, moderado_por = $3, moderado_em = $4, atualizado_em = $4
+		WHERE id = $5
+		RETURNING usuario_id, produto_id`, req.Status, motivo, adminID, time.Now(), avaliacaoID).Scan(&usuarioID, &produtoID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Avaliação não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao moderar avaliação", "detalhes": err.Error()})
+		return
+	}
+
+	titulo, mensagem := "Avaliação publicada", "Sua avaliação foi aprovada e já aparece na loja."
+	if req.Status == "rejeitada" {
+		titulo, mensagem = "Avaliação não publicada", "Sua avaliação não foi aprovada: "+req.Motivo
+	}
+	if err := criarNotificacao(db, "cliente", usuarioID, "avaliacao_moderada", titulo, mensagem,
+		gin.H{"avaliacao_id": avaliacaoID, "produto_id": produtoID, "status": req.Status}); err != nil {
+		log.Printf("ERRO BD: Falha ao notificar cliente %d sobre avaliação: %v", usuarioID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Avaliação moderada com sucesso", "status": req.Status})
+}
+
+// ResponderAvaliacao grava (ou substitui) a resposta pública da loja.
+func ResponderAvaliacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	avaliacaoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.ResponderAvaliacaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	req.Resposta = strings.TrimSpace(req.Resposta)
+	if req.Resposta == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "A resposta não pode ficar em branco"})
+		return
+	}
+
+	var usuarioID, produtoID int
+	err = db.QueryRow(`
+		UPDATE avaliacoes
+		SET resposta = $1, respondido_em = CURRENT_TIMESTAMP, atualizado_em = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING usuario_id, produto_id`, req.Resposta, avaliacaoID).Scan(&usuarioID, &produtoID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Avaliação não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao responder avaliação", "detalhes": err.Error()})
+		return
+	}
+
+	if err := criarNotificacao(db, "cliente", usuarioID, "avaliacao_respondida", "Sua avaliação foi respondida",
+		req.Resposta, gin.H{"avaliacao_id": avaliacaoID, "produto_id": produtoID}); err != nil {
+		log.Printf("ERRO BD: Falha ao notificar cliente %d sobre resposta: %v", usuarioID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Resposta registrada com sucesso"})
+}
+
+func DeletarAvaliacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	result, err := db.Exec(`DELETE FROM avaliacoes WHERE id = $1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir avaliação", "detalhes": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Avaliação não encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Avaliação excluída com sucesso"})
+}
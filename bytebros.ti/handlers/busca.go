@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fontesBusca descreve como cada tabela entra na busca unificada. As colunas
+// busca_documento e busca_texto são mantidas por gatilhos (ver database.go).
+var fontesBusca = map[string]string{
+	"produtos": `SELECT 'produto' AS tipo, p.id, p.nome AS titulo, COALESCE(NULLIF(p.detalhes, ''), p.nome) AS texto,
+		` + sqlPrecoEfetivoProduto + ` AS preco, ` + sqlProdutoEmOferta + ` AS oferta, p.imagem, p.busca_documento, p.busca_texto
+		FROM produtos p`,
+	"servicos": `SELECT 'servico', s.id, s.nome, s.detalhes, ` + sqlPrecoEfetivoServico + `, ` + sqlServicoEmOferta + `,
+		NULL::VARCHAR, s.busca_documento, s.busca_texto FROM servicos s`,
+	"noticias": `SELECT 'noticia', id, titulo, subtitulo || E'\n' || conteudo, NULL::DECIMAL, false, NULL::VARCHAR, busca_documento, busca_texto FROM noticias`,
+}
+
+var ordemFontesBusca = []string{"produtos", "servicos", "noticias"}
+
+// sqlEscaparHTML escapa &, <, >, " e ' de uma expressão de texto no próprio
+// banco, como html.EscapeString.
+func sqlEscaparHTML(expressao string) string {
+	return `replace(replace(replace(replace(replace(` + expressao +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+}
+
+// Buscar procura o termo em produtos, serviços e notícias ao mesmo tempo. A
+// busca textual usa stemming em português sem acentos; a semelhança por
+// trigramas do título cobre erros de digitação.
+func Buscar(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	termo := strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(termo) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Informe ao menos 2 caracteres em 'q'"})
+		return
+	}
+	if utf8.RuneCountInString(termo) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Termo de busca muito longo"})
+		return
+	}
+
+	tipos := ordemFontesBusca
+	if filtro := c.Query("tipos"); filtro != "" {
+		tipos = nil
+		vistos := map[string]bool{}
+		for _, tipo := range strings.Split(filtro, ",") {
+			tipo = strings.TrimSpace(tipo)
+			if _, ok := fontesBusca[tipo]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"erro": "Tipo de busca inválido: " + tipo, "tipos_validos": ordemFontesBusca})
+				return
+			}
+			if !vistos[tipo] {
+				vistos[tipo] = true
+				tipos = append(tipos, tipo)
+			}
+		}
+	}
+	fontes := make([]string, len(tipos))
+	for i, tipo := range tipos {
+		fontes[i] = fontesBusca[tipo]
+	}
+
+	limite := 20
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= 50 {
+		limite = v
+	}
+	pagina := 1
+	if v, err := strconv.Atoi(c.Query("pagina")); err == nil && v > 0 {
+		pagina = v
+	}
+
+	// O trecho destacado só é calculado para a página retornada. O texto é
+	// escapado antes do ts_headline para que o único HTML do trecho seja <mark>.
+	query := `
+		WITH consulta AS (
+			SELECT websearch_to_tsquery('portugues_sem_acento', $1) AS q, lower(unaccent($1)) AS t
+		), encontrados AS (
+			SELECT d.tipo, d.id, d.titulo, d.texto, d.preco, d.oferta, d.imagem, consulta.q,
+				ts_rank_cd(d.busca_documento, consulta.q) + word_similarity(consulta.t, d.busca_texto) * 0.5 AS relevancia
+			FROM (` + strings.Join(fontes, " UNION ALL ") + `) d, consulta
+			WHERE d.busca_documento @@ consulta.q OR consulta.t <% d.busca_texto
+		)
+		SELECT tipo, id, titulo,
+			ts_headline('portugues_sem_acento', ` + sqlEscaparHTML("texto") + `, q, 'StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" ... "'),
+			preco, oferta, imagem, relevancia, total
+		FROM (
+			SELECT *, COUNT(*) OVER () AS total
+			FROM encontrados
+			ORDER BY relevancia DESC, titulo
+			LIMIT $2 OFFSET $3
+		) pagina
+		ORDER BY relevancia DESC, titulo`
+
+	rows, err := db.Query(query, termo, limite, (pagina-1)*limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao realizar busca", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	total := 0
+	resultados := make([]models.ResultadoBusca, 0)
+	for rows.Next() {
+		var r models.ResultadoBusca
+		var preco sql.NullFloat64
+		var imagem sql.NullString
+		if err := rows.Scan(&r.Tipo, &r.ID, &r.Titulo, &r.Trecho, &preco, &r.Oferta, &imagem, &r.Relevancia, &total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler resultados da busca", "detalhes": err.Error()})
+			return
+		}
+		if preco.Valid {
+			r.Preco = &preco.Float64
+		}
+		r.Imagem = imagem.String
+		resultados = append(resultados, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"consulta":   termo,
+		"resultados": resultados,
+		"total":      total,
+		"pagina":     pagina,
+		"limite":     limite,
+	})
+}
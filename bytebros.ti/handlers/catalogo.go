@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// sqlQuantidadeVendida soma as unidades do produto p em pedidos não cancelados.
+const sqlQuantidadeVendida = `COALESCE((
+	SELECT SUM(pi.quantidade)
+	FROM pedido_itens pi
+	JOIN pedidos pe ON pe.id = pi.pedido_id
+	WHERE pi.produto_id = p.id AND LOWER(pe.status) <> 'cancelado'), 0)`
+
+// sqlProdutoEmEstoque considera o estoque do produto e o das variantes ativas.
+const sqlProdutoEmEstoque = `(p.quantidade > 0 OR EXISTS (
+	SELECT 1 FROM produto_variantes v WHERE v.produto_id = p.id AND v.ativo AND v.quantidade > 0))`
+
+// ordenacoesCatalogo define a coluna de ordenação de cada opção de ?ordenar=.
+// O ID do produto desempata e faz parte do cursor.
+var ordenacoesCatalogo = map[string]struct {
+	coluna    string
+	tipo      string
+	crescente bool
+}{
+	"nome":          {"nome", "TEXT", true},
+	"menor_preco":   {"preco_efetivo", "DECIMAL", true},
+	"maior_preco":   {"preco_efetivo", "DECIMAL", false},
+	"recentes":      {"criado_em", "TIMESTAMP", false},
+	"mais_vendidos": {"vendidos", "BIGINT", false},
+	"avaliacao":     {"avaliacao", "DECIMAL", false},
+}
+
+// cursorCatalogo aponta para o último produto entregue; o valor da coluna de
+// ordenação vai como texto e é convertido de volta no SQL.
+type cursorCatalogo struct {
+	Ordenar string `json:"o"`
+	Valor   string `json:"v"`
+	ID      int    `json:"id"`
+}
+
+func codificarCursor(cursor cursorCatalogo) string {
+	dados, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(dados)
+}
+
+func decodificarCursor(texto string) (cursorCatalogo, error) {
+	var cursor cursorCatalogo
+	dados, err := base64.RawURLEncoding.DecodeString(texto)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(dados, &cursor)
+	return cursor, err
+}
+
+// paginaCatalogo guarda o limite e o cursor de uma listagem paginada.
+type paginaCatalogo struct {
+	limite int
+	cursor *cursorCatalogo
+}
+
+// paginacaoCatalogo lê ?limite= e ?cursor=. Sem nenhum dos dois a listagem
+// não é paginada e retorna nil, mantendo a resposta antiga (lista completa).
+// Quando ok é false a resposta de erro já foi enviada.
+func paginacaoCatalogo(c *gin.Context, ordenar string) (pagina *paginaCatalogo, ok bool) {
+	textoLimite, temLimite := c.GetQuery("limite")
+	textoCursor, temCursor := c.GetQuery("cursor")
+	if !temLimite && !temCursor {
+		return nil, true
+	}
+
+	pagina = &paginaCatalogo{limite: 50}
+	if v, err := strconv.Atoi(textoLimite); err == nil && v > 0 && v <= 200 {
+		pagina.limite = v
+	}
+	if textoCursor != "" {
+		cursor, err := decodificarCursor(textoCursor)
+		if err != nil || cursor.Ordenar != ordenar {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Cursor inválido para esta ordenação"})
+			return nil, false
+		}
+		pagina.cursor = &cursor
+	}
+	return pagina, true
+}
+
+// cortarPaginaCatalogo recebe até limite+1 produtos com os valores da coluna de
+// ordenação: o excedente indica que existe próxima página, que começa depois
+// do último produto entregue.
+func cortarPaginaCatalogo(produtos []models.Produto, valores []string, limite int, ordenar string) ([]models.Produto, *string) {
+	if len(produtos) <= limite {
+		return produtos, nil
+	}
+	produtos = produtos[:limite]
+	cursor := codificarCursor(cursorCatalogo{Ordenar: ordenar, Valor: valores[limite-1], ID: produtos[limite-1].ID})
+	return produtos, &cursor
+}
+
+// filtrosCatalogo traduz os parâmetros de ListarProdutos em condições sobre o
+// alias p de produtos. Quando ok é false a resposta de erro já foi enviada.
+func filtrosCatalogo(c *gin.Context, db *sql.DB) (clausulas []string, args []interface{}, ok bool) {
+	proximo := func(valor interface{}) string {
+		args = append(args, valor)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if c.Query("ofertas") == "true" {
+		clausulas = append(clausulas, sqlProdutoEmOferta)
+	}
+
+	if c.Query("em_estoque") == "true" {
+		clausulas = append(clausulas, sqlProdutoEmEstoque)
+	}
+
+	// ?categoria= aceita ID ou slug e inclui os produtos das subcategorias.
+	if categoria := c.Query("categoria"); categoria != "" {
+		categoriaID, err := resolverCategoriaID(db, categoria)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"erro": "Categoria não encontrada"})
+				return nil, nil, false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categoria", "detalhes": err.Error()})
+			return nil, nil, false
+		}
+		subarvore := strings.Replace(sqlSubarvoreCategoria, "$1", proximo(categoriaID), 1)
+		clausulas = append(clausulas, `p.id IN (SELECT produto_id FROM produto_categorias WHERE categoria_id IN (`+subarvore+`))`)
+	}
+
+	for _, faixa := range []struct{ parametro, operador string }{{"preco_min", ">="}, {"preco_max", "<="}} {
+		texto := c.Query(faixa.parametro)
+		if texto == "" {
+			continue
+		}
+		valor, err := strconv.ParseFloat(strings.Replace(texto, ",", ".", 1), 64)
+		if err != nil || valor < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("'%s' deve ser um número positivo", faixa.parametro)})
+			return nil, nil, false
+		}
+		clausulas = append(clausulas, sqlPrecoEfetivoProduto+" "+faixa.operador+" "+proximo(valor))
+	}
+
+	// ?atributos[cor]=azul,preto filtra produtos com alguma variante ativa
+	// que tenha um dos valores; atributos diferentes se somam (E).
+	for chave, valores := range c.QueryMap("atributos") {
+		chave = strings.ToLower(strings.TrimSpace(chave))
+		var lista []string
+		for _, valor := range strings.Split(valores, ",") {
+			if valor = strings.ToLower(strings.TrimSpace(valor)); valor != "" {
+				lista = append(lista, valor)
+			}
+		}
+		if chave == "" || len(lista) == 0 {
+			continue
+		}
+		clausulas = append(clausulas, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM produto_variantes v
+			WHERE v.produto_id = p.id AND v.ativo AND LOWER(v.atributos->>%s) = ANY(%s))`,
+			proximo(chave), proximo(pq.Array(lista))))
+	}
+
+	if termo := strings.TrimSpace(c.Query("q")); termo != "" {
+		clausulas = append(clausulas, "p.busca_documento @@ websearch_to_tsquery('portugues_sem_acento', "+proximo(termo)+")")
+	}
+
+	return clausulas, args, true
+}
+
+func whereCatalogo(clausulas []string) string {
+	if len(clausulas) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(clausulas, " AND ")
+}
+
+// calcularFacetas conta, dentro do conjunto filtrado, quantos produtos há por
+// categoria, por valor de atributo, em estoque e em oferta, e a faixa de preço
+// efetivo.
+func calcularFacetas(db *sql.DB, clausulas []string, args []interface{}) (models.FacetasProdutos, error) {
+	facetas := models.FacetasProdutos{
+		Categorias: []models.FacetaCategoria{},
+		Atributos:  map[string][]models.FacetaValor{},
+	}
+	filtrados := `SELECT p.id FROM produtos p` + whereCatalogo(clausulas)
+
+	var minimo, maximo sql.NullFloat64
+	var total int
+	err := db.QueryRow(`
+		SELECT COUNT(*), MIN(preco_efetivo), MAX(preco_efetivo),
+			COUNT(*) FILTER (WHERE em_estoque), COUNT(*) FILTER (WHERE em_oferta)
+		FROM (
+			SELECT `+sqlPrecoEfetivoProduto+` AS preco_efetivo, `+sqlProdutoEmEstoque+` AS em_estoque,
+				`+sqlProdutoEmOferta+` AS em_oferta
+			FROM produtos p`+whereCatalogo(clausulas)+`
+		) filtrados`, args...).
+		Scan(&total, &minimo, &maximo, &facetas.Disponibilidade.EmEstoque, &facetas.Ofertas)
+	if err != nil {
+		return facetas, err
+	}
+	facetas.Disponibilidade.SemEstoque = total - facetas.Disponibilidade.EmEstoque
+	if minimo.Valid {
+		facetas.Preco.Minimo = &minimo.Float64
+		facetas.Preco.Maximo = &maximo.Float64
+	}
+
+	rows, err := db.Query(`
+		SELECT c.id, c.nome, c.slug, COUNT(*)
+		FROM produto_categorias pc
+		JOIN categorias c ON c.id = pc.categoria_id
+		WHERE pc.produto_id IN (`+filtrados+`)
+		GROUP BY c.id, c.nome, c.slug
+		ORDER BY c.nome`, args...)
+	if err != nil {
+		return facetas, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f models.FacetaCategoria
+		if err := rows.Scan(&f.ID, &f.Nome, &f.Slug, &f.Total); err != nil {
+			return facetas, err
+		}
+		facetas.Categorias = append(facetas.Categorias, f)
+	}
+	if err := rows.Err(); err != nil {
+		return facetas, err
+	}
+
+	atributoRows, err := db.Query(`
+		SELECT a.chave, a.valor, COUNT(DISTINCT v.produto_id)
+		FROM produto_variantes v, jsonb_each_text(v.atributos) AS a(chave, valor)
+		WHERE v.ativo AND v.produto_id IN (`+filtrados+`)
+		GROUP BY a.chave, a.valor
+		ORDER BY a.chave, a.valor`, args...)
+	if err != nil {
+		return facetas, err
+	}
+	defer atributoRows.Close()
+	for atributoRows.Next() {
+		var chave string
+		var f models.FacetaValor
+		if err := atributoRows.Scan(&chave, &f.Valor, &f.Total); err != nil {
+			return facetas, err
+		}
+		facetas.Atributos[chave] = append(facetas.Atributos[chave], f)
+	}
+	return facetas, atributoRows.Err()
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCursorCatalogoIdaEVolta(t *testing.T) {
+	cursores := []cursorCatalogo{
+		{Ordenar: "nome", Valor: "Mouse \"Gamer\" ção", ID: 7},
+		{Ordenar: "menor_preco", Valor: "19.90", ID: 1},
+		{Ordenar: "recentes", Valor: "2025-01-31 10:00:00.123456", ID: 42},
+	}
+	for _, cursor := range cursores {
+		lido, err := decodificarCursor(codificarCursor(cursor))
+		if err != nil {
+			t.Fatalf("decodificarCursor: %v", err)
+		}
+		if lido != cursor {
+			t.Errorf("cursor = %+v, esperado %+v", lido, cursor)
+		}
+	}
+}
+
+func TestPaginacaoCatalogo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cursorNome := codificarCursor(cursorCatalogo{Ordenar: "nome", Valor: "Mouse", ID: 3})
+
+	casos := []struct {
+		nome     string
+		consulta string
+		ok       bool
+		esperado *paginaCatalogo
+	}{
+		{"sem paginação", "", true, nil},
+		{"só outros parâmetros", "?ordenar=nome&ofertas=true", true, nil},
+		{"limite", "?limite=10", true, &paginaCatalogo{limite: 10}},
+		{"limite vazio usa o padrão", "?limite=", true, &paginaCatalogo{limite: 50}},
+		{"limite acima do máximo usa o padrão", "?limite=500", true, &paginaCatalogo{limite: 50}},
+		{"limite inválido usa o padrão", "?limite=abc", true, &paginaCatalogo{limite: 50}},
+		{"cursor sem limite", "?cursor=" + cursorNome, true, &paginaCatalogo{limite: 50, cursor: &cursorCatalogo{Ordenar: "nome", Valor: "Mouse", ID: 3}}},
+		{"cursor com limite", "?limite=2&cursor=" + cursorNome, true, &paginaCatalogo{limite: 2, cursor: &cursorCatalogo{Ordenar: "nome", Valor: "Mouse", ID: 3}}},
+		{"cursor vazio é a primeira página", "?cursor=", true, &paginaCatalogo{limite: 50}},
+		{"cursor corrompido", "?cursor=nao-e-base64!", false, nil},
+		{"cursor de outra ordenação", "?cursor=" + codificarCursor(cursorCatalogo{Ordenar: "menor_preco", Valor: "10", ID: 3}), false, nil},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/produtos"+caso.consulta, nil)
+
+			pagina, ok := paginacaoCatalogo(c, "nome")
+			if ok != caso.ok {
+				t.Fatalf("ok = %v, esperado %v", ok, caso.ok)
+			}
+			if !ok {
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, esperado %d", w.Code, http.StatusBadRequest)
+				}
+				return
+			}
+			if !reflect.DeepEqual(pagina, caso.esperado) {
+				t.Errorf("página = %+v, esperado %+v", pagina, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestCortarPaginaCatalogo(t *testing.T) {
+	produtos := []models.Produto{{ID: 4}, {ID: 9}, {ID: 2}}
+	valores := []string{"Cabo", "Mouse", "Teclado"}
+
+	pagina, proximo := cortarPaginaCatalogo(produtos, valores, 2, "nome")
+	if len(pagina) != 2 || pagina[1].ID != 9 {
+		t.Fatalf("página = %+v, esperado os dois primeiros produtos", pagina)
+	}
+	if proximo == nil {
+		t.Fatal("próximo cursor ausente com produtos sobrando")
+	}
+	cursor, err := decodificarCursor(*proximo)
+	if err != nil {
+		t.Fatalf("decodificarCursor: %v", err)
+	}
+	if esperado := (cursorCatalogo{Ordenar: "nome", Valor: "Mouse", ID: 9}); cursor != esperado {
+		t.Errorf("cursor = %+v, esperado %+v", cursor, esperado)
+	}
+
+	for _, limite := range []int{3, 10} {
+		pagina, proximo := cortarPaginaCatalogo(produtos, valores, limite, "nome")
+		if len(pagina) != len(produtos) || proximo != nil {
+			t.Errorf("limite %d: %d produtos e cursor %v, esperado a última página", limite, len(pagina), proximo)
+		}
+	}
+}
+
+// Ordenação e cursor inválidos são recusados antes de qualquer consulta.
+func TestListarProdutosRecusaCursorInvalido(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := sql.Open("sem-banco", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	roteador := gin.New()
+	roteador.Use(func(c *gin.Context) { c.Set("db", db) })
+	roteador.GET("/api/produtos", ListarProdutos)
+
+	consultas := []string{
+		"?ordenar=aleatorio",
+		"?cursor=nao-e-base64!",
+		"?ordenar=maior_preco&cursor=" + codificarCursor(cursorCatalogo{Ordenar: "nome", Valor: "Mouse", ID: 3}),
+	}
+	for _, consulta := range consultas {
+		w := httptest.NewRecorder()
+		roteador.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/produtos"+consulta, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, esperado %d; corpo: %s", consulta, w.Code, http.StatusBadRequest, w.Body.String())
+		}
+	}
+}
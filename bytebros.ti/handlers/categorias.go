@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var errCategoriaInexistente = errors.New("uma ou mais categorias não existem")
+
+const colunasCategoria = `c.id, c.nome, c.slug, c.descricao, c.categoria_pai_id, c.ordem, c.criado_em, c.atualizado_em,
+	(SELECT COUNT(*) FROM produto_categorias pc WHERE pc.categoria_id = c.id)`
+
+// sqlSubarvoreCategoria seleciona o ID da categoria $1 e de todos os seus descendentes.
+const sqlSubarvoreCategoria = `
+	WITH RECURSIVE subarvore AS (
+		SELECT id FROM categorias WHERE id = $1
+		UNION ALL
+		SELECT filha.id FROM categorias filha JOIN subarvore s ON filha.categoria_pai_id = s.id
+	)
+	SELECT id FROM subarvore`
+
+func scanCategoria(row linhaSQL) (models.Categoria, error) {
+	var cat models.Categoria
+	var descricao sql.NullString
+	var paiID sql.NullInt64
+	err := row.Scan(&cat.ID, &cat.Nome, &cat.Slug, &descricao, &paiID, &cat.Ordem, &cat.CriadoEm, &cat.AtualizadoEm, &cat.TotalProdutos)
+	cat.Descricao = descricao.String
+	if paiID.Valid {
+		id := int(paiID.Int64)
+		cat.CategoriaPaiID = &id
+	}
+	return cat, err
+}
+
+// resolverCategoriaID aceita o ID numérico ou o slug da categoria.
+func resolverCategoriaID(db *sql.DB, identificador string) (int, error) {
+	if id, err := strconv.Atoi(identificador); err == nil {
+		var existe bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM categorias WHERE id = $1)`, id).Scan(&existe); err != nil {
+			return 0, err
+		}
+		if !existe {
+			return 0, sql.ErrNoRows
+		}
+		return id, nil
+	}
+	var id int
+	err := db.QueryRow(`SELECT id FROM categorias WHERE slug = $1`, identificador).Scan(&id)
+	return id, err
+}
+
+// montarArvoreCategorias organiza a lista plana em árvore, respeitando a ordem
+// definida (ordem, nome) em cada nível.
+func montarArvoreCategorias(lista []models.Categoria) []models.Categoria {
+	filhos := map[int][]models.Categoria{}
+	raizes := []models.Categoria{}
+	ids := map[int]bool{}
+	for _, cat := range lista {
+		ids[cat.ID] = true
+	}
+	for _, cat := range lista {
+		if cat.CategoriaPaiID != nil && ids[*cat.CategoriaPaiID] {
+			filhos[*cat.CategoriaPaiID] = append(filhos[*cat.CategoriaPaiID], cat)
+		} else {
+			raizes = append(raizes, cat)
+		}
+	}
+
+	var preencher func(nivel []models.Categoria) []models.Categoria
+	preencher = func(nivel []models.Categoria) []models.Categoria {
+		sort.SliceStable(nivel, func(i, j int) bool {
+			if nivel[i].Ordem != nivel[j].Ordem {
+				return nivel[i].Ordem < nivel[j].Ordem
+			}
+			return nivel[i].Nome < nivel[j].Nome
+		})
+		for i := range nivel {
+			if sub, ok := filhos[nivel[i].ID]; ok {
+				nivel[i].Subcategorias = preencher(sub)
+			}
+		}
+		return nivel
+	}
+	return preencher(raizes)
+}
+
+func ListarCategorias(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	rows, err := db.Query(`SELECT ` + colunasCategoria + ` FROM categorias c ORDER BY c.ordem, c.nome`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categorias", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	categorias := make([]models.Categoria, 0)
+	for rows.Next() {
+		cat, err := scanCategoria(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler categorias", "detalhes": err.Error()})
+			return
+		}
+		categorias = append(categorias, cat)
+	}
+
+	if c.Query("formato") == "lista" {
+		c.JSON(http.StatusOK, categorias)
+		return
+	}
+	c.JSON(http.StatusOK, montarArvoreCategorias(categorias))
+}
+
+func ObterCategoria(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	id, err := resolverCategoriaID(db, c.Param("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Categoria não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categoria", "detalhes": err.Error()})
+		return
+	}
+
+	rows, err := db.Query(`SELECT `+colunasCategoria+` FROM categorias c WHERE c.id IN (`+sqlSubarvoreCategoria+`)`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categoria", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	subarvore := []models.Categoria{}
+	for rows.Next() {
+		cat, err := scanCategoria(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler categoria", "detalhes": err.Error()})
+			return
+		}
+		subarvore = append(subarvore, cat)
+	}
+
+	// A própria categoria é a única raiz da subárvore.
+	var categoria models.Categoria
+	for _, cat := range montarArvoreCategorias(subarvore) {
+		if cat.ID == id {
+			categoria = cat
+		}
+	}
+
+	caminhoRows, err := db.Query(`
+		WITH RECURSIVE ancestrais AS (
+			SELECT id, nome, slug, categoria_pai_id, 0 AS nivel FROM categorias WHERE id = $1
+			UNION ALL
+			SELECT pai.id, pai.nome, pai.slug, pai.categoria_pai_id, a.nivel + 1
+			FROM categorias pai JOIN ancestrais a ON pai.id = a.categoria_pai_id
+		)
+		SELECT id, nome, slug FROM ancestrais ORDER BY nivel DESC`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar caminho da categoria", "detalhes": err.Error()})
+		return
+	}
+	defer caminhoRows.Close()
+	for caminhoRows.Next() {
+		var r models.CategoriaResumo
+		if err := caminhoRows.Scan(&r.ID, &r.Nome, &r.Slug); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler caminho da categoria", "detalhes": err.Error()})
+			return
+		}
+		categoria.Caminho = append(categoria.Caminho, r)
+	}
+
+	c.JSON(http.StatusOK, categoria)
+}
+
+// validarCategoriaPai confere se o pai existe e, em atualizações, se não é a
+// própria categoria nem um de seus descendentes (o que criaria um ciclo).
+func validarCategoriaPai(tx *sql.Tx, categoriaID int, paiID *int) (string, error) {
+	if paiID == nil {
+		return "", nil
+	}
+	var existe bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM categorias WHERE id = $1)`, *paiID).Scan(&existe); err != nil {
+		return "", err
+	}
+	if !existe {
+		return "Categoria pai não encontrada", nil
+	}
+	if categoriaID == 0 {
+		return "", nil
+	}
+
+	var ciclo bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM (`+sqlSubarvoreCategoria+`) s WHERE s.id = $2)`, categoriaID, *paiID).Scan(&ciclo); err != nil {
+		return "", err
+	}
+	if ciclo {
+		return "A categoria pai não pode ser a própria categoria nem uma de suas subcategorias", nil
+	}
+	return "", nil
+}
+
+func CriarCategoria(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	var req models.CategoriaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	if problema, err := validarCategoriaPai(tx, 0, req.CategoriaPaiID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao validar categoria pai", "detalhes": err.Error()})
+		return
+	} else if problema != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": problema})
+		return
+	}
+
+	base := gerarSlug(req.Slug)
+	if base == "" {
+		base = gerarSlug(req.Nome)
+	}
+	slug, err := gerarSlugUnico(tx, "categorias", base, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+		return
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO categorias (nome, slug, descricao, categoria_pai_id, ordem)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		req.Nome, slug, req.Descricao, req.CategoriaPaiID, req.Ordem).Scan(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criar categoria", "detalhes": err.Error()})
+		return
+	}
+
+	categoria, err := scanCategoria(tx.QueryRow(`SELECT `+colunasCategoria+` FROM categorias c WHERE c.id = $1`, id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler categoria criada", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar categoria"})
+		return
+	}
+	c.JSON(http.StatusCreated, categoria)
+}
+
+func AtualizarCategoria(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.CategoriaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var slugAtual string
+	if err := tx.QueryRow(`SELECT slug FROM categorias WHERE id = $1 FOR UPDATE`, id).Scan(&slugAtual); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Categoria não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categoria", "detalhes": err.Error()})
+		return
+	}
+
+	if problema, err := validarCategoriaPai(tx, id, req.CategoriaPaiID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao validar categoria pai", "detalhes": err.Error()})
+		return
+	} else if problema != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": problema})
+		return
+	}
+
+	// O slug só muda quando enviado explicitamente, para não quebrar links.
+	slug := slugAtual
+	if base := gerarSlug(req.Slug); base != "" && base != slugAtual {
+		if slug, err = gerarSlugUnico(tx, "categorias", base, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE categorias
+		SET nome = $1, slug = $2, descricao = $3, categoria_pai_id = $4, ordem = $5, atualizado_em = CURRENT_TIMESTAMP
+		WHERE id = $6`,
+		req.Nome, slug, req.Descricao, req.CategoriaPaiID, req.Ordem, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar categoria", "detalhes": err.Error()})
+		return
+	}
+
+	categoria, err := scanCategoria(tx.QueryRow(`SELECT `+colunasCategoria+` FROM categorias c WHERE c.id = $1`, id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler categoria", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar categoria"})
+		return
+	}
+	c.JSON(http.StatusOK, categoria)
+}
+
+func DeletarCategoria(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var subcategorias int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM categorias WHERE categoria_pai_id = $1`, id).Scan(&subcategorias); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar subcategorias", "detalhes": err.Error()})
+		return
+	}
+	if subcategorias > 0 {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Remova ou mova as subcategorias antes de excluir a categoria"})
+		return
+	}
+
+	result, err := db.Exec(`DELETE FROM categorias WHERE id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir categoria", "detalhes": err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Categoria não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Categoria excluída com sucesso"})
+}
+
+// definirCategoriasProduto substitui os vínculos do produto pelas categorias informadas.
+func definirCategoriasProduto(tx *sql.Tx, produtoID int, categoriaIDs []int) error {
+	if len(categoriaIDs) > 0 {
+		var encontradas int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM categorias WHERE id = ANY($1)`, pq.Array(categoriaIDs)).Scan(&encontradas); err != nil {
+			return err
+		}
+		unicas := map[int]bool{}
+		for _, id := range categoriaIDs {
+			unicas[id] = true
+		}
+		if encontradas != len(unicas) {
+			return errCategoriaInexistente
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM produto_categorias WHERE produto_id = $1`, produtoID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO produto_categorias (produto_id, categoria_id)
+		SELECT $1, UNNEST($2::int[])
+		ON CONFLICT DO NOTHING`, produtoID, pq.Array(categoriaIDs))
+	return err
+}
+
+// carregarCategoriasProdutos busca, em uma única consulta, as categorias de vários produtos.
+func carregarCategoriasProdutos(db *sql.DB, produtoIDs []int) (map[int][]models.CategoriaResumo, error) {
+	resultado := map[int][]models.CategoriaResumo{}
+	if len(produtoIDs) == 0 {
+		return resultado, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT pc.produto_id, c.id, c.nome, c.slug
+		FROM produto_categorias pc
+		JOIN categorias c ON c.id = pc.categoria_id
+		WHERE pc.produto_id = ANY($1)
+		ORDER BY c.ordem, c.nome`, pq.Array(produtoIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var produtoID int
+		var r models.CategoriaResumo
+		if err := rows.Scan(&produtoID, &r.ID, &r.Nome, &r.Slug); err != nil {
+			return nil, err
+		}
+		resultado[produtoID] = append(resultado[produtoID], r)
+	}
+	return resultado, rows.Err()
+}
+
+func DefinirCategoriasProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.DefinirCategoriasProdutoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var existe bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM produtos WHERE id = $1)`, produtoID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+
+	if err := definirCategoriasProduto(tx, produtoID, req.CategoriaIDs); err != nil {
+		if err == errCategoriaInexistente {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Uma ou mais categorias não existem"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao vincular categorias", "detalhes": err.Error()})
+		return
+	}
+	if !conferirEspecificacoesProduto(c, tx, produtoID) {
+		return
+	}
+
+	// Promoções por categoria podem mudar o preço efetivo.
+	if err := registrarHistoricoPrecos(tx, "edicao", []int{produtoID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar histórico de preços", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar categorias do produto"})
+		return
+	}
+
+	categorias, err := carregarCategoriasProdutos(db, []int{produtoID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler categorias do produto", "detalhes": err.Error()})
+		return
+	}
+	lista := categorias[produtoID]
+	if lista == nil {
+		lista = []models.CategoriaResumo{}
+	}
+	c.JSON(http.StatusOK, gin.H{"produto_id": produtoID, "categorias": lista})
+}
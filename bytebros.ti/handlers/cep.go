@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errCEPNaoEncontrado = errors.New("CEP não encontrado")
+
+// ProvedorCEP consulta um CEP (apenas dígitos) em uma fonte externa.
+type ProvedorCEP interface {
+	Nome() string
+	Consultar(ctx context.Context, cep string) (models.EnderecoCEP, error)
+}
+
+// provedorViaCEP consulta a API pública do ViaCEP (ou outra com o mesmo formato,
+// configurada em VIACEP_URL).
+type provedorViaCEP struct {
+	baseURL string
+	cliente *http.Client
+}
+
+func (p *provedorViaCEP) Nome() string { return "viacep" }
+
+func (p *provedorViaCEP) Consultar(ctx context.Context, cep string) (models.EnderecoCEP, error) {
+	var endereco models.EnderecoCEP
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/ws/%s/json/", p.baseURL, cep), nil)
+	if err != nil {
+		return endereco, err
+	}
+	resp, err := p.cliente.Do(req)
+	if err != nil {
+		return endereco, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusNotFound {
+		return endereco, errCEPNaoEncontrado
+	}
+	if resp.StatusCode != http.StatusOK {
+		return endereco, fmt.Errorf("ViaCEP retornou status %d", resp.StatusCode)
+	}
+
+	var corpo struct {
+		CEP         string      `json:"cep"`
+		Logradouro  string      `json:"logradouro"`
+		Complemento string      `json:"complemento"`
+		Bairro      string      `json:"bairro"`
+		Localidade  string      `json:"localidade"`
+		UF          string      `json:"uf"`
+		IBGE        string      `json:"ibge"`
+		Erro        interface{} `json:"erro"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&corpo); err != nil {
+		return endereco, fmt.Errorf("resposta inválida do ViaCEP: %w", err)
+	}
+	// O ViaCEP responde 200 com {"erro": true} (ou "true") para CEPs inexistentes.
+	if corpo.Erro != nil && corpo.Erro != false && corpo.Erro != "false" {
+		return endereco, errCEPNaoEncontrado
+	}
+
+	endereco = models.EnderecoCEP{
+		CEP:         somenteDigitos(corpo.CEP),
+		Logradouro:  corpo.Logradouro,
+		Complemento: corpo.Complemento,
+		Bairro:      corpo.Bairro,
+		Cidade:      corpo.Localidade,
+		Estado:      corpo.UF,
+		IBGE:        corpo.IBGE,
+	}
+	return endereco, nil
+}
+
+//go:embed dados/ceps.json
+var cepsLocaisJSON []byte
+
+// provedorCEPLocal responde a partir de uma lista fixa de CEPs embutida no
+// binário (ou do arquivo em CEP_ARQUIVO_LOCAL). Útil em desenvolvimento e testes,
+// sem acesso à internet.
+type provedorCEPLocal struct {
+	ceps map[string]models.EnderecoCEP
+}
+
+func novoProvedorCEPLocal(dados []byte) (*provedorCEPLocal, error) {
+	var lista []models.EnderecoCEP
+	if err := json.Unmarshal(dados, &lista); err != nil {
+		return nil, err
+	}
+	p := &provedorCEPLocal{ceps: make(map[string]models.EnderecoCEP, len(lista))}
+	for _, e := range lista {
+		e.CEP = somenteDigitos(e.CEP)
+		p.ceps[e.CEP] = e
+	}
+	return p, nil
+}
+
+func (p *provedorCEPLocal) Nome() string { return "local" }
+
+func (p *provedorCEPLocal) Consultar(ctx context.Context, cep string) (models.EnderecoCEP, error) {
+	endereco, ok := p.ceps[cep]
+	if !ok {
+		return models.EnderecoCEP{}, errCEPNaoEncontrado
+	}
+	return endereco, nil
+}
+
+var (
+	provedorCEPOnce  sync.Once
+	provedorCEPAtual ProvedorCEP
+)
+
+// obterProvedorCEP escolhe o provedor por CEP_PROVEDOR ("viacep", padrão, ou "local").
+func obterProvedorCEP() ProvedorCEP {
+	provedorCEPOnce.Do(func() {
+		if strings.ToLower(os.Getenv("CEP_PROVEDOR")) == "local" {
+			dados := cepsLocaisJSON
+			if arquivo := os.Getenv("CEP_ARQUIVO_LOCAL"); arquivo != "" {
+				conteudo, err := os.ReadFile(arquivo)
+				if err != nil {
+					log.Printf("AVISO: Falha ao ler CEP_ARQUIVO_LOCAL (%s), usando a lista embutida: %v", arquivo, err)
+				} else {
+					dados = conteudo
+				}
+			}
+			local, err := novoProvedorCEPLocal(dados)
+			if err == nil {
+				provedorCEPAtual = local
+				return
+			}
+			log.Printf("AVISO: Lista local de CEPs inválida, usando ViaCEP: %v", err)
+		}
+
+		baseURL := os.Getenv("VIACEP_URL")
+		if baseURL == "" {
+			baseURL = "https://viacep.com.br"
+		}
+		provedorCEPAtual = &provedorViaCEP{
+			baseURL: strings.TrimSuffix(baseURL, "/"),
+			cliente: &http.Client{Timeout: 5 * time.Second},
+		}
+	})
+	return provedorCEPAtual
+}
+
+// validadeCacheCEP lê CEP_CACHE_DIAS (padrão 30). CEPs inexistentes ficam em
+// cache por apenas um dia, pois os Correios criam CEPs novos com frequência.
+func validadeCacheCEP(encontrado bool) time.Duration {
+	if !encontrado {
+		return 24 * time.Hour
+	}
+	dias := 30
+	if v, err := strconv.Atoi(os.Getenv("CEP_CACHE_DIAS")); err == nil && v > 0 {
+		dias = v
+	}
+	return time.Duration(dias) * 24 * time.Hour
+}
+
+// consultarCEP devolve o endereço do CEP usando o cache em cep_cache e, quando
+// necessário, o provedor configurado. Retorna errCEPNaoEncontrado para CEPs inexistentes.
+func consultarCEP(ctx context.Context, db *sql.DB, cep string) (models.EnderecoCEP, error) {
+	var endereco models.EnderecoCEP
+	var encontrado bool
+	var atualizadoEm time.Time
+	var logradouro, complemento, bairro, cidade, estado, ibge sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT encontrado, logradouro, complemento, bairro, cidade, estado, ibge, atualizado_em
+		FROM cep_cache
+		WHERE cep = $1`, cep).
+		Scan(&encontrado, &logradouro, &complemento, &bairro, &cidade, &estado, &ibge, &atualizadoEm)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("ERRO BD: Falha ao ler cache de CEP %s: %v", cep, err)
+	}
+	if err == nil && time.Since(atualizadoEm) < validadeCacheCEP(encontrado) {
+		if !encontrado {
+			return endereco, errCEPNaoEncontrado
+		}
+		return models.EnderecoCEP{
+			CEP:         cep,
+			Logradouro:  logradouro.String,
+			Complemento: complemento.String,
+			Bairro:      bairro.String,
+			Cidade:      cidade.String,
+			Estado:      estado.String,
+			IBGE:        ibge.String,
+			Fonte:       "cache",
+		}, nil
+	}
+
+	provedor := obterProvedorCEP()
+	endereco, err = provedor.Consultar(ctx, cep)
+	if err != nil && err != errCEPNaoEncontrado {
+		return endereco, err
+	}
+	encontrado = err == nil
+	endereco.CEP = cep
+	endereco.Fonte = provedor.Nome()
+
+	_, errCache := db.ExecContext(ctx, `
+		INSERT INTO cep_cache (cep, encontrado, logradouro, complemento, bairro, cidade, estado, ibge, provedor, atualizado_em)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (cep) DO UPDATE SET
+			encontrado = EXCLUDED.encontrado, logradouro = EXCLUDED.logradouro, complemento = EXCLUDED.complemento,
+			bairro = EXCLUDED.bairro, cidade = EXCLUDED.cidade, estado = EXCLUDED.estado, ibge = EXCLUDED.ibge,
+			provedor = EXCLUDED.provedor, atualizado_em = EXCLUDED.atualizado_em`,
+		cep, encontrado, endereco.Logradouro, endereco.Complemento, endereco.Bairro, endereco.Cidade, endereco.Estado, endereco.IBGE, provedor.Nome(), time.Now())
+	if errCache != nil {
+		log.Printf("ERRO BD: Falha ao gravar cache de CEP %s: %v", cep, errCache)
+	}
+
+	if !encontrado {
+		return endereco, errCEPNaoEncontrado
+	}
+	return endereco, nil
+}
+
+func ConsultarCEP(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	cep := somenteDigitos(c.Param("cep"))
+	if len(cep) != 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "CEP deve conter 8 dígitos"})
+		return
+	}
+
+	endereco, err := consultarCEP(c.Request.Context(), db, cep)
+	if err != nil {
+		if err == errCEPNaoEncontrado {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "CEP não encontrado"})
+			return
+		}
+		log.Printf("ERRO: Falha ao consultar CEP %s: %v", cep, err)
+		c.JSON(http.StatusBadGateway, gin.H{"erro": "Serviço de CEP indisponível no momento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, endereco)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// driverSemBanco recusa toda consulta: o cache de CEP falha (e só registra
+// log) e a consulta segue para o provedor.
+type driverSemBanco struct{}
+
+func (driverSemBanco) Open(string) (driver.Conn, error) {
+	return nil, errors.New("sem banco nos testes")
+}
+
+func init() { sql.Register("sem-banco", driverSemBanco{}) }
+
+// provedorCEPIndisponivel simula a fonte externa fora do ar.
+type provedorCEPIndisponivel struct{}
+
+func (provedorCEPIndisponivel) Nome() string { return "indisponivel" }
+
+func (provedorCEPIndisponivel) Consultar(context.Context, string) (models.EnderecoCEP, error) {
+	return models.EnderecoCEP{}, errors.New("conexão recusada")
+}
+
+// usarProvedorCEP troca o provedor escolhido por obterProvedorCEP durante o teste.
+func usarProvedorCEP(t *testing.T, provedor ProvedorCEP) {
+	t.Helper()
+	provedorCEPOnce.Do(func() {})
+	anterior := provedorCEPAtual
+	provedorCEPAtual = provedor
+	t.Cleanup(func() { provedorCEPAtual = anterior })
+}
+
+func TestConsultarCEP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	local, err := novoProvedorCEPLocal([]byte(`[{"cep": "01310-100", "logradouro": "Avenida Paulista", "bairro": "Bela Vista", "cidade": "São Paulo", "estado": "SP", "ibge": "3550308"}]`))
+	if err != nil {
+		t.Fatalf("novoProvedorCEPLocal: %v", err)
+	}
+	db, err := sql.Open("sem-banco", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	roteador := gin.New()
+	roteador.Use(func(c *gin.Context) { c.Set("db", db) })
+	roteador.GET("/api/cep/:cep", ConsultarCEP)
+
+	casos := []struct {
+		nome     string
+		provedor ProvedorCEP
+		cep      string
+		status   int
+		esperado models.EnderecoCEP
+	}{
+		{"encontrado", local, "01310100", http.StatusOK, models.EnderecoCEP{
+			CEP: "01310100", Logradouro: "Avenida Paulista", Bairro: "Bela Vista", Cidade: "São Paulo", Estado: "SP", IBGE: "3550308", Fonte: "local",
+		}},
+		{"encontrado com máscara", local, "01310-100", http.StatusOK, models.EnderecoCEP{
+			CEP: "01310100", Logradouro: "Avenida Paulista", Bairro: "Bela Vista", Cidade: "São Paulo", Estado: "SP", IBGE: "3550308", Fonte: "local",
+		}},
+		{"desconhecido", local, "99999999", http.StatusNotFound, models.EnderecoCEP{}},
+		{"curto demais", local, "0131010", http.StatusBadRequest, models.EnderecoCEP{}},
+		{"longo demais", local, "013101000", http.StatusBadRequest, models.EnderecoCEP{}},
+		{"sem dígitos", local, "abcdefgh", http.StatusBadRequest, models.EnderecoCEP{}},
+		{"provedor fora do ar", provedorCEPIndisponivel{}, "01310100", http.StatusBadGateway, models.EnderecoCEP{}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			usarProvedorCEP(t, caso.provedor)
+			w := httptest.NewRecorder()
+			roteador.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/cep/"+caso.cep, nil))
+
+			if w.Code != caso.status {
+				t.Fatalf("status = %d, esperado %d; corpo: %s", w.Code, caso.status, w.Body.String())
+			}
+			if caso.status != http.StatusOK {
+				return
+			}
+			var endereco models.EnderecoCEP
+			if err := json.Unmarshal(w.Body.Bytes(), &endereco); err != nil {
+				t.Fatalf("resposta inválida: %v", err)
+			}
+			if endereco != caso.esperado {
+				t.Errorf("endereço = %+v, esperado %+v", endereco, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestProvedorViaCEP(t *testing.T) {
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws/01310100/json/":
+			w.Write([]byte(`{"cep": "01310-100", "logradouro": "Avenida Paulista", "bairro": "Bela Vista", "localidade": "São Paulo", "uf": "SP", "ibge": "3550308"}`))
+		case "/ws/99999999/json/":
+			w.Write([]byte(`{"erro": "true"}`))
+		case "/ws/00000000/json/":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer servidor.Close()
+	provedor := &provedorViaCEP{baseURL: servidor.URL, cliente: servidor.Client()}
+	ctx := context.Background()
+
+	endereco, err := provedor.Consultar(ctx, "01310100")
+	if err != nil {
+		t.Fatalf("Consultar: %v", err)
+	}
+	if endereco.CEP != "01310100" || endereco.Cidade != "São Paulo" || endereco.Estado != "SP" {
+		t.Errorf("endereço = %+v", endereco)
+	}
+	for _, cep := range []string{"99999999", "00000000"} {
+		if _, err := provedor.Consultar(ctx, cep); err != errCEPNaoEncontrado {
+			t.Errorf("CEP %s: erro %v, esperado %v", cep, err, errCEPNaoEncontrado)
+		}
+	}
+	if _, err := provedor.Consultar(ctx, "11111111"); err == nil || err == errCEPNaoEncontrado {
+		t.Errorf("falha do ViaCEP: erro %v, esperado erro de indisponibilidade", err)
+	}
+}
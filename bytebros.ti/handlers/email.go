@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer envia emails em texto simples. Sem SMTP_HOST configurado, as
+// mensagens apenas vão para o log (útil em desenvolvimento).
+type Mailer interface {
+	Nome() string
+	Enviar(ctx context.Context, destinatarios []string, assunto, corpo string) error
+}
+
+var (
+	mailerOnce  sync.Once
+	mailerAtual Mailer
+)
+
+func obterMailer() Mailer {
+	mailerOnce.Do(func() {
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			mailerAtual = mailerLog{}
+			return
+		}
+		porta := os.Getenv("SMTP_PORTA")
+		if porta == "" {
+			porta = "587"
+		}
+		remetente := os.Getenv("SMTP_REMETENTE")
+		if remetente == "" {
+			remetente = os.Getenv("SMTP_USUARIO")
+		}
+		mailerAtual = &mailerSMTP{
+			endereco:  net.JoinHostPort(host, porta),
+			host:      host,
+			usuario:   os.Getenv("SMTP_USUARIO"),
+			senha:     os.Getenv("SMTP_SENHA"),
+			remetente: remetente,
+		}
+	})
+	return mailerAtual
+}
+
+type mailerLog struct{}
+
+func (mailerLog) Nome() string { return "log" }
+
+func (mailerLog) Enviar(ctx context.Context, destinatarios []string, assunto, corpo string) error {
+	log.Printf("EMAIL (não enviado, SMTP_HOST ausente) para %s: %s\n%s", strings.Join(destinatarios, ", "), assunto, corpo)
+	return nil
+}
+
+type mailerSMTP struct {
+	endereco  string
+	host      string
+	usuario   string
+	senha     string
+	remetente string
+}
+
+func (m *mailerSMTP) Nome() string { return "smtp" }
+
+func (m *mailerSMTP) Enviar(ctx context.Context, destinatarios []string, assunto, corpo string) error {
+	if len(destinatarios) == 0 {
+		return nil
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.remetente)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(destinatarios, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", assunto))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&msg)
+	qp.Write([]byte(strings.ReplaceAll(corpo, "\n", "\r\n")))
+	qp.Close()
+
+	var auth smtp.Auth
+	if m.usuario != "" {
+		auth = smtp.PlainAuth("", m.usuario, m.senha, m.host)
+	}
+
+	// smtp.SendMail não aceita contexto; o envio roda à parte e a espera
+	// respeita o cancelamento.
+	resultado := make(chan error, 1)
+	go func() {
+		resultado <- smtp.SendMail(m.endereco, auth, m.remetente, destinatarios, msg.Bytes())
+	}()
+	select {
+	case err := <-resultado:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
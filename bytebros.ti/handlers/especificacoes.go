@@ -0,0 +1,636 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var padraoChaveEspecificacao = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+const colunasEspecificacao = `e.id, e.categoria_id, e.chave, e.nome, e.tipo, COALESCE(e.unidade, ''), e.opcoes, e.obrigatoria, e.ordem`
+
+// sqlEspecificacoesHerdadas seleciona as especificações das categorias
+// listadas em %s e de todos os seus ancestrais.
+const sqlEspecificacoesHerdadas = `
+	WITH RECURSIVE ancestrais AS (
+		SELECT id, categoria_pai_id FROM categorias WHERE id IN (%s)
+		UNION
+		SELECT pai.id, pai.categoria_pai_id FROM categorias pai JOIN ancestrais a ON pai.id = a.categoria_pai_id
+	)
+	SELECT ` + colunasEspecificacao + `
+	FROM categoria_especificacoes e
+	WHERE e.categoria_id IN (SELECT id FROM ancestrais)
+	ORDER BY e.ordem, e.nome, e.id`
+
+// sqlCategoriasDosProdutos serve de semente para sqlEspecificacoesHerdadas a
+// partir de uma lista de produtos ($1).
+const sqlCategoriasDosProdutos = `SELECT categoria_id FROM produto_categorias WHERE produto_id = ANY($1)`
+
+// sqlConflitoChaveEspecificacao verifica se a chave $2 já está definida na
+// categoria $1, em um ancestral ou em um descendente (exceto a especificação $3).
+const sqlConflitoChaveEspecificacao = `
+	SELECT EXISTS (
+		SELECT 1 FROM categoria_especificacoes e
+		WHERE e.chave = $2 AND e.id <> $3 AND (
+			e.categoria_id IN (` + sqlSubarvoreCategoria + `) OR
+			e.categoria_id IN (
+				WITH RECURSIVE ancestrais AS (
+					SELECT id, categoria_pai_id FROM categorias WHERE id = $1
+					UNION ALL
+					SELECT pai.id, pai.categoria_pai_id FROM categorias pai JOIN ancestrais a ON pai.id = a.categoria_pai_id
+				)
+				SELECT id FROM ancestrais)))`
+
+type consultaSQL interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// carregarEspecificacoes devolve as definições aplicáveis às categorias de
+// sementes, uma por chave.
+func carregarEspecificacoes(db consultaSQL, sementes string, args ...interface{}) ([]models.Especificacao, error) {
+	rows, err := db.Query(fmt.Sprintf(sqlEspecificacoesHerdadas, sementes), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []models.Especificacao{}
+	vistas := map[string]bool{}
+	for rows.Next() {
+		var e models.Especificacao
+		if err := rows.Scan(&e.ID, &e.CategoriaID, &e.Chave, &e.Nome, &e.Tipo, &e.Unidade, pq.Array(&e.Opcoes), &e.Obrigatoria, &e.Ordem); err != nil {
+			return nil, err
+		}
+		// Categorias sem parentesco podem repetir a chave; vale a primeira.
+		if vistas[e.Chave] {
+			continue
+		}
+		vistas[e.Chave] = true
+		if e.Tipo != "opcao" {
+			e.Opcoes = nil
+		}
+		lista = append(lista, e)
+	}
+	return lista, rows.Err()
+}
+
+// validarEspecificacoes confere os valores informados contra as definições e
+// devolve os valores normalizados. Valores nulos ou textos vazios equivalem a
+// não informar a especificação.
+func validarEspecificacoes(definicoes []models.Especificacao, valores map[string]interface{}) (map[string]interface{}, []string) {
+	porChave := map[string]models.Especificacao{}
+	for _, e := range definicoes {
+		porChave[e.Chave] = e
+	}
+
+	normalizados := map[string]interface{}{}
+	var problemas []string
+	comProblema := map[string]bool{}
+	registrar := func(chave, mensagem string) {
+		comProblema[chave] = true
+		problemas = append(problemas, fmt.Sprintf("'%s' %s", chave, mensagem))
+	}
+	for chave, valor := range valores {
+		e, ok := porChave[chave]
+		if !ok {
+			registrar(chave, "não é uma especificação das categorias do produto")
+			continue
+		}
+		if valor == nil {
+			continue
+		}
+
+		switch e.Tipo {
+		case "numero":
+			numero, ok := valor.(float64)
+			if !ok {
+				registrar(chave, "deve ser um número")
+				continue
+			}
+			normalizados[chave] = numero
+		case "booleano":
+			booleano, ok := valor.(bool)
+			if !ok {
+				registrar(chave, "deve ser verdadeiro ou falso")
+				continue
+			}
+			normalizados[chave] = booleano
+		case "opcao":
+			texto, ok := valor.(string)
+			if texto = strings.TrimSpace(texto); ok && texto == "" {
+				continue
+			}
+			encontrada := ""
+			for _, opcao := range e.Opcoes {
+				if strings.EqualFold(opcao, texto) {
+					encontrada = opcao
+					break
+				}
+			}
+			if encontrada == "" {
+				registrar(chave, "deve ser uma das opções: "+strings.Join(e.Opcoes, ", "))
+				continue
+			}
+			normalizados[chave] = encontrada
+		default:
+			texto, ok := valor.(string)
+			if !ok {
+				registrar(chave, "deve ser um texto")
+				continue
+			}
+			if texto = strings.TrimSpace(texto); texto == "" {
+				continue
+			}
+			if len([]rune(texto)) > 255 {
+				registrar(chave, "deve ter no máximo 255 caracteres")
+				continue
+			}
+			normalizados[chave] = texto
+		}
+	}
+
+	for _, e := range definicoes {
+		if _, ok := normalizados[e.Chave]; e.Obrigatoria && !ok && !comProblema[e.Chave] {
+			registrar(e.Chave, "é obrigatória")
+		}
+	}
+	sort.Strings(problemas)
+	return normalizados, problemas
+}
+
+// montarEspecificacoesProduto junta os valores do produto às definições, na
+// ordem das definições. Valores de especificações que deixaram de se aplicar
+// ao produto ficam de fora.
+func montarEspecificacoesProduto(definicoes []models.Especificacao, valores map[string]interface{}) []models.EspecificacaoProduto {
+	lista := []models.EspecificacaoProduto{}
+	for _, e := range definicoes {
+		valor, ok := valores[e.Chave]
+		if !ok || valor == nil {
+			continue
+		}
+		lista = append(lista, models.EspecificacaoProduto{Chave: e.Chave, Nome: e.Nome, Tipo: e.Tipo, Unidade: e.Unidade, Valor: valor})
+	}
+	return lista
+}
+
+func lerValoresEspecificacoes(dados []byte) map[string]interface{} {
+	valores := map[string]interface{}{}
+	if len(dados) > 0 {
+		json.Unmarshal(dados, &valores)
+	}
+	return valores
+}
+
+// gravarEspecificacoesProduto valida os valores contra as categorias atuais do
+// produto (já vinculadas na transação) e os grava. Responde 400 com a lista de
+// problemas; com ok false a resposta já foi enviada.
+func gravarEspecificacoesProduto(c *gin.Context, tx *sql.Tx, produtoID int, valores map[string]interface{}) ([]models.EspecificacaoProduto, bool) {
+	definicoes, err := carregarEspecificacoes(tx, sqlCategoriasDosProdutos, pq.Array([]int{produtoID}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar especificações", "detalhes": err.Error()})
+		return nil, false
+	}
+
+	normalizados, problemas := validarEspecificacoes(definicoes, valores)
+	if len(problemas) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Especificações inválidas", "problemas": problemas})
+		return nil, false
+	}
+
+	dados, _ := json.Marshal(normalizados)
+	if _, err := tx.Exec(`UPDATE produtos SET especificacoes = $1 WHERE id = $2`, string(dados), produtoID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar especificações", "detalhes": err.Error()})
+		return nil, false
+	}
+	return montarEspecificacoesProduto(definicoes, normalizados), true
+}
+
+// revalidarEspecificacoes confere os valores já gravados contra as definições
+// de novas categorias. Valores de especificações que deixaram de se aplicar
+// são ignorados, como em montarEspecificacoesProduto; sobram as obrigatórias
+// ausentes e os valores incompatíveis.
+func revalidarEspecificacoes(definicoes []models.Especificacao, valores map[string]interface{}) []string {
+	aplicaveis := map[string]interface{}{}
+	for _, e := range definicoes {
+		if valor, ok := valores[e.Chave]; ok {
+			aplicaveis[e.Chave] = valor
+		}
+	}
+	_, problemas := validarEspecificacoes(definicoes, aplicaveis)
+	return problemas
+}
+
+// problemasEspecificacoesProduto revalida as especificações gravadas do
+// produto contra as categorias vinculadas na transação.
+func problemasEspecificacoesProduto(tx *sql.Tx, produtoID int) ([]string, error) {
+	var dados []byte
+	if err := tx.QueryRow(`SELECT especificacoes FROM produtos WHERE id = $1`, produtoID).Scan(&dados); err != nil {
+		return nil, err
+	}
+	definicoes, err := carregarEspecificacoes(tx, sqlCategoriasDosProdutos, pq.Array([]int{produtoID}))
+	if err != nil {
+		return nil, err
+	}
+	return revalidarEspecificacoes(definicoes, lerValoresEspecificacoes(dados)), nil
+}
+
+// conferirEspecificacoesProduto é usada quando só as categorias mudam: responde
+// 400 se as novas categorias exigem especificações que o produto não tem. Com
+// ok false a resposta já foi enviada.
+func conferirEspecificacoesProduto(c *gin.Context, tx *sql.Tx, produtoID int) bool {
+	problemas, err := problemasEspecificacoesProduto(tx, produtoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar especificações", "detalhes": err.Error()})
+		return false
+	}
+	if len(problemas) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Especificações inválidas para as novas categorias", "problemas": problemas})
+		return false
+	}
+	return true
+}
+
+// normalizarEspecificacaoRequest padroniza a chave e as opções; devolve a
+// mensagem de erro quando a definição é inválida.
+func normalizarEspecificacaoRequest(req *models.EspecificacaoRequest) string {
+	req.Chave = strings.ToLower(strings.TrimSpace(req.Chave))
+	req.Nome = strings.TrimSpace(req.Nome)
+	req.Unidade = strings.TrimSpace(req.Unidade)
+	if !padraoChaveEspecificacao.MatchString(req.Chave) {
+		return "A chave deve começar com uma letra e conter apenas letras minúsculas, números e '_'"
+	}
+
+	if req.Tipo != "opcao" {
+		req.Opcoes = []string{}
+		return ""
+	}
+	var opcoes []string
+	vistas := map[string]bool{}
+	for _, opcao := range req.Opcoes {
+		opcao = strings.TrimSpace(opcao)
+		if opcao == "" || vistas[strings.ToLower(opcao)] {
+			continue
+		}
+		vistas[strings.ToLower(opcao)] = true
+		opcoes = append(opcoes, opcao)
+	}
+	if len(opcoes) == 0 {
+		return "Especificações do tipo 'opcao' precisam de ao menos uma opção"
+	}
+	req.Opcoes = opcoes
+	return ""
+}
+
+// ListarEspecificacoesCategoria devolve as especificações da categoria (ID ou
+// slug), incluindo as herdadas das categorias ancestrais.
+func ListarEspecificacoesCategoria(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	categoriaID, err := resolverCategoriaID(db, c.Param("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Categoria não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categoria", "detalhes": err.Error()})
+		return
+	}
+
+	especificacoes, err := carregarEspecificacoes(db, "$1", categoriaID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar especificações", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categoria_id": categoriaID, "especificacoes": especificacoes})
+}
+
+func CriarEspecificacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	categoriaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.EspecificacaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	if msg := normalizarEspecificacaoRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": msg})
+		return
+	}
+
+	var existe bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM categorias WHERE id = $1)`, categoriaID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categoria", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Categoria não encontrada"})
+		return
+	}
+
+	var conflito bool
+	if err := db.QueryRow(sqlConflitoChaveEspecificacao, categoriaID, req.Chave, 0).Scan(&conflito); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar chave", "detalhes": err.Error()})
+		return
+	}
+	if conflito {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Chave já definida nesta categoria, em uma ancestral ou em uma subcategoria"})
+		return
+	}
+
+	especificacao := models.Especificacao{
+		CategoriaID: categoriaID,
+		Chave:       req.Chave,
+		Nome:        req.Nome,
+		Tipo:        req.Tipo,
+		Unidade:     req.Unidade,
+		Opcoes:      req.Opcoes,
+		Obrigatoria: req.Obrigatoria,
+		Ordem:       req.Ordem,
+	}
+	err = db.QueryRow(`
+		INSERT INTO categoria_especificacoes (categoria_id, chave, nome, tipo, unidade, opcoes, obrigatoria, ordem)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+		RETURNING id`,
+		categoriaID, req.Chave, req.Nome, req.Tipo, req.Unidade, pq.Array(req.Opcoes), req.Obrigatoria, req.Ordem).
+		Scan(&especificacao.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"erro": "Chave já definida nesta categoria"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criar especificação", "detalhes": err.Error()})
+		return
+	}
+	if especificacao.Tipo != "opcao" {
+		especificacao.Opcoes = nil
+	}
+
+	c.JSON(http.StatusCreated, especificacao)
+}
+
+// AtualizarEspecificacao altera a definição. Renomear a chave renomeia os
+// valores já gravados nos produtos; mudar o tipo (ou retirar opções em uso)
+// só é aceito quando nenhum produto da categoria tem valor incompatível.
+func AtualizarEspecificacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	categoriaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+	especificacaoID, err := strconv.Atoi(c.Param("especificacao_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID da especificação inválido"})
+		return
+	}
+
+	var req models.EspecificacaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	if msg := normalizarEspecificacaoRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": msg})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var chaveAtual, tipoAtual string
+	err = tx.QueryRow(`SELECT chave, tipo FROM categoria_especificacoes WHERE id = $1 AND categoria_id = $2 FOR UPDATE`,
+		especificacaoID, categoriaID).Scan(&chaveAtual, &tipoAtual)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Especificação não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar especificação", "detalhes": err.Error()})
+		return
+	}
+
+	produtosDaCategoria := `SELECT produto_id FROM produto_categorias WHERE categoria_id IN (` + sqlSubarvoreCategoria + `)`
+
+	if tipoMudou := req.Tipo != tipoAtual; tipoMudou || req.Tipo == "opcao" {
+		var incompativeis int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM produtos
+			WHERE id IN (`+produtosDaCategoria+`) AND especificacoes ? $2
+				AND ($3 OR NOT (especificacoes->>$2 = ANY($4)))`,
+			categoriaID, chaveAtual, tipoMudou, pq.Array(req.Opcoes)).Scan(&incompativeis)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar produtos", "detalhes": err.Error()})
+			return
+		}
+		if incompativeis > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"erro":     "Há produtos com valores incompatíveis com a nova definição; ajuste-os antes",
+				"produtos": incompativeis,
+			})
+			return
+		}
+	}
+
+	if req.Chave != chaveAtual {
+		var conflito bool
+		if err := tx.QueryRow(sqlConflitoChaveEspecificacao, categoriaID, req.Chave, especificacaoID).Scan(&conflito); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar chave", "detalhes": err.Error()})
+			return
+		}
+		if conflito {
+			c.JSON(http.StatusConflict, gin.H{"erro": "Chave já definida nesta categoria, em uma ancestral ou em uma subcategoria"})
+			return
+		}
+		if _, err := tx.Exec(`
+			UPDATE produtos
+			SET especificacoes = (especificacoes - $2::text) || jsonb_build_object($3::text, especificacoes->$2)
+			WHERE id IN (`+produtosDaCategoria+`) AND especificacoes ? $2`,
+			categoriaID, chaveAtual, req.Chave); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao renomear valores dos produtos", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE categoria_especificacoes
+		SET chave = $1, nome = $2, tipo = $3, unidade = NULLIF($4, ''), opcoes = $5, obrigatoria = $6, ordem = $7
+		WHERE id = $8`,
+		req.Chave, req.Nome, req.Tipo, req.Unidade, pq.Array(req.Opcoes), req.Obrigatoria, req.Ordem, especificacaoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar especificação", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar especificação"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Especificação atualizada com sucesso"})
+}
+
+// DeletarEspecificacao remove a definição e os valores dela nos produtos da
+// categoria e das subcategorias.
+func DeletarEspecificacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	categoriaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var chave string
+	err = tx.QueryRow(`DELETE FROM categoria_especificacoes WHERE id = $1 AND categoria_id = $2 RETURNING chave`,
+		c.Param("especificacao_id"), categoriaID).Scan(&chave)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Especificação não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir especificação", "detalhes": err.Error()})
+		return
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE produtos SET especificacoes = especificacoes - $2::text
+		WHERE id IN (SELECT produto_id FROM produto_categorias WHERE categoria_id IN (`+sqlSubarvoreCategoria+`))
+			AND especificacoes ? $2`, categoriaID, chave); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao remover valores dos produtos", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir especificação"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Especificação excluída com sucesso"})
+}
+
+// CompararProdutos monta a matriz lado a lado de ?ids=1,2,3: uma linha por
+// especificação aplicável a algum dos produtos, com os valores na ordem dos
+// IDs informados.
+func CompararProdutos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	lidos, ok := lerIDsQuery(c, 2, 10)
+	if !ok {
+		return
+	}
+	var ids []int
+	vistos := map[int]bool{}
+	for _, id := range lidos {
+		if !vistos[id] {
+			vistos[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Informe ao menos 2 produtos diferentes em 'ids'"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT p.id, p.nome, p.slug, p.imagem, p.preco, `+sqlPrecoEfetivoProduto+`, `+sqlProdutoEmEstoque+`,
+			`+sqlMediaAvaliacoes+`, p.especificacoes
+		FROM produtos p
+		WHERE p.id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produtos", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	produtos := map[int]models.ProdutoComparado{}
+	valores := map[int]map[string]interface{}{}
+	for rows.Next() {
+		var p models.ProdutoComparado
+		var imagem sql.NullString
+		var media sql.NullFloat64
+		var especificacoes []byte
+		if err := rows.Scan(&p.ID, &p.Nome, &p.Slug, &imagem, &p.Preco, &p.PrecoEfetivo, &p.EmEstoque, &media, &especificacoes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler produtos", "detalhes": err.Error()})
+			return
+		}
+		if imagem.Valid {
+			p.Imagem = &imagem.String
+		}
+		if media.Valid {
+			p.AvaliacaoMedia = &media.Float64
+		}
+		produtos[p.ID] = p
+		valores[p.ID] = lerValoresEspecificacoes(especificacoes)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler produtos", "detalhes": err.Error()})
+		return
+	}
+
+	var faltando []int
+	comparacao := models.ComparacaoProdutos{Produtos: []models.ProdutoComparado{}, Especificacoes: []models.LinhaComparacao{}}
+	for _, id := range ids {
+		p, ok := produtos[id]
+		if !ok {
+			faltando = append(faltando, id)
+			continue
+		}
+		comparacao.Produtos = append(comparacao.Produtos, p)
+	}
+	if len(faltando) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado", "ids": faltando})
+		return
+	}
+
+	definicoes, err := carregarEspecificacoes(db, sqlCategoriasDosProdutos, pq.Array(ids))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar especificações", "detalhes": err.Error()})
+		return
+	}
+
+	for _, e := range definicoes {
+		linha := models.LinhaComparacao{Chave: e.Chave, Nome: e.Nome, Tipo: e.Tipo, Unidade: e.Unidade, Valores: make([]interface{}, len(ids))}
+		informada := false
+		for i, id := range ids {
+			linha.Valores[i] = valores[id][e.Chave]
+			if linha.Valores[i] != nil {
+				informada = true
+			}
+			if i > 0 && fmt.Sprint(linha.Valores[i]) != fmt.Sprint(linha.Valores[0]) {
+				linha.Diferente = true
+			}
+		}
+		if informada {
+			comparacao.Especificacoes = append(comparacao.Especificacoes, linha)
+		}
+	}
+
+	c.JSON(http.StatusOK, comparacao)
+}
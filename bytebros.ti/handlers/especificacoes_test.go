@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"bytebros.ti/models"
+)
+
+// Ao trocar de categoria sem enviar especificações, os valores gravados são
+// conferidos contra as definições das novas categorias.
+func TestRevalidarEspecificacoes(t *testing.T) {
+	definicoes := []models.Especificacao{
+		{Chave: "socket", Tipo: "opcao", Opcoes: []string{"AM5", "LGA1700"}, Obrigatoria: true},
+		{Chave: "tdp", Tipo: "numero"},
+	}
+	casos := []struct {
+		nome      string
+		valores   map[string]interface{}
+		problemas []string
+	}{
+		{"obrigatória presente", map[string]interface{}{"socket": "AM5", "tdp": 105.0}, nil},
+		{"obrigatória ausente", map[string]interface{}{"tdp": 105.0}, []string{"'socket' é obrigatória"}},
+		{"sem valores gravados", map[string]interface{}{}, []string{"'socket' é obrigatória"}},
+		{"valores da categoria antiga são ignorados", map[string]interface{}{"socket": "LGA1700", "capacidade": "16GB"}, nil},
+		{"valor fora das novas opções", map[string]interface{}{"socket": "AM4"}, []string{"'socket' deve ser uma das opções: AM5, LGA1700"}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			problemas := revalidarEspecificacoes(definicoes, caso.valores)
+			if !reflect.DeepEqual(problemas, caso.problemas) {
+				t.Errorf("problemas = %q, esperado %q", problemas, caso.problemas)
+			}
+		})
+	}
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var errEstoqueInsuficiente = errors.New("estoque insuficiente")
+
+// registrarMovimentoEstoque aplica a variação ao saldo do produto (ou da
+// variante, se VarianteID estiver preenchido) e grava o movimento na mesma
+// transação. O saldo nunca fica negativo: nesse caso retorna errEstoqueInsuficiente.
+// Produto ou variante inexistente retorna sql.ErrNoRows. Entradas que tiram o
+// produto do zero enfileiram os avisos de disponibilidade.
+func registrarMovimentoEstoque(tx *sql.Tx, m *models.MovimentoEstoque) error {
+	var err error
+	var sku sql.NullString
+	if m.VarianteID != nil {
+		err = tx.QueryRow(`
+			UPDATE produto_variantes
+			SET quantidade = quantidade + $1, atualizado_em = CURRENT_TIMESTAMP
+			WHERE id = $2 AND produto_id = $3 AND quantidade + $1 >= 0
+			RETURNING quantidade, sku`, m.Quantidade, *m.VarianteID, m.ProdutoID).Scan(&m.SaldoApos, &sku)
+	} else {
+		err = tx.QueryRow(`
+			UPDATE produtos SET quantidade = quantidade + $1
+			WHERE id = $2 AND quantidade + $1 >= 0
+			RETURNING quantidade, sku`, m.Quantidade, m.ProdutoID).Scan(&m.SaldoApos, &sku)
+	}
+	if err == sql.ErrNoRows {
+		var existe bool
+		if m.VarianteID != nil {
+			err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM produto_variantes WHERE id = $1 AND produto_id = $2)`, *m.VarianteID, m.ProdutoID).Scan(&existe)
+		} else {
+			err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM produtos WHERE id = $1)`, m.ProdutoID).Scan(&existe)
+		}
+		if err != nil {
+			return err
+		}
+		if existe {
+			return errEstoqueInsuficiente
+		}
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+	m.SKU = sku.String
+
+	var atorID sql.NullInt64
+	if m.AtorID != nil {
+		atorID = sql.NullInt64{Int64: int64(*m.AtorID), Valid: true}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO estoque_movimentos (produto_id, variante_id, de_variante, sku, tipo, quantidade, saldo_apos, motivo, pedido_id, ator_tipo, ator_id, ator_email)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, NULLIF($8, ''), $9, $10, $11, NULLIF($12, ''))
+		RETURNING id, criado_em`,
+		m.ProdutoID, m.VarianteID, m.VarianteID != nil, m.SKU, m.Tipo, m.Quantidade, m.SaldoApos, m.Motivo, m.PedidoID, m.AtorTipo, atorID, m.AtorEmail).
+		Scan(&m.ID, &m.CriadoEm)
+	if err != nil {
+		return err
+	}
+	return enfileirarAvisosDisponibilidade(tx, m)
+}
+
+// novoMovimentoEstoque preenche o autor do movimento a partir da requisição.
+func novoMovimentoEstoque(c *gin.Context, produtoID int, varianteID *int, tipo string, quantidade int, motivo string) *models.MovimentoEstoque {
+	atorTipo, atorID, atorEmail, _ := identificarAtor(c)
+	m := &models.MovimentoEstoque{
+		ProdutoID:  produtoID,
+		VarianteID: varianteID,
+		Tipo:       tipo,
+		Quantidade: quantidade,
+		Motivo:     motivo,
+		AtorTipo:   atorTipo,
+		AtorEmail:  atorEmail,
+	}
+	if atorID != 0 {
+		m.AtorID = &atorID
+	}
+	return m
+}
+
+// movimentarItensPedido baixa (venda) ou devolve (devolucao) ao estoque todos
+// os itens do pedido, respeitando a variante de cada item.
+func movimentarItensPedido(c *gin.Context, tx *sql.Tx, pedidoID int, tipo, motivo string) error {
+	rows, err := tx.Query(`SELECT produto_id, variante_id, nome_produto, quantidade FROM pedido_itens WHERE pedido_id = $1 ORDER BY id`, pedidoID)
+	if err != nil {
+		return err
+	}
+
+	type itemEstoque struct {
+		produtoID  int
+		varianteID *int
+		nome       string
+		quantidade int
+	}
+	var itens []itemEstoque
+	for rows.Next() {
+		var item itemEstoque
+		var varianteID sql.NullInt64
+		if err := rows.Scan(&item.produtoID, &varianteID, &item.nome, &item.quantidade); err != nil {
+			rows.Close()
+			return err
+		}
+		if varianteID.Valid {
+			id := int(varianteID.Int64)
+			item.varianteID = &id
+		}
+		itens = append(itens, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range itens {
+		quantidade := item.quantidade
+		if tipo == "venda" {
+			quantidade = -quantidade
+		}
+		m := novoMovimentoEstoque(c, item.produtoID, item.varianteID, tipo, quantidade, motivo)
+		m.PedidoID = &pedidoID
+		if err := registrarMovimentoEstoque(tx, m); err != nil {
+			if err == errEstoqueInsuficiente {
+				return fmt.Errorf("%w para %s", errEstoqueInsuficiente, item.nome)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// RegistrarMovimentoEstoque lança um movimento manual: entrada (recebimento de
+// compra), devolucao, perda ou ajuste (inventário).
+func RegistrarMovimentoEstoque(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.MovimentoEstoqueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	req.Motivo = strings.TrimSpace(req.Motivo)
+
+	// Entradas, devoluções e perdas são informadas em unidades positivas; o
+	// ajuste recebe a variação com sinal (ex.: -2 após uma contagem).
+	quantidade := req.Quantidade
+	switch req.Tipo {
+	case "entrada", "devolucao", "perda":
+		if quantidade <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "'quantidade' deve ser positiva para este tipo de movimento"})
+			return
+		}
+		if req.Tipo == "perda" {
+			quantidade = -quantidade
+		}
+	}
+	if (req.Tipo == "ajuste" || req.Tipo == "perda") && req.Motivo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Informe o 'motivo' para ajustes e perdas"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	m := novoMovimentoEstoque(c, produtoID, req.VarianteID, req.Tipo, quantidade, req.Motivo)
+	if err := registrarMovimentoEstoque(tx, m); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Produto ou variante não encontrado"})
+		case errEstoqueInsuficiente:
+			c.JSON(http.StatusConflict, gin.H{"erro": "O movimento deixaria o estoque negativo"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar movimento", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao confirmar transação"})
+		return
+	}
+	c.JSON(http.StatusCreated, m)
+}
+
+// ListarMovimentosEstoque devolve o histórico do produto, do mais recente
+// para o mais antigo.
+func ListarMovimentosEstoque(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	whereClauses := []string{"produto_id = $1"}
+	args := []interface{}{produtoID}
+
+	if varianteID := c.Query("variante_id"); varianteID != "" {
+		if varianteID == "nenhuma" {
+			whereClauses = append(whereClauses, "NOT de_variante")
+		} else {
+			args = append(args, varianteID)
+			whereClauses = append(whereClauses, fmt.Sprintf("variante_id = $%d", len(args)))
+		}
+	}
+	if tipo := c.Query("tipo"); tipo != "" {
+		args = append(args, tipo)
+		whereClauses = append(whereClauses, fmt.Sprintf("tipo = $%d", len(args)))
+	}
+	if pedidoID := c.Query("pedido_id"); pedidoID != "" {
+		args = append(args, pedidoID)
+		whereClauses = append(whereClauses, fmt.Sprintf("pedido_id = $%d", len(args)))
+	}
+
+	limite := 50
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= 200 {
+		limite = v
+	}
+	pagina := 1
+	if v, err := strconv.Atoi(c.Query("pagina")); err == nil && v > 0 {
+		pagina = v
+	}
+	args = append(args, limite, (pagina-1)*limite)
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT id, produto_id, variante_id, COALESCE(sku, ''), tipo, quantidade, saldo_apos, motivo, pedido_id, ator_tipo, ator_id, ator_email, criado_em
+		FROM estoque_movimentos
+		WHERE %s
+		ORDER BY criado_em DESC, id DESC
+		LIMIT $%d OFFSET $%d`, strings.Join(whereClauses, " AND "), len(args)-1, len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar movimentos de estoque", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	movimentos := make([]models.MovimentoEstoque, 0)
+	for rows.Next() {
+		var m models.MovimentoEstoque
+		var varianteID, pedidoID, atorID sql.NullInt64
+		var motivo, atorEmail sql.NullString
+		if err := rows.Scan(&m.ID, &m.ProdutoID, &varianteID, &m.SKU, &m.Tipo, &m.Quantidade, &m.SaldoApos, &motivo, &pedidoID, &m.AtorTipo, &atorID, &atorEmail, &m.CriadoEm); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler movimentos de estoque", "detalhes": err.Error()})
+			return
+		}
+		if varianteID.Valid {
+			id := int(varianteID.Int64)
+			m.VarianteID = &id
+		}
+		if pedidoID.Valid {
+			id := int(pedidoID.Int64)
+			m.PedidoID = &id
+		}
+		if atorID.Valid {
+			id := int(atorID.Int64)
+			m.AtorID = &id
+		}
+		m.Motivo = motivo.String
+		m.AtorEmail = atorEmail.String
+		movimentos = append(movimentos, m)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"movimentos": movimentos, "pagina": pagina, "limite": limite})
+}
+
+// sqlSituacaoEstoque lista o saldo de cada produto e variante ao lado da soma
+// dos seus movimentos no livro.
+const sqlSituacaoEstoque = `
+	SELECT * FROM (
+		SELECT p.id AS produto_id, p.nome, NULL::INTEGER AS variante_id, p.sku, p.quantidade,
+			COALESCE((SELECT SUM(m.quantidade) FROM estoque_movimentos m WHERE m.produto_id = p.id AND NOT m.de_variante), 0) AS saldo_livro
+		FROM produtos p
+		UNION ALL
+		SELECT v.produto_id, p.nome, v.id, v.sku, v.quantidade,
+			COALESCE((SELECT SUM(m.quantidade) FROM estoque_movimentos m WHERE m.variante_id = v.id), 0)
+		FROM produto_variantes v
+		JOIN produtos p ON p.id = v.produto_id
+	) situacao`
+
+func listarSituacaoEstoque(db consultaSQL, filtro string, args ...interface{}) ([]models.SituacaoEstoque, error) {
+	rows, err := db.Query(sqlSituacaoEstoque+` WHERE `+filtro+` ORDER BY nome, produto_id, variante_id NULLS FIRST`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	situacoes := make([]models.SituacaoEstoque, 0)
+	for rows.Next() {
+		var s models.SituacaoEstoque
+		var varianteID sql.NullInt64
+		var sku sql.NullString
+		if err := rows.Scan(&s.ProdutoID, &s.Nome, &varianteID, &sku, &s.Quantidade, &s.SaldoLivro); err != nil {
+			return nil, err
+		}
+		if varianteID.Valid {
+			id := int(varianteID.Int64)
+			s.VarianteID = &id
+		}
+		s.SKU = sku.String
+		s.Divergencia = s.Quantidade - s.SaldoLivro
+		situacoes = append(situacoes, s)
+	}
+	return situacoes, rows.Err()
+}
+
+// ObterSituacaoEstoque mostra o saldo do produto e de suas variantes conferido
+// com o livro de estoque.
+func ObterSituacaoEstoque(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	situacoes, err := listarSituacaoEstoque(db, "produto_id = $1", produtoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao conferir estoque", "detalhes": err.Error()})
+		return
+	}
+	if len(situacoes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, situacoes)
+}
+
+// ListarDivergenciasEstoque aponta produtos e variantes cujo saldo não bate
+// com o livro (ex.: alterações feitas direto no banco).
+func ListarDivergenciasEstoque(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	situacoes, err := listarSituacaoEstoque(db, "quantidade <> saldo_livro")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao conferir estoque", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, situacoes)
+}
+
+// ReconciliarEstoque lança, para cada divergência, um ajuste que traz o livro
+// ao saldo atual sem alterar a quantidade, registrando quem reconciliou.
+func ReconciliarEstoque(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	var req struct {
+		Motivo string `json:"motivo" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	// As divergências são travadas (FOR UPDATE) e recalculadas antes do ajuste:
+	// uma venda concorrente espera a reconciliação, e uma segunda reconciliação
+	// encontra o livro já corrigido em vez de aplicar o mesmo ajuste de novo.
+	candidatas, err := listarSituacaoEstoque(tx, "quantidade <> saldo_livro")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao conferir estoque", "detalhes": err.Error()})
+		return
+	}
+	produtoIDs, varianteIDs := []int64{}, []int64{}
+	for _, s := range candidatas {
+		if s.VarianteID != nil {
+			varianteIDs = append(varianteIDs, int64(*s.VarianteID))
+		} else {
+			produtoIDs = append(produtoIDs, int64(s.ProdutoID))
+		}
+	}
+	for _, trava := range []struct {
+		query string
+		ids   []int64
+	}{
+		{`SELECT id FROM produtos WHERE id = ANY($1) ORDER BY id FOR UPDATE`, produtoIDs},
+		{`SELECT id FROM produto_variantes WHERE id = ANY($1) ORDER BY id FOR UPDATE`, varianteIDs},
+	} {
+		if len(trava.ids) == 0 {
+			continue
+		}
+		if _, err := tx.Exec(trava.query, pq.Array(trava.ids)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao travar estoque", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	situacoes, err := listarSituacaoEstoque(tx,
+		"quantidade <> saldo_livro AND (variante_id = ANY($1) OR (variante_id IS NULL AND produto_id = ANY($2)))",
+		pq.Array(varianteIDs), pq.Array(produtoIDs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao conferir estoque", "detalhes": err.Error()})
+		return
+	}
+
+	for _, s := range situacoes {
+		m := novoMovimentoEstoque(c, s.ProdutoID, s.VarianteID, "ajuste", s.Divergencia, "Reconciliação: "+req.Motivo)
+		_, err := tx.Exec(`
+			INSERT INTO estoque_movimentos (produto_id, variante_id, de_variante, sku, tipo, quantidade, saldo_apos, motivo, ator_tipo, ator_id, ator_email)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, NULLIF($11, ''))`,
+			m.ProdutoID, m.VarianteID, m.VarianteID != nil, s.SKU, m.Tipo, m.Quantidade, s.Quantidade, m.Motivo, m.AtorTipo, m.AtorID, m.AtorEmail)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar reconciliação", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao confirmar transação"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Estoque reconciliado", "ajustes": situacoes})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizarCargo(t *testing.T) {
+	casos := []struct {
+		cargo    string
+		esperado string
+		ok       bool
+	}{
+		{"Tecnico", "Tecnico", true},
+		{"tecnico", "Tecnico", true},
+		{"  SUPERVISOR ", "Supervisor", true},
+		{"Atendente", "Atendente", true},
+		{"admin", "", false},
+		{"Administrador", "", false},
+		{"Técnico de campo", "", false},
+		{"", "", false},
+	}
+	for _, caso := range casos {
+		cargo, ok := normalizarCargo(caso.cargo)
+		if cargo != caso.esperado || ok != caso.ok {
+			t.Errorf("normalizarCargo(%q) = %q, %v; esperado %q, %v", caso.cargo, cargo, ok, caso.esperado, caso.ok)
+		}
+	}
+}
+
+// Cargos desconhecidos são recusados antes de qualquer consulta; os aceitos
+// seguem para o banco, que nos testes sempre falha.
+func TestCargoFuncionarioNoCadastroEAlteracao(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := sql.Open("sem-banco", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	roteador := gin.New()
+	roteador.Use(func(c *gin.Context) { c.Set("db", db) })
+	roteador.POST("/api/admin/funcionarios", CriarFuncionario)
+	roteador.PUT("/api/admin/funcionarios/:id/cargo", AlterarCargoFuncionario)
+
+	casos := []struct {
+		nome   string
+		metodo string
+		rota   string
+		corpo  string
+		status int
+	}{
+		{"cadastro com cargo conhecido", http.MethodPost, "/api/admin/funcionarios", `{"nome": "João Func", "cargo": "tecnico", "email": "joao@bytebros.com", "senha": "Senha@123"}`, http.StatusInternalServerError},
+		{"cadastro com cargo desconhecido", http.MethodPost, "/api/admin/funcionarios", `{"nome": "João Func", "cargo": "Faxineiro", "email": "joao@bytebros.com", "senha": "Senha@123"}`, http.StatusBadRequest},
+		{"cadastro como admin", http.MethodPost, "/api/admin/funcionarios", `{"nome": "João Func", "cargo": "admin", "email": "joao@bytebros.com", "senha": "Senha@123"}`, http.StatusBadRequest},
+		{"alteração para cargo conhecido", http.MethodPut, "/api/admin/funcionarios/1/cargo", `{"cargo": "Supervisor"}`, http.StatusInternalServerError},
+		{"alteração para cargo desconhecido", http.MethodPut, "/api/admin/funcionarios/1/cargo", `{"cargo": "Chefe"}`, http.StatusBadRequest},
+		{"alteração para admin", http.MethodPut, "/api/admin/funcionarios/1/cargo", `{"cargo": "ADMIN"}`, http.StatusBadRequest},
+		{"alteração sem cargo", http.MethodPut, "/api/admin/funcionarios/1/cargo", `{}`, http.StatusBadRequest},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(caso.metodo, caso.rota, strings.NewReader(caso.corpo))
+			req.Header.Set("Content-Type", "application/json")
+			roteador.ServeHTTP(w, req)
+
+			if w.Code != caso.status {
+				t.Errorf("status = %d, esperado %d; corpo: %s", w.Code, caso.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"bytebros.ti/models"
 
@@ -11,69 +15,32 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func RegistrarFuncionario(c *gin.Context) {
-	log.Printf("DEBUG: Iniciando handler RegistrarFuncionario.")
-	var funcionario models.Funcionario
-	if err := c.ShouldBindJSON(&funcionario); err != nil {
-		log.Printf("ERRO: Falha ao fazer bind JSON para RegistrarFuncionario: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
-		return
-	}
-	log.Printf("DEBUG: Dados do funcionário recebidos: Email=%s, Nome=%s, Cargo=%s", funcionario.Email, funcionario.Nome, funcionario.Cargo)
-
-	db := c.MustGet("db").(*sql.DB)
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM funcionarios WHERE email = $1", funcionario.Email).Scan(&count)
-	if err != nil {
-		log.Printf("ERRO BD: Falha ao verificar existência de email em 'funcionarios': %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro interno ao verificar email de funcionário"})
-		return
-	}
-	if count > 0 {
-		log.Printf("AVISO: Tentativa de registro de funcionário com email já existente: %s", funcionario.Email)
-		c.JSON(http.StatusBadRequest, gin.H{"erro": "Email já registrado para funcionário"})
-		return
-	}
-	log.Printf("DEBUG: Email %s não encontrado em funcionários. Prosseguindo com o registro.", funcionario.Email)
+// cargosFuncionario são os cargos aceitos no cadastro de funcionários.
+// "admin" fica de fora: administradores têm cadastro e login próprios.
+var cargosFuncionario = []string{"Atendente", "Estoquista", "Gerente", "Supervisor", "Tecnico", "Vendedor"}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(funcionario.Senha), bcrypt.DefaultCost)
-	if err != nil {
-		log.Printf("ERRO: Falha ao criptografar senha de funcionário: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criptografar senha de funcionário"})
-		return
+// normalizarCargo compara sem diferenciar maiúsculas e retorna o cargo com a
+// grafia de cargosFuncionario.
+func normalizarCargo(cargo string) (string, bool) {
+	cargo = strings.TrimSpace(cargo)
+	for _, conhecido := range cargosFuncionario {
+		if strings.EqualFold(cargo, conhecido) {
+			return conhecido, true
+		}
 	}
-	log.Printf("DEBUG: Senha de funcionário criptografada com sucesso.")
-
-	err = db.QueryRow(`
-        INSERT INTO funcionarios (nome, cargo, email, senha_hash)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id`,
-		funcionario.Nome, funcionario.Cargo, funcionario.Email, string(hashedPassword)).
-		Scan(&funcionario.ID)
+	return "", false
+}
 
-	if err != nil {
-		log.Printf("ERRO BD: Falha ao inserir novo funcionário: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar funcionário"})
-		return
+// cargoFuncionarioValido responde 400 com os cargos aceitos quando o cargo é
+// desconhecido; caso contrário grava a grafia padrão em cargo.
+func cargoFuncionarioValido(c *gin.Context, cargo *string) bool {
+	normalizado, ok := normalizarCargo(*cargo)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Cargo inválido", "opcoes": cargosFuncionario})
+		return false
 	}
-	log.Printf("DEBUG: Funcionário registrado com ID: %d", funcionario.ID)
-
-	token, err := generateJWTToken(funcionario.ID, funcionario.Email, funcionario.Cargo)
-	if err != nil {
-		log.Printf("ERRO: Falha ao gerar token JWT para funcionário %s: %v", funcionario.Email, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar token para funcionário"})
-		return
-	}
-	log.Printf("DEBUG: Token JWT gerado com sucesso para funcionário %s", funcionario.Email)
-
-	c.JSON(http.StatusCreated, models.FuncionarioResponse{
-		ID:    funcionario.ID,
-		Nome:  funcionario.Nome,
-		Cargo: funcionario.Cargo,
-		Email: funcionario.Email,
-		Token: token,
-	})
-	log.Printf("DEBUG: Resposta de registro de funcionário enviada com sucesso.")
+	*cargo = normalizado
+	return true
 }
 
 func LoginFuncionario(c *gin.Context) {
@@ -90,13 +57,14 @@ func LoginFuncionario(c *gin.Context) {
 	var funcionario models.Funcionario
 
 	var senhaHashDB string
+	var ativo bool
 
 	log.Printf("DEBUG: Executando query SELECT para funcionário com email %s.", login.Email)
 	err := db.QueryRow(`
-        SELECT id, nome, cargo, email, senha_hash
+        SELECT id, nome, cargo, email, senha_hash, ativo
         FROM funcionarios
         WHERE email = $1`, login.Email).
-		Scan(&funcionario.ID, &funcionario.Nome, &funcionario.Cargo, &funcionario.Email, &senhaHashDB)
+		Scan(&funcionario.ID, &funcionario.Nome, &funcionario.Cargo, &funcionario.Email, &senhaHashDB, &ativo)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -115,6 +83,12 @@ func LoginFuncionario(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Credenciais inválidas"})
 		return
 	}
+	if !ativo {
+		log.Printf("AVISO: Tentativa de login de funcionário desativado: %s", login.Email)
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Funcionário desativado"})
+		return
+	}
+	atualizarHashSenha(db, "funcionarios", funcionario.ID, senhaHashDB, login.Senha)
 	log.Printf("DEBUG: Senha correta para funcionário %s. Gerando token.", funcionario.Email)
 
 	token, err := generateJWTToken(funcionario.ID, funcionario.Email, funcionario.Cargo)
@@ -134,23 +108,63 @@ func LoginFuncionario(c *gin.Context) {
 	})
 	log.Printf("DEBUG: Resposta de login de funcionário enviada com sucesso.")
 }
+
+func scanFuncionarioResumo(row linhaSQL) (models.FuncionarioResumo, error) {
+	var f models.FuncionarioResumo
+	var atualizadoEm sql.NullTime
+	err := row.Scan(&f.ID, &f.Nome, &f.Cargo, &f.Email, &f.Ativo, &f.CriadoEm, &atualizadoEm)
+	if atualizadoEm.Valid {
+		f.AtualizadoEm = atualizadoEm.Time
+	} else {
+		f.AtualizadoEm = f.CriadoEm
+	}
+	return f, err
+}
+
 func ListarFuncionarios(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 
-	rows, err := db.Query(`
-        SELECT id, nome, cargo, email, criado_em
-        FROM funcionarios
-        ORDER BY nome`)
+	busca := c.Query("busca")
+	cargo := c.Query("cargo")
+	ativo := c.Query("ativo")
+
+	query := `SELECT id, nome, cargo, email, ativo, criado_em, atualizado_em FROM funcionarios`
+	args := []interface{}{}
+	whereClauses := []string{}
+	argCounter := 1
+
+	if busca != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf("(nome ILIKE $%d OR email ILIKE $%d)", argCounter, argCounter))
+		args = append(args, "%"+busca+"%")
+		argCounter++
+	}
+	if cargo != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf("cargo = $%d", argCounter))
+		args = append(args, cargo)
+		argCounter++
+	}
+	if ativo == "true" || ativo == "false" {
+		whereClauses = append(whereClauses, fmt.Sprintf("ativo = $%d", argCounter))
+		args = append(args, ativo == "true")
+		argCounter++
+	}
+
+	if len(whereClauses) > 0 {
+		query += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	query += " ORDER BY nome"
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar funcionários"})
 		return
 	}
 	defer rows.Close()
 
-	var funcionarios []models.Funcionario
+	funcionarios := make([]models.FuncionarioResumo, 0)
 	for rows.Next() {
-		var f models.Funcionario
-		if err := rows.Scan(&f.ID, &f.Nome, &f.Cargo, &f.Email); err != nil {
+		f, err := scanFuncionarioResumo(rows)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler funcionários"})
 			return
 		}
@@ -159,3 +173,271 @@ func ListarFuncionarios(c *gin.Context) {
 
 	c.JSON(http.StatusOK, funcionarios)
 }
+
+func ObterFuncionario(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	funcionario, err := scanFuncionarioResumo(db.QueryRow(`
+		SELECT id, nome, cargo, email, ativo, criado_em, atualizado_em
+		FROM funcionarios
+		WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Funcionário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar funcionário", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, funcionario)
+}
+
+func CriarFuncionario(c *gin.Context) {
+	var req models.Funcionario
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	if !cargoFuncionarioValido(c, &req.Cargo) {
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM funcionarios WHERE email = $1", req.Email).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro interno ao verificar email de funcionário"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Email já registrado para funcionário"})
+		return
+	}
+
+	if !senhaAtendePolitica(c, req.Senha, req.Email) {
+		return
+	}
+
+	hashedPassword, err := gerarHashSenha(req.Senha)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criptografar senha de funcionário"})
+		return
+	}
+
+	funcionario, err := scanFuncionarioResumo(db.QueryRow(`
+		INSERT INTO funcionarios (nome, cargo, email, senha_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, nome, cargo, email, ativo, criado_em, atualizado_em`,
+		req.Nome, req.Cargo, req.Email, hashedPassword))
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao inserir novo funcionário: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao cadastrar funcionário", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, funcionario)
+}
+
+func AtualizarFuncionario(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var req models.AtualizarFuncionarioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM funcionarios WHERE email = $1 AND id != $2`, req.Email, id).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar email."})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Este email já está em uso por outro funcionário."})
+		return
+	}
+
+	funcionario, err := scanFuncionarioResumo(db.QueryRow(`
+		UPDATE funcionarios
+		SET nome = $1, email = $2, atualizado_em = $3
+		WHERE id = $4
+		RETURNING id, nome, cargo, email, ativo, criado_em, atualizado_em`,
+		req.Nome, req.Email, time.Now(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Funcionário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar funcionário", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, funcionario)
+}
+
+func AlterarCargoFuncionario(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var req models.AlterarCargoFuncionarioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	if !cargoFuncionarioValido(c, &req.Cargo) {
+		return
+	}
+
+	funcionario, err := scanFuncionarioResumo(db.QueryRow(`
+		UPDATE funcionarios
+		SET cargo = $1, atualizado_em = $2
+		WHERE id = $3
+		RETURNING id, nome, cargo, email, ativo, criado_em, atualizado_em`,
+		req.Cargo, time.Now(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Funcionário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao alterar cargo do funcionário", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, funcionario)
+}
+
+func RedefinirSenhaFuncionario(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var req models.RedefinirSenhaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	var email string
+	if err := db.QueryRow(`SELECT email FROM funcionarios WHERE id = $1`, id).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Funcionário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar funcionário", "detalhes": err.Error()})
+		}
+		return
+	}
+	if !senhaAtendePolitica(c, req.NovaSenha, email) {
+		return
+	}
+
+	hashedPassword, err := gerarHashSenha(req.NovaSenha)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criptografar senha de funcionário"})
+		return
+	}
+
+	result, err := db.Exec(`UPDATE funcionarios SET senha_hash = $1, atualizado_em = $2 WHERE id = $3`, hashedPassword, time.Now(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao redefinir senha do funcionário", "detalhes": err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Funcionário não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Senha do funcionário redefinida com sucesso!"})
+}
+
+func AlterarStatusFuncionario(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var req models.AlterarStatusFuncionarioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	funcionario, err := scanFuncionarioResumo(db.QueryRow(`
+		UPDATE funcionarios
+		SET ativo = $1, atualizado_em = $2
+		WHERE id = $3
+		RETURNING id, nome, cargo, email, ativo, criado_em, atualizado_em`,
+		*req.Ativo, time.Now(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Funcionário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao alterar status do funcionário", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, funcionario)
+}
+
+func EstatisticasFuncionario(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var funcionarioID int
+	if err := db.QueryRow(`SELECT id FROM funcionarios WHERE id = $1`, id).Scan(&funcionarioID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Funcionário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar funcionário", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	dias, err := strconv.Atoi(c.DefaultQuery("dias", "30"))
+	if err != nil || dias <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Parâmetro 'dias' inválido"})
+		return
+	}
+	desde := time.Now().AddDate(0, 0, -dias)
+
+	stats := models.EstatisticasFuncionario{
+		FuncionarioID: funcionarioID,
+		Periodo:       fmt.Sprintf("últimos %d dias", dias),
+	}
+
+	var ultimoTicket, ultimoOrcamento sql.NullTime
+	err = db.QueryRow(`
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'em_andamento'),
+			COUNT(*) FILTER (WHERE status = 'resolvido'),
+			MAX(atendido_em)
+		FROM suporte
+		WHERE atendido_por = $1 AND atendido_em >= $2`, funcionarioID, desde).
+		Scan(&stats.TicketsAtendidos, &stats.TicketsEmAndamento, &stats.TicketsResolvidos, &ultimoTicket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao calcular estatísticas de suporte", "detalhes": err.Error()})
+		return
+	}
+
+	err = db.QueryRow(`
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'aprovado'),
+			COUNT(*) FILTER (WHERE status = 'rejeitado'),
+			MAX(processado_em)
+		FROM orcamentos
+		WHERE processado_por = $1 AND processado_em >= $2`, funcionarioID, desde).
+		Scan(&stats.OrcamentosProcessados, &stats.OrcamentosAprovados, &stats.OrcamentosRejeitados, &ultimoOrcamento)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao calcular estatísticas de orçamentos", "detalhes": err.Error()})
+		return
+	}
+
+	for _, t := range []sql.NullTime{ultimoTicket, ultimoOrcamento} {
+		if t.Valid && (stats.UltimaAtividade == nil || t.Time.After(*stats.UltimaAtividade)) {
+			ultima := t.Time
+			stats.UltimaAtividade = &ultima
+		}
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,561 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// tamanhosMiniatura são gerados para cada imagem enviada; o lado indica a maior
+// dimensão (largura ou altura) da miniatura, preservando a proporção.
+var tamanhosMiniatura = []struct {
+	nome string
+	lado int
+}{
+	{"pequena", 150},
+	{"media", 400},
+	{"grande", 800},
+}
+
+// tiposImagemPermitidos são detectados pelo conteúdo do arquivo, não pelo
+// Content-Type informado pelo navegador.
+var tiposImagemPermitidos = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+const (
+	maximoImagensPorEnvio = 10
+	maximoPixelsImagem    = 40000000
+)
+
+func tamanhoMaximoImagem() int64 {
+	megabytes := 5
+	if v, err := strconv.Atoi(os.Getenv("IMAGEM_TAMANHO_MAXIMO_MB")); err == nil && v > 0 {
+		megabytes = v
+	}
+	return int64(megabytes) << 20
+}
+
+type imagemProcessada struct {
+	conteudo        []byte
+	tipo, extensao  string
+	largura, altura int
+	miniaturas      map[string][]byte
+	tipoMiniatura   string
+	extMiniatura    string
+}
+
+// processarImagem valida tipo, tamanho e dimensões do arquivo e gera as
+// miniaturas. Os erros retornados podem ser exibidos ao usuário.
+func processarImagem(arquivo *multipart.FileHeader, limite int64) (*imagemProcessada, error) {
+	if arquivo.Size > limite {
+		return nil, fmt.Errorf("Arquivo maior que o limite de %d MB", limite>>20)
+	}
+
+	f, err := arquivo.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Não foi possível ler o arquivo")
+	}
+	defer f.Close()
+
+	conteudo, err := io.ReadAll(io.LimitReader(f, limite+1))
+	if err != nil {
+		return nil, fmt.Errorf("Não foi possível ler o arquivo")
+	}
+	if int64(len(conteudo)) > limite {
+		return nil, fmt.Errorf("Arquivo maior que o limite de %d MB", limite>>20)
+	}
+
+	tipo := http.DetectContentType(conteudo)
+	extensao, ok := tiposImagemPermitidos[tipo]
+	if !ok {
+		return nil, fmt.Errorf("Tipo de arquivo não permitido (%s). Envie JPEG, PNG ou GIF", tipo)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(conteudo))
+	if err != nil {
+		return nil, fmt.Errorf("Imagem corrompida ou inválida")
+	}
+	if config.Width*config.Height > maximoPixelsImagem {
+		return nil, fmt.Errorf("Imagem com dimensões grandes demais (%dx%d)", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(conteudo))
+	if err != nil {
+		return nil, fmt.Errorf("Imagem corrompida ou inválida")
+	}
+
+	p := &imagemProcessada{
+		conteudo:      conteudo,
+		tipo:          tipo,
+		extensao:      extensao,
+		largura:       config.Width,
+		altura:        config.Height,
+		miniaturas:    map[string][]byte{},
+		tipoMiniatura: "image/png",
+		extMiniatura:  "png",
+	}
+	// JPEG continua JPEG; PNG e GIF viram PNG para manter a transparência.
+	if tipo == "image/jpeg" {
+		p.tipoMiniatura, p.extMiniatura = "image/jpeg", "jpg"
+	}
+
+	origem := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(origem, origem.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	for _, tamanho := range tamanhosMiniatura {
+		var buf bytes.Buffer
+		miniatura := redimensionarImagem(origem, tamanho.lado)
+		if p.tipoMiniatura == "image/jpeg" {
+			err = jpeg.Encode(&buf, miniatura, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, miniatura)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Falha ao gerar miniatura")
+		}
+		p.miniaturas[tamanho.nome] = buf.Bytes()
+	}
+	return p, nil
+}
+
+// redimensionarImagem reduz a imagem para caber em lado x lado pela média dos
+// pixels de cada bloco (filtro de área). Imagens menores não são ampliadas.
+func redimensionarImagem(origem *image.RGBA, lado int) *image.RGBA {
+	w, h := origem.Bounds().Dx(), origem.Bounds().Dy()
+	if w <= lado && h <= lado {
+		return origem
+	}
+
+	escala := float64(lado) / float64(max(w, h))
+	nw := max(1, int(math.Round(float64(w)*escala)))
+	nh := max(1, int(math.Round(float64(h)*escala)))
+	destino := image.NewRGBA(image.Rect(0, 0, nw, nh))
+
+	for y := 0; y < nh; y++ {
+		y0, y1 := y*h/nh, max((y+1)*h/nh, y*h/nh+1)
+		for x := 0; x < nw; x++ {
+			x0, x1 := x*w/nw, max((x+1)*w/nw, x*w/nw+1)
+
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				i := sy*origem.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(origem.Pix[i])
+					g += uint64(origem.Pix[i+1])
+					b += uint64(origem.Pix[i+2])
+					a += uint64(origem.Pix[i+3])
+					i += 4
+				}
+			}
+
+			n := uint64((y1 - y0) * (x1 - x0))
+			o := y*destino.Stride + x*4
+			destino.Pix[o] = uint8(r / n)
+			destino.Pix[o+1] = uint8(g / n)
+			destino.Pix[o+2] = uint8(b / n)
+			destino.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return destino
+}
+
+// removerArquivosArmazenados é usado na limpeza; falhas apenas vão para o log.
+func removerArquivosArmazenados(chaves []string) {
+	armazenamento := obterArmazenamento()
+	for _, chave := range chaves {
+		if err := armazenamento.Remover(context.Background(), chave); err != nil {
+			log.Printf("ERRO: Falha ao remover arquivo %s (%s): %v", chave, armazenamento.Nome(), err)
+		}
+	}
+}
+
+const colunasProdutoImagem = `id, produto_id, url, miniaturas, COALESCE(texto_alternativo, ''), tipo_conteudo, largura, altura, tamanho_bytes, ordem, criado_em, arquivos`
+
+func scanProdutoImagem(row linhaSQL) (models.ProdutoImagem, error) {
+	var img models.ProdutoImagem
+	var miniaturas []byte
+	err := row.Scan(&img.ID, &img.ProdutoID, &img.URL, &miniaturas, &img.TextoAlternativo, &img.TipoConteudo,
+		&img.Largura, &img.Altura, &img.TamanhoBytes, &img.Ordem, &img.CriadoEm, pq.Array(&img.Arquivos))
+	if err != nil {
+		return img, err
+	}
+	return img, json.Unmarshal(miniaturas, &img.Miniaturas)
+}
+
+func carregarImagensProdutos(db *sql.DB, produtoIDs []int) (map[int][]models.ProdutoImagem, error) {
+	resultado := map[int][]models.ProdutoImagem{}
+	if len(produtoIDs) == 0 {
+		return resultado, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT `+colunasProdutoImagem+`
+		FROM produto_imagens
+		WHERE produto_id = ANY($1)
+		ORDER BY ordem, id`, pq.Array(produtoIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		img, err := scanProdutoImagem(rows)
+		if err != nil {
+			return nil, err
+		}
+		resultado[img.ProdutoID] = append(resultado[img.ProdutoID], img)
+	}
+	return resultado, rows.Err()
+}
+
+// atualizarImagemPrincipal mantém produtos.imagem apontando para a primeira
+// imagem da galeria. Sem galeria, a URL antiga só é limpa se for urlRemovida.
+func atualizarImagemPrincipal(tx *sql.Tx, produtoID int, urlRemovida string) error {
+	_, err := tx.Exec(`
+		UPDATE produtos
+		SET imagem = COALESCE(
+			(SELECT url FROM produto_imagens WHERE produto_id = $1 ORDER BY ordem, id LIMIT 1),
+			CASE WHEN imagem = $2 THEN NULL ELSE imagem END)
+		WHERE id = $1`, produtoID, urlRemovida)
+	return err
+}
+
+// arquivosImagensProduto lista as chaves de todos os arquivos da galeria, para
+// removê-los do armazenamento quando o produto é excluído.
+func arquivosImagensProduto(db *sql.DB, produtoID string) ([]string, error) {
+	rows, err := db.Query(`SELECT unnest(arquivos) FROM produto_imagens WHERE produto_id = $1`, produtoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chaves []string
+	for rows.Next() {
+		var chave string
+		if err := rows.Scan(&chave); err != nil {
+			return nil, err
+		}
+		chaves = append(chaves, chave)
+	}
+	return chaves, rows.Err()
+}
+
+func ListarImagensProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	imagens, err := carregarImagensProdutos(db, []int{produtoID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar imagens", "detalhes": err.Error()})
+		return
+	}
+	lista := imagens[produtoID]
+	if lista == nil {
+		lista = []models.ProdutoImagem{}
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// EnviarImagensProduto recebe um ou mais arquivos no campo multipart "imagens",
+// grava original e miniaturas no armazenamento e adiciona as imagens ao fim
+// da galeria do produto.
+func EnviarImagensProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var existe bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM produtos WHERE id = $1)`, produtoID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+
+	limite := tamanhoMaximoImagem()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limite*maximoImagensPorEnvio+(1<<20))
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["imagens"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Envie as imagens como multipart/form-data no campo 'imagens'"})
+		return
+	}
+	arquivos := form.File["imagens"]
+	if len(arquivos) > maximoImagensPorEnvio {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("Envie no máximo %d imagens por vez", maximoImagensPorEnvio)})
+		return
+	}
+	textoAlternativo := c.PostForm("texto_alternativo")
+	if len([]rune(textoAlternativo)) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "'texto_alternativo' deve ter no máximo 255 caracteres"})
+		return
+	}
+
+	// Todas as imagens são validadas antes de qualquer gravação.
+	processadas := make([]*imagemProcessada, len(arquivos))
+	for i, arquivo := range arquivos {
+		p, err := processarImagem(arquivo, limite)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error(), "arquivo": arquivo.Filename})
+			return
+		}
+		processadas[i] = p
+	}
+
+	armazenamento := obterArmazenamento()
+	var gravados []string
+	imagens := make([]models.ProdutoImagem, len(processadas))
+	for i, p := range processadas {
+		nome, err := valorAleatorio(12)
+		if err != nil {
+			removerArquivosArmazenados(gravados)
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar imagem"})
+			return
+		}
+		prefixo := fmt.Sprintf("produtos/%d/%s", produtoID, nome)
+
+		img := models.ProdutoImagem{
+			ProdutoID:        produtoID,
+			Miniaturas:       map[string]string{},
+			TextoAlternativo: textoAlternativo,
+			TipoConteudo:     p.tipo,
+			Largura:          p.largura,
+			Altura:           p.altura,
+			TamanhoBytes:     len(p.conteudo),
+		}
+
+		chave := prefixo + "." + p.extensao
+		img.URL, err = armazenamento.Salvar(c.Request.Context(), chave, p.conteudo, p.tipo)
+		if err == nil {
+			gravados = append(gravados, chave)
+			img.Arquivos = append(img.Arquivos, chave)
+			for _, tamanho := range tamanhosMiniatura {
+				chave = prefixo + "-" + tamanho.nome + "." + p.extMiniatura
+				var url string
+				url, err = armazenamento.Salvar(c.Request.Context(), chave, p.miniaturas[tamanho.nome], p.tipoMiniatura)
+				if err != nil {
+					break
+				}
+				gravados = append(gravados, chave)
+				img.Arquivos = append(img.Arquivos, chave)
+				img.Miniaturas[tamanho.nome] = url
+			}
+		}
+		if err != nil {
+			log.Printf("ERRO: Falha ao gravar imagem do produto %d (%s): %v", produtoID, armazenamento.Nome(), err)
+			removerArquivosArmazenados(gravados)
+			c.JSON(http.StatusBadGateway, gin.H{"erro": "Erro ao salvar imagem no armazenamento"})
+			return
+		}
+		imagens[i] = img
+	}
+
+	if err := inserirImagensProduto(db, produtoID, imagens); err != nil {
+		removerArquivosArmazenados(gravados)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar imagens", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, imagens)
+}
+
+func inserirImagensProduto(db *sql.DB, produtoID int, imagens []models.ProdutoImagem) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Trava o produto para que envios simultâneos não repitam a mesma ordem.
+	if _, err := tx.Exec(`SELECT id FROM produtos WHERE id = $1 FOR UPDATE`, produtoID); err != nil {
+		return err
+	}
+	var ordem int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(ordem), -1) + 1 FROM produto_imagens WHERE produto_id = $1`, produtoID).Scan(&ordem); err != nil {
+		return err
+	}
+
+	for i := range imagens {
+		miniaturas, _ := json.Marshal(imagens[i].Miniaturas)
+		imagens[i].Ordem = ordem + i
+		err := tx.QueryRow(`
+			INSERT INTO produto_imagens (produto_id, url, miniaturas, texto_alternativo, tipo_conteudo, largura, altura, tamanho_bytes, ordem, arquivos)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10)
+			RETURNING id, criado_em`,
+			produtoID, imagens[i].URL, string(miniaturas), imagens[i].TextoAlternativo, imagens[i].TipoConteudo,
+			imagens[i].Largura, imagens[i].Altura, imagens[i].TamanhoBytes, imagens[i].Ordem, pq.Array(imagens[i].Arquivos)).
+			Scan(&imagens[i].ID, &imagens[i].CriadoEm)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := atualizarImagemPrincipal(tx, produtoID, ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReordenarImagensProduto recebe todos os IDs da galeria na nova ordem; a
+// primeira imagem passa a ser a imagem principal do produto.
+func ReordenarImagensProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var req models.ReordenarImagensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM produto_imagens WHERE produto_id = $1`, produtoID).Scan(&total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar imagens", "detalhes": err.Error()})
+		return
+	}
+	vistos := map[int]bool{}
+	for _, id := range req.IDs {
+		vistos[id] = true
+	}
+	if total != len(req.IDs) || len(vistos) != len(req.IDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Informe todas as imagens do produto, sem repetição"})
+		return
+	}
+
+	for ordem, id := range req.IDs {
+		result, err := tx.Exec(`UPDATE produto_imagens SET ordem = $1 WHERE id = $2 AND produto_id = $3`, ordem, id, produtoID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao reordenar imagens", "detalhes": err.Error()})
+			return
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("Imagem %d não pertence ao produto", id)})
+			return
+		}
+	}
+
+	if err := atualizarImagemPrincipal(tx, produtoID, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar imagem principal", "detalhes": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao confirmar transação"})
+		return
+	}
+
+	ListarImagensProduto(c)
+}
+
+func AtualizarImagemProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	var req models.AtualizarImagemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	img, err := scanProdutoImagem(db.QueryRow(`
+		UPDATE produto_imagens SET texto_alternativo = NULLIF($1, '')
+		WHERE id = $2 AND produto_id = $3
+		RETURNING `+colunasProdutoImagem, req.TextoAlternativo, c.Param("imagem_id"), c.Param("id")))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Imagem não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar imagem", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, img)
+}
+
+func DeletarImagemProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var url string
+	var arquivos []string
+	err = tx.QueryRow(`
+		DELETE FROM produto_imagens WHERE id = $1 AND produto_id = $2
+		RETURNING url, arquivos`, c.Param("imagem_id"), produtoID).
+		Scan(&url, pq.Array(&arquivos))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Imagem não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir imagem", "detalhes": err.Error()})
+		return
+	}
+
+	if err := atualizarImagemPrincipal(tx, produtoID, url); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar imagem principal", "detalhes": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao confirmar transação"})
+		return
+	}
+
+	removerArquivosArmazenados(arquivos)
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Imagem excluída com sucesso"})
+}
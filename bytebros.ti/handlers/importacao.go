@@ -0,0 +1,768 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// camposImportacao são as colunas reconhecidas na planilha de produtos, na
+// ordem usada pela exportação.
+var camposImportacao = []string{"sku", "nome", "preco", "quantidade", "oferta", "estoque_minimo", "detalhes", "imagem", "categorias"}
+
+const (
+	tamanhoMaximoImportacao = 10 << 20
+	maximoLinhasImportacao  = 10000
+	maximoErrosImportacao   = 1000
+)
+
+const colunasImportacao = `id, arquivo_nome, formato, simulacao, status, mapeamento, total_linhas, processadas,
+	criados, atualizados, com_erro, mensagem, ator_tipo, ator_id, ator_email, criado_em, iniciado_em, concluido_em`
+
+func scanImportacao(row linhaSQL, destinos ...interface{}) (models.Importacao, error) {
+	var imp models.Importacao
+	var mapeamento []byte
+	var mensagem, atorEmail sql.NullString
+	var atorID sql.NullInt64
+	err := row.Scan(append([]interface{}{&imp.ID, &imp.ArquivoNome, &imp.Formato, &imp.Simulacao, &imp.Status, &mapeamento,
+		&imp.TotalLinhas, &imp.Processadas, &imp.Criados, &imp.Atualizados, &imp.ComErro, &mensagem,
+		&imp.AtorTipo, &atorID, &atorEmail, &imp.CriadoEm, &imp.IniciadoEm, &imp.ConcluidoEm}, destinos...)...)
+	if len(mapeamento) > 0 {
+		json.Unmarshal(mapeamento, &imp.Mapeamento)
+	}
+	imp.Mensagem = mensagem.String
+	imp.AtorEmail = atorEmail.String
+	if atorID.Valid {
+		id := int(atorID.Int64)
+		imp.AtorID = &id
+	}
+	return imp, err
+}
+
+// linhaImportacao guarda apenas os campos preenchidos na planilha; campos
+// nulos mantêm o valor atual do produto (ou o padrão, na criação).
+type linhaImportacao struct {
+	sku, nome, detalhes, imagem *string
+	preco                       *float64
+	quantidade, estoqueMinimo   *int
+	oferta                      *bool
+	categorias                  []int
+}
+
+// colunasDaPlanilha associa cada campo ao índice da coluna no cabeçalho. O
+// mapeamento ({"campo": "Nome da coluna"}) tem prioridade; sem ele, vale a
+// coluna com o próprio nome do campo.
+func colunasDaPlanilha(cabecalho []string, mapeamento map[string]string) (map[string]int, error) {
+	indices := map[string]int{}
+	for i, nome := range cabecalho {
+		nome = strings.ToLower(strings.TrimSpace(nome))
+		if _, existe := indices[nome]; !existe {
+			indices[nome] = i
+		}
+	}
+
+	conhecidos := map[string]bool{}
+	for _, campo := range camposImportacao {
+		conhecidos[campo] = true
+	}
+
+	colunas := map[string]int{}
+	for campo, coluna := range mapeamento {
+		if !conhecidos[campo] {
+			return nil, fmt.Errorf("campo desconhecido no mapeamento: %s", campo)
+		}
+		indice, ok := indices[strings.ToLower(strings.TrimSpace(coluna))]
+		if !ok {
+			return nil, fmt.Errorf("coluna '%s' não encontrada na planilha", coluna)
+		}
+		colunas[campo] = indice
+	}
+	for _, campo := range camposImportacao {
+		if _, mapeado := mapeamento[campo]; mapeado {
+			continue
+		}
+		if indice, ok := indices[campo]; ok {
+			colunas[campo] = indice
+		}
+	}
+
+	_, temSKU := colunas["sku"]
+	_, temNome := colunas["nome"]
+	if !temSKU && !temNome {
+		return nil, errors.New("a planilha precisa de uma coluna 'sku' ou 'nome' (ou de um mapeamento para elas)")
+	}
+	return colunas, nil
+}
+
+// numeroPlanilha aceita "1234.5", "1.234,50" e "R$ 19,90".
+func numeroPlanilha(texto string) (float64, error) {
+	texto = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(texto), "R$"))
+	if strings.Contains(texto, ",") {
+		texto = strings.ReplaceAll(texto, ".", "")
+		texto = strings.Replace(texto, ",", ".", 1)
+	}
+	return strconv.ParseFloat(texto, 64)
+}
+
+func inteiroPlanilha(texto string) (int, error) {
+	numero, err := numeroPlanilha(texto)
+	if err != nil || numero != float64(int(numero)) {
+		return 0, errors.New("número inteiro inválido")
+	}
+	return int(numero), nil
+}
+
+func booleanoPlanilha(texto string) (bool, error) {
+	switch strings.ToLower(texto) {
+	case "true", "verdadeiro", "sim", "s", "1", "x":
+		return true, nil
+	case "false", "falso", "não", "nao", "n", "0":
+		return false, nil
+	}
+	return false, errors.New("use sim/não ou true/false")
+}
+
+// interpretarLinhaImportacao valida as células da linha. Categorias aceitam
+// IDs ou slugs separados por ";"; as já resolvidas ficam em cacheCategorias.
+func interpretarLinhaImportacao(db *sql.DB, colunas map[string]int, celulas []string, cacheCategorias map[string]int) (linhaImportacao, []models.ErroImportacao) {
+	var linha linhaImportacao
+	var erros []models.ErroImportacao
+	falha := func(campo, mensagem string) {
+		erros = append(erros, models.ErroImportacao{Campo: campo, Mensagem: mensagem})
+	}
+
+	for campo, indice := range colunas {
+		if indice >= len(celulas) {
+			continue
+		}
+		valor := strings.TrimSpace(celulas[indice])
+		if valor == "" {
+			continue
+		}
+
+		switch campo {
+		case "sku":
+			sku := normalizarSKU(valor)
+			if len(sku) > 64 {
+				falha(campo, "SKU deve ter no máximo 64 caracteres")
+				continue
+			}
+			linha.sku = &sku
+		case "nome":
+			linha.nome = &valor
+		case "detalhes":
+			linha.detalhes = &valor
+		case "imagem":
+			linha.imagem = &valor
+		case "preco":
+			preco, err := numeroPlanilha(valor)
+			if err != nil || preco < 0.01 {
+				falha(campo, "preço deve ser um número maior que zero")
+				continue
+			}
+			linha.preco = &preco
+		case "quantidade", "estoque_minimo":
+			numero, err := inteiroPlanilha(valor)
+			if err != nil || numero < 0 {
+				falha(campo, "deve ser um número inteiro maior ou igual a zero")
+				continue
+			}
+			if campo == "quantidade" {
+				linha.quantidade = &numero
+			} else {
+				linha.estoqueMinimo = &numero
+			}
+		case "oferta":
+			oferta, err := booleanoPlanilha(valor)
+			if err != nil {
+				falha(campo, err.Error())
+				continue
+			}
+			linha.oferta = &oferta
+		case "categorias":
+			linha.categorias = []int{}
+			for _, identificador := range strings.FieldsFunc(valor, func(r rune) bool { return r == ';' || r == '|' }) {
+				identificador = strings.TrimSpace(identificador)
+				id, ok := cacheCategorias[identificador]
+				if !ok {
+					var err error
+					id, err = resolverCategoriaID(db, identificador)
+					if err != nil {
+						if err == sql.ErrNoRows {
+							falha(campo, "categoria não encontrada: "+identificador)
+						} else {
+							falha(campo, "erro ao buscar categoria: "+err.Error())
+						}
+						continue
+					}
+					cacheCategorias[identificador] = id
+				}
+				linha.categorias = append(linha.categorias, id)
+			}
+		}
+	}
+
+	if linha.sku == nil && linha.nome == nil {
+		falha("", "informe o SKU ou o nome do produto")
+	}
+	return linha, erros
+}
+
+func movimentoImportacao(imp *models.Importacao, produtoID int, tipo string, quantidade int) *models.MovimentoEstoque {
+	return &models.MovimentoEstoque{
+		ProdutoID:  produtoID,
+		Tipo:       tipo,
+		Quantidade: quantidade,
+		Motivo:     fmt.Sprintf("Importação #%d", imp.ID),
+		AtorTipo:   imp.AtorTipo,
+		AtorID:     imp.AtorID,
+		AtorEmail:  imp.AtorEmail,
+	}
+}
+
+type consultaImportacao interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// localizarProdutoImportacao encontra o produto da linha pelo SKU ou, na falta
+// dele, pelo nome (entre os produtos ainda sem SKU, se a linha trouxer um).
+// Devolve sql.ErrNoRows quando a linha criaria um produto novo. Com bloquear,
+// a linha do produto fica travada até o fim da transação.
+func localizarProdutoImportacao(q consultaImportacao, linha linhaImportacao, bloquear bool) (produtoID, quantidadeAtual int, err error) {
+	trava := ""
+	if bloquear {
+		trava = ` FOR UPDATE`
+	}
+	err = sql.ErrNoRows
+	if linha.sku != nil {
+		err = q.QueryRow(`SELECT id, quantidade FROM produtos WHERE sku = $1`+trava, *linha.sku).Scan(&produtoID, &quantidadeAtual)
+	}
+	if err == sql.ErrNoRows && linha.nome != nil {
+		query := `SELECT id, quantidade FROM produtos WHERE LOWER(nome) = LOWER($1)`
+		if linha.sku != nil {
+			query += ` AND sku IS NULL`
+		}
+		rows, errBusca := q.Query(query+` LIMIT 2`+trava, *linha.nome)
+		if errBusca != nil {
+			return 0, 0, errBusca
+		}
+		encontrados := 0
+		for rows.Next() {
+			encontrados++
+			if errBusca = rows.Scan(&produtoID, &quantidadeAtual); errBusca != nil {
+				break
+			}
+		}
+		rows.Close()
+		if errBusca != nil {
+			return 0, 0, errBusca
+		}
+		switch encontrados {
+		case 0:
+			err = sql.ErrNoRows
+		case 1:
+			err = nil
+		default:
+			return 0, 0, errors.New("há mais de um produto com este nome; informe o SKU")
+		}
+	}
+	return produtoID, quantidadeAtual, err
+}
+
+var errImportacaoSemNomeOuPreco = errors.New("nome e preço são obrigatórios para criar um produto")
+
+// validarLinhaImportacao responde, sem gravar nem travar nada, se a linha
+// criaria ou atualizaria um produto. Usada na simulação.
+func validarLinhaImportacao(db *sql.DB, linha linhaImportacao) (criado bool, err error) {
+	produtoID, _, err := localizarProdutoImportacao(db, linha, false)
+	if err == sql.ErrNoRows {
+		if linha.nome == nil || linha.preco == nil {
+			return false, errImportacaoSemNomeOuPreco
+		}
+		criado = true
+	} else if err != nil {
+		return false, err
+	}
+
+	if linha.categorias != nil {
+		valores := map[string]interface{}{}
+		if !criado {
+			var dados []byte
+			if err := db.QueryRow(`SELECT especificacoes FROM produtos WHERE id = $1`, produtoID).Scan(&dados); err != nil {
+				return false, err
+			}
+			valores = lerValoresEspecificacoes(dados)
+		}
+		definicoes, err := carregarEspecificacoes(db, `SELECT unnest($1::int[])`, pq.Array(linha.categorias))
+		if err != nil {
+			return false, err
+		}
+		if problemas := revalidarEspecificacoes(definicoes, valores); len(problemas) > 0 {
+			return false, erroEspecificacoesImportacao(problemas)
+		}
+	}
+	return criado, nil
+}
+
+// erroEspecificacoesImportacao recusa a linha cujas categorias exigem
+// especificações que o produto não tem (a planilha não traz especificações).
+func erroEspecificacoesImportacao(problemas []string) error {
+	return errors.New("especificações inválidas para as categorias: " + strings.Join(problemas, "; "))
+}
+
+// aplicarLinhaImportacao atualiza o produto da linha ou, se não encontrar,
+// cria. Quantidades passam pelo livro de estoque.
+func aplicarLinhaImportacao(tx *sql.Tx, imp *models.Importacao, linha linhaImportacao) (criado bool, err error) {
+	produtoID, quantidadeAtual, err := localizarProdutoImportacao(tx, linha, true)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	if err == sql.ErrNoRows {
+		if linha.nome == nil || linha.preco == nil {
+			return false, errImportacaoSemNomeOuPreco
+		}
+		oferta := linha.oferta != nil && *linha.oferta
+		err = tx.QueryRow(`
+			INSERT INTO produtos (nome, sku, quantidade, preco, oferta, detalhes, imagem, estoque_minimo)
+			VALUES ($1, $2, 0, $3, $4, $5, $6, COALESCE($7, 0))
+			RETURNING id`,
+			*linha.nome, linha.sku, *linha.preco, oferta, linha.detalhes, linha.imagem, linha.estoqueMinimo).Scan(&produtoID)
+		if err != nil {
+			return false, err
+		}
+		if linha.quantidade != nil && *linha.quantidade > 0 {
+			if err := registrarMovimentoEstoque(tx, movimentoImportacao(imp, produtoID, "entrada", *linha.quantidade)); err != nil {
+				return false, err
+			}
+		}
+		criado = true
+	} else {
+		var sets []string
+		var args []interface{}
+		for _, campo := range []struct {
+			coluna string
+			valor  interface{}
+			existe bool
+		}{
+			{"nome", linha.nome, linha.nome != nil},
+			{"sku", linha.sku, linha.sku != nil},
+			{"preco", linha.preco, linha.preco != nil},
+			{"oferta", linha.oferta, linha.oferta != nil},
+			{"detalhes", linha.detalhes, linha.detalhes != nil},
+			{"imagem", linha.imagem, linha.imagem != nil},
+			{"estoque_minimo", linha.estoqueMinimo, linha.estoqueMinimo != nil},
+		} {
+			if campo.existe {
+				args = append(args, campo.valor)
+				sets = append(sets, fmt.Sprintf("%s = $%d", campo.coluna, len(args)))
+			}
+		}
+		if len(sets) > 0 {
+			args = append(args, produtoID)
+			if _, err := tx.Exec(fmt.Sprintf(`UPDATE produtos SET %s WHERE id = $%d`, strings.Join(sets, ", "), len(args)), args...); err != nil {
+				return false, err
+			}
+		}
+		if linha.quantidade != nil {
+			if diferenca := *linha.quantidade - quantidadeAtual; diferenca != 0 {
+				if err := registrarMovimentoEstoque(tx, movimentoImportacao(imp, produtoID, "ajuste", diferenca)); err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+
+	if linha.nome != nil {
+		if _, err := atribuirSlug(tx, "produtos", produtoID, *linha.nome); err != nil {
+			return false, err
+		}
+	}
+	if linha.categorias != nil {
+		if err := definirCategoriasProduto(tx, produtoID, linha.categorias); err != nil {
+			return false, err
+		}
+		problemas, err := problemasEspecificacoesProduto(tx, produtoID)
+		if err != nil {
+			return false, err
+		}
+		if len(problemas) > 0 {
+			return false, erroEspecificacoesImportacao(problemas)
+		}
+	}
+	if err := registrarHistoricoPrecos(tx, "importacao", []int{produtoID}); err != nil {
+		return false, err
+	}
+	return criado, nil
+}
+
+func mensagemErroImportacao(err error) string {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return "SKU já utilizado por outro produto"
+	}
+	return err.Error()
+}
+
+// processarImportacao roda em segundo plano. Cada linha válida é gravada na
+// sua própria transação curta, para que as travas de estoque não segurem os
+// pedidos durante a importação inteira e o erro de uma linha não desfaça as
+// demais. A simulação apenas valida as linhas, sem gravar nem travar produtos.
+func processarImportacao(db *sql.DB, imp models.Importacao, colunas map[string]int, linhas [][]string) {
+	imp.Erros = []models.ErroImportacao{}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ERRO: Importação #%d interrompida: %v", imp.ID, r)
+			finalizarImportacao(db, &imp, "falhou", fmt.Sprint(r))
+		}
+	}()
+
+	if _, err := db.Exec(`UPDATE importacoes SET status = 'processando', iniciado_em = CURRENT_TIMESTAMP WHERE id = $1`, imp.ID); err != nil {
+		log.Printf("ERRO BD: Falha ao iniciar importação #%d: %v", imp.ID, err)
+	}
+
+	cacheCategorias := map[string]int{}
+	chaves := map[string]int{}
+	for i, celulas := range linhas {
+		numero := i + 2
+		linha, erros := interpretarLinhaImportacao(db, colunas, celulas, cacheCategorias)
+
+		if len(erros) == 0 {
+			chave := ""
+			if linha.sku != nil {
+				chave = "sku:" + *linha.sku
+			} else {
+				chave = "nome:" + strings.ToLower(*linha.nome)
+			}
+			if anterior, repetida := chaves[chave]; repetida {
+				erros = append(erros, models.ErroImportacao{Mensagem: fmt.Sprintf("produto repetido (já aparece na linha %d)", anterior)})
+			} else {
+				chaves[chave] = numero
+			}
+		}
+
+		if len(erros) == 0 {
+			var criado bool
+			var err error
+			if imp.Simulacao {
+				criado, err = validarLinhaImportacao(db, linha)
+			} else {
+				criado, err = gravarLinhaImportacao(db, &imp, linha)
+			}
+			if err != nil {
+				erros = append(erros, models.ErroImportacao{Mensagem: mensagemErroImportacao(err)})
+			} else if criado {
+				imp.Criados++
+			} else {
+				imp.Atualizados++
+			}
+		}
+
+		if len(erros) > 0 {
+			imp.ComErro++
+			for _, e := range erros {
+				if len(imp.Erros) < maximoErrosImportacao {
+					e.Linha = numero
+					imp.Erros = append(imp.Erros, e)
+				}
+			}
+		}
+
+		imp.Processadas++
+		if imp.Processadas%100 == 0 {
+			db.Exec(`UPDATE importacoes SET processadas = $2 WHERE id = $1`, imp.ID, imp.Processadas)
+		}
+	}
+
+	finalizarImportacao(db, &imp, "concluida", "")
+}
+
+func gravarLinhaImportacao(db *sql.DB, imp *models.Importacao, linha linhaImportacao) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	criado, err := aplicarLinhaImportacao(tx, imp, linha)
+	if err != nil {
+		return false, err
+	}
+	return criado, tx.Commit()
+}
+
+// EncerrarImportacoesInterrompidas marca como falhas as importações que
+// ficaram pendentes ou em processamento quando o servidor parou: o arquivo só
+// existia na memória da goroutine, então não há como retomá-las. Deve rodar na
+// inicialização, antes de o servidor aceitar novas importações.
+func EncerrarImportacoesInterrompidas(db *sql.DB) {
+	resultado, err := db.Exec(`
+		UPDATE importacoes
+		SET status = 'falhou', mensagem = 'Importação interrompida pela reinicialização do servidor; envie o arquivo novamente',
+			concluido_em = CURRENT_TIMESTAMP
+		WHERE status IN ('pendente', 'processando')`)
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao encerrar importações interrompidas: %v", err)
+		return
+	}
+	if n, _ := resultado.RowsAffected(); n > 0 {
+		log.Printf("%d importação(ões) interrompida(s) marcada(s) como falha", n)
+	}
+}
+
+func finalizarImportacao(db *sql.DB, imp *models.Importacao, status, mensagem string) {
+	errosJSON, _ := json.Marshal(imp.Erros)
+	_, err := db.Exec(`
+		UPDATE importacoes
+		SET status = $2, processadas = $3, criados = $4, atualizados = $5, com_erro = $6,
+			erros = $7, mensagem = NULLIF($8, ''), concluido_em = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		imp.ID, status, imp.Processadas, imp.Criados, imp.Atualizados, imp.ComErro, errosJSON, mensagem)
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao finalizar importação #%d: %v", imp.ID, err)
+	}
+}
+
+// ImportarProdutos recebe uma planilha CSV ou XLSX (campo "arquivo"), valida
+// o cabeçalho e enfileira o processamento; o resultado é consultado em
+// ObterImportacao. Com simular=true nada é gravado, apenas validado.
+func ImportarProdutos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, tamanhoMaximoImportacao+(1<<20))
+	cabecalhoArquivo, err := c.FormFile("arquivo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Envie a planilha no campo 'arquivo'", "detalhes": err.Error()})
+		return
+	}
+	if cabecalhoArquivo.Size > tamanhoMaximoImportacao {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"erro": fmt.Sprintf("A planilha deve ter no máximo %d MB", tamanhoMaximoImportacao>>20)})
+		return
+	}
+	arquivo, err := cabecalhoArquivo.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Erro ao ler a planilha", "detalhes": err.Error()})
+		return
+	}
+	conteudo, err := io.ReadAll(arquivo)
+	arquivo.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Erro ao ler a planilha", "detalhes": err.Error()})
+		return
+	}
+
+	formato := strings.ToLower(c.PostForm("formato"))
+	if formato == "" {
+		switch strings.ToLower(filepath.Ext(cabecalhoArquivo.Filename)) {
+		case ".xlsx":
+			formato = "xlsx"
+		case ".csv", ".txt":
+			formato = "csv"
+		default:
+			formato = "csv"
+			if bytes.HasPrefix(conteudo, []byte("PK\x03\x04")) {
+				formato = "xlsx"
+			}
+		}
+	}
+	if formato != "csv" && formato != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Formato inválido. Use 'csv' ou 'xlsx'"})
+		return
+	}
+
+	linhas, err := lerPlanilha(conteudo, formato)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Não foi possível ler a planilha", "detalhes": err.Error()})
+		return
+	}
+	if len(linhas) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "A planilha não tem linhas de produtos"})
+		return
+	}
+	if len(linhas)-1 > maximoLinhasImportacao {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("A planilha deve ter no máximo %d linhas de produtos", maximoLinhasImportacao)})
+		return
+	}
+
+	mapeamento := map[string]string{}
+	if texto := c.PostForm("mapeamento"); texto != "" {
+		if err := json.Unmarshal([]byte(texto), &mapeamento); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Mapeamento inválido: envie um objeto JSON {\"campo\": \"coluna\"}", "detalhes": err.Error()})
+			return
+		}
+	}
+	colunas, err := colunasDaPlanilha(linhas[0], mapeamento)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Cabeçalho da planilha inválido", "detalhes": err.Error(), "campos": camposImportacao})
+		return
+	}
+	mapeamentoJSON, _ := json.Marshal(mapeamento)
+
+	imp := models.Importacao{
+		ArquivoNome: filepath.Base(cabecalhoArquivo.Filename),
+		Formato:     formato,
+		Simulacao:   c.PostForm("simular") == "true" || c.Query("simular") == "true",
+		Status:      "pendente",
+		Mapeamento:  mapeamento,
+		TotalLinhas: len(linhas) - 1,
+	}
+	atorTipo, atorID, atorEmail, _ := identificarAtor(c)
+	imp.AtorTipo, imp.AtorEmail = atorTipo, atorEmail
+	if atorID != 0 {
+		imp.AtorID = &atorID
+	}
+
+	err = db.QueryRow(`
+		INSERT INTO importacoes (arquivo_nome, formato, simulacao, mapeamento, total_linhas, ator_tipo, ator_id, ator_email)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+		RETURNING id, criado_em`,
+		imp.ArquivoNome, imp.Formato, imp.Simulacao, mapeamentoJSON, imp.TotalLinhas, imp.AtorTipo, imp.AtorID, imp.AtorEmail).
+		Scan(&imp.ID, &imp.CriadoEm)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar importação", "detalhes": err.Error()})
+		return
+	}
+
+	go processarImportacao(db, imp, colunas, linhas[1:])
+
+	c.JSON(http.StatusAccepted, imp)
+}
+
+func ListarImportacoes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	limite := 50
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= 200 {
+		limite = v
+	}
+	pagina := 1
+	if v, err := strconv.Atoi(c.Query("pagina")); err == nil && v > 0 {
+		pagina = v
+	}
+
+	rows, err := db.Query(`SELECT `+colunasImportacao+` FROM importacoes ORDER BY criado_em DESC, id DESC LIMIT $1 OFFSET $2`,
+		limite, (pagina-1)*limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar importações", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	importacoes := make([]models.Importacao, 0)
+	for rows.Next() {
+		imp, err := scanImportacao(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler importações", "detalhes": err.Error()})
+			return
+		}
+		importacoes = append(importacoes, imp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"importacoes": importacoes, "pagina": pagina, "limite": limite})
+}
+
+// ObterImportacao mostra o andamento e, ao final, os erros por linha.
+func ObterImportacao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var erros []byte
+	imp, err := scanImportacao(db.QueryRow(`SELECT `+colunasImportacao+`, erros FROM importacoes WHERE id = $1`, id), &erros)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Importação não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar importação", "detalhes": err.Error()})
+		return
+	}
+	imp.Erros = []models.ErroImportacao{}
+	if len(erros) > 0 {
+		json.Unmarshal(erros, &imp.Erros)
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// ExportarProdutos gera a planilha do catálogo no mesmo layout aceito pela
+// importação (a coluna id é apenas informativa). ?formato=csv (padrão) ou xlsx.
+func ExportarProdutos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	formato := strings.ToLower(c.DefaultQuery("formato", "csv"))
+	if formato != "csv" && formato != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Formato inválido. Use 'csv' ou 'xlsx'"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT p.id, COALESCE(p.sku, ''), p.nome, p.preco, p.quantidade, p.oferta, p.estoque_minimo,
+			COALESCE(p.detalhes, ''), COALESCE(p.imagem, ''),
+			COALESCE((
+				SELECT string_agg(c.slug, ';' ORDER BY c.slug)
+				FROM produto_categorias pc JOIN categorias c ON c.id = pc.categoria_id
+				WHERE pc.produto_id = p.id), '')
+		FROM produtos p
+		ORDER BY p.id`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produtos", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	linhas := [][]string{append([]string{"id"}, camposImportacao...)}
+	for rows.Next() {
+		var id, quantidade, estoqueMinimo int
+		var sku, nome, detalhes, imagem, categorias string
+		var preco float64
+		var oferta bool
+		if err := rows.Scan(&id, &sku, &nome, &preco, &quantidade, &oferta, &estoqueMinimo, &detalhes, &imagem, &categorias); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler produtos", "detalhes": err.Error()})
+			return
+		}
+		linhas = append(linhas, []string{
+			strconv.Itoa(id), sku, nome, strconv.FormatFloat(preco, 'f', 2, 64), strconv.Itoa(quantidade),
+			strconv.FormatBool(oferta), strconv.Itoa(estoqueMinimo), detalhes, imagem, categorias,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler produtos", "detalhes": err.Error()})
+		return
+	}
+
+	var arquivo bytes.Buffer
+	tipoConteudo := "text/csv; charset=utf-8"
+	if formato == "xlsx" {
+		tipoConteudo = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = escreverXLSX(&arquivo, "Produtos", linhas, map[int]bool{0: true, 3: true, 4: true, 6: true})
+	} else {
+		err = escreverCSV(&arquivo, linhas)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar planilha", "detalhes": err.Error()})
+		return
+	}
+
+	nomeArquivo := fmt.Sprintf("produtos-%s.%s", time.Now().Format("20060102-150405"), formato)
+	c.Header("Content-Disposition", `attachment; filename="`+nomeArquivo+`"`)
+	c.Data(http.StatusOK, tipoConteudo, arquivo.Bytes())
+}
@@ -0,0 +1,694 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	nomeAnonimizado     = "Titular anonimizado"
+	conteudoAnonimizado = "[conteúdo removido a pedido do titular - LGPD]"
+)
+
+func emailAnonimizado(usuarioID int) string {
+	return fmt.Sprintf("anonimizado-%d@anonimizado.invalid", usuarioID)
+}
+
+func ExportarDadosPessoais(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes podem exportar seus dados pessoais"})
+		return
+	}
+
+	exportacao, err := coletarDadosPessoais(db, usuarioID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Usuário não encontrado"})
+			return
+		}
+		log.Printf("ERRO BD: Falha ao coletar dados pessoais do usuário %d: %v", usuarioID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao exportar dados pessoais", "detalhes": err.Error()})
+		return
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO solicitacoes_lgpd (usuario_id, email, tipo, status, processado_em)
+		VALUES ($1, $2, 'exportacao', 'concluida', $3)`,
+		usuarioID, exportacao.Titular.Email, time.Now())
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao registrar exportação de dados do usuário %d: %v", usuarioID, err)
+	}
+
+	nomeArquivo := fmt.Sprintf("meus-dados-bytebros-%s", exportacao.GeradoEm.Format("20060102-150405"))
+
+	if c.Query("formato") != "zip" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, nomeArquivo))
+		c.JSON(http.StatusOK, exportacao)
+		return
+	}
+
+	secoes := []struct {
+		arquivo string
+		dados   interface{}
+	}{
+		{"titular.json", exportacao.Titular},
+		{"pedidos.json", exportacao.Pedidos},
+		{"suporte.json", exportacao.Suporte},
+		{"orcamentos.json", exportacao.Orcamentos},
+		{"consentimentos.json", exportacao.Consentimentos},
+		{"solicitacoes.json", exportacao.Solicitacoes},
+		{"enderecos.json", exportacao.Enderecos},
+		{"identidades_externas.json", exportacao.Identidades},
+		{"sessoes.json", exportacao.Sessoes},
+		{"avaliacoes.json", exportacao.Avaliacoes},
+		{"lista_desejos.json", exportacao.ListaDesejos},
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, nomeArquivo))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	for _, secao := range secoes {
+		w, err := zw.Create(secao.arquivo)
+		if err != nil {
+			log.Printf("ERRO: Falha ao criar %s no ZIP de exportação: %v", secao.arquivo, err)
+			return
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(secao.dados); err != nil {
+			log.Printf("ERRO: Falha ao escrever %s no ZIP de exportação: %v", secao.arquivo, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("ERRO: Falha ao finalizar ZIP de exportação: %v", err)
+	}
+}
+
+// coletarDadosPessoais reúne os dados do titular. Pedidos, suporte e orçamentos
+// são localizados pelo email atual do cadastro, não pelo do token, que pode
+// ser anterior a uma troca de email.
+func coletarDadosPessoais(db *sql.DB, usuarioID int) (*models.ExportacaoDadosPessoais, error) {
+	exportacao := &models.ExportacaoDadosPessoais{
+		GeradoEm:       time.Now(),
+		Pedidos:        make([]models.Pedido, 0),
+		Suporte:        make([]models.Suporte, 0),
+		Orcamentos:     make([]models.Orcamento, 0),
+		Consentimentos: make([]models.Consentimento, 0),
+		Solicitacoes:   make([]models.SolicitacaoLGPD, 0),
+		Enderecos:      make([]models.Endereco, 0),
+		Identidades:    make([]models.IdentidadeExterna, 0),
+		Sessoes:        make([]models.Sessao, 0),
+		Avaliacoes:     make([]models.Avaliacao, 0),
+		ListaDesejos:   make([]models.ItemListaDesejos, 0),
+	}
+
+	var telefone, cpf sql.NullString
+	var anonimizadoEm sql.NullTime
+	t := &exportacao.Titular
+	err := db.QueryRow(`
+		SELECT id, nome_completo, email, telefone, cpf, criado_em, atualizado_em, anonimizado_em
+		FROM usuarios
+		WHERE id = $1`, usuarioID).
+		Scan(&t.ID, &t.Nome, &t.Email, &telefone, &cpf, &t.CriadoEm, &t.AtualizadoEm, &anonimizadoEm)
+	if err != nil {
+		return nil, err
+	}
+	t.Telefone = telefone.String
+	t.CPF = cpf.String
+	email := t.Email
+
+	exportacao.Enderecos, err = listarEnderecos(db, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if anonimizadoEm.Valid {
+		t.AnonimizadoEm = &anonimizadoEm.Time
+	}
+
+	pedidoRows, err := db.Query(`
+		SELECT id, cliente_email, data_pedido, status, endereco_entrega, tipo_frete, valor_frete, valor_total, forma_pagamento, prazo_entrega, criado_em
+		FROM pedidos
+		WHERE cliente_email = $1
+		ORDER BY data_pedido DESC`, email)
+	if err != nil {
+		return nil, err
+	}
+	defer pedidoRows.Close()
+	for pedidoRows.Next() {
+		var p models.Pedido
+		var prazo sql.NullString
+		if err := pedidoRows.Scan(&p.ID, &p.ClienteEmail, &p.DataPedido, &p.Status, &p.EnderecoEntrega, &p.TipoFrete, &p.ValorFrete, &p.ValorTotal, &p.FormaPagamento, &prazo, &p.CriadoEm); err != nil {
+			return nil, err
+		}
+		p.PrazoEntrega = prazo.String
+		p.Itens = make([]models.PedidoItem, 0)
+		exportacao.Pedidos = append(exportacao.Pedidos, p)
+	}
+	if err := pedidoRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range exportacao.Pedidos {
+		itemRows, err := db.Query(`
+			SELECT `+colunasPedidoItem+`
+			FROM pedido_itens
+			WHERE pedido_id = $1`, exportacao.Pedidos[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for itemRows.Next() {
+			pi, err := scanPedidoItem(itemRows)
+			if err != nil {
+				itemRows.Close()
+				return nil, err
+			}
+			exportacao.Pedidos[i].Itens = append(exportacao.Pedidos[i].Itens, pi)
+		}
+		itemRows.Close()
+	}
+
+	suporteRows, err := db.Query(`
+		SELECT id, nome, email, mensagem, status, tipo_interacao, cliente_email, criado_em
+		FROM suporte
+		WHERE cliente_email = $1 OR email = $1
+		ORDER BY criado_em DESC`, email)
+	if err != nil {
+		return nil, err
+	}
+	defer suporteRows.Close()
+	for suporteRows.Next() {
+		var s models.Suporte
+		var clienteEmailSQL sql.NullString
+		if err := suporteRows.Scan(&s.ID, &s.Nome, &s.Email, &s.Mensagem, &s.Status, &s.TipoInteracao, &clienteEmailSQL, &s.CriadoEm); err != nil {
+			return nil, err
+		}
+		s.ClienteEmail = clienteEmailSQL.String
+		exportacao.Suporte = append(exportacao.Suporte, s)
+	}
+	if err := suporteRows.Err(); err != nil {
+		return nil, err
+	}
+
+	orcamentoRows, err := db.Query(`
+		SELECT id, nome_cliente, email_cliente, telefone, descricao, servico_nome, status, criado_em, atualizado_em
+		FROM orcamentos
+		WHERE email_cliente = $1
+		ORDER BY criado_em DESC`, email)
+	if err != nil {
+		return nil, err
+	}
+	defer orcamentoRows.Close()
+	for orcamentoRows.Next() {
+		var o models.Orcamento
+		var servicoNome sql.NullString
+		if err := orcamentoRows.Scan(&o.ID, &o.NomeCliente, &o.EmailCliente, &o.Telefone, &o.Descricao, &servicoNome, &o.Status, &o.CriadoEm, &o.AtualizadoEm); err != nil {
+			return nil, err
+		}
+		o.ServicoNome = servicoNome.String
+		exportacao.Orcamentos = append(exportacao.Orcamentos, o)
+	}
+	if err := orcamentoRows.Err(); err != nil {
+		return nil, err
+	}
+
+	exportacao.Consentimentos, err = listarConsentimentos(db, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+
+	solicitacaoRows, err := db.Query(`
+		SELECT id, usuario_id, email, tipo, status, motivo, observacao_admin, processado_por, processado_em, criado_em, atualizado_em
+		FROM solicitacoes_lgpd
+		WHERE usuario_id = $1
+		ORDER BY criado_em DESC`, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer solicitacaoRows.Close()
+	for solicitacaoRows.Next() {
+		s, err := scanSolicitacaoLGPD(solicitacaoRows)
+		if err != nil {
+			return nil, err
+		}
+		exportacao.Solicitacoes = append(exportacao.Solicitacoes, s)
+	}
+	if err := solicitacaoRows.Err(); err != nil {
+		return nil, err
+	}
+
+	identidadeRows, err := db.Query(`
+		SELECT provedor, email, criado_em, ultimo_login_em
+		FROM identidades_externas
+		WHERE usuario_id = $1
+		ORDER BY criado_em`, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer identidadeRows.Close()
+	for identidadeRows.Next() {
+		var ie models.IdentidadeExterna
+		var emailExterno sql.NullString
+		var ultimoLogin sql.NullTime
+		if err := identidadeRows.Scan(&ie.Provedor, &emailExterno, &ie.CriadoEm, &ultimoLogin); err != nil {
+			return nil, err
+		}
+		ie.Email = emailExterno.String
+		if ultimoLogin.Valid {
+			ie.UltimoLoginEm = &ultimoLogin.Time
+		}
+		exportacao.Identidades = append(exportacao.Identidades, ie)
+	}
+	if err := identidadeRows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessaoRows, err := db.Query(`
+		SELECT id, dispositivo, user_agent, ip, criado_em, ultima_atividade_em, expira_em
+		FROM sessoes
+		WHERE usuario_id = $1
+		ORDER BY criado_em`, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer sessaoRows.Close()
+	for sessaoRows.Next() {
+		var s models.Sessao
+		var dispositivo, userAgent, ip sql.NullString
+		if err := sessaoRows.Scan(&s.ID, &dispositivo, &userAgent, &ip, &s.CriadoEm, &s.UltimaAtividadeEm, &s.ExpiraEm); err != nil {
+			return nil, err
+		}
+		s.Dispositivo = dispositivo.String
+		s.UserAgent = userAgent.String
+		s.IP = ip.String
+		exportacao.Sessoes = append(exportacao.Sessoes, s)
+	}
+	if err := sessaoRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// LIMIT NULL devolve todas as avaliações.
+	exportacao.Avaliacoes, err = listarAvaliacoes(db, ` WHERE a.usuario_id = $1`, "a.criado_em", usuarioID, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	exportacao.ListaDesejos, err = listarItensListaDesejos(db, usuarioID)
+	return exportacao, err
+}
+
+func listarConsentimentos(db *sql.DB, usuarioID int) ([]models.Consentimento, error) {
+	rows, err := db.Query(`
+		SELECT id, usuario_id, email, finalidade, concedido, ip, user_agent, criado_em
+		FROM consentimentos
+		WHERE usuario_id = $1
+		ORDER BY criado_em DESC`, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consentimentos := make([]models.Consentimento, 0)
+	for rows.Next() {
+		var cs models.Consentimento
+		var ip, userAgent sql.NullString
+		if err := rows.Scan(&cs.ID, &cs.UsuarioID, &cs.Email, &cs.Finalidade, &cs.Concedido, &ip, &userAgent, &cs.CriadoEm); err != nil {
+			return nil, err
+		}
+		cs.IP = ip.String
+		cs.UserAgent = userAgent.String
+		consentimentos = append(consentimentos, cs)
+	}
+	return consentimentos, rows.Err()
+}
+
+type linhaSQL interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSolicitacaoLGPD(row linhaSQL) (models.SolicitacaoLGPD, error) {
+	var s models.SolicitacaoLGPD
+	var motivo, observacao sql.NullString
+	var processadoPor sql.NullInt64
+	var processadoEm sql.NullTime
+	err := row.Scan(&s.ID, &s.UsuarioID, &s.Email, &s.Tipo, &s.Status, &motivo, &observacao, &processadoPor, &processadoEm, &s.CriadoEm, &s.AtualizadoEm)
+	if err != nil {
+		return s, err
+	}
+	s.Motivo = motivo.String
+	s.ObservacaoAdmin = observacao.String
+	if processadoPor.Valid {
+		id := int(processadoPor.Int64)
+		s.ProcessadoPor = &id
+	}
+	if processadoEm.Valid {
+		s.ProcessadoEm = &processadoEm.Time
+	}
+	return s, nil
+}
+
+func ListarConsentimentos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes possuem registro de consentimentos"})
+		return
+	}
+
+	consentimentos, err := listarConsentimentos(db, usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar consentimentos", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, consentimentos)
+}
+
+func RegistrarConsentimento(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes podem registrar consentimentos"})
+		return
+	}
+
+	var req models.RegistrarConsentimentoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	var email string
+	if err := db.QueryRow(`SELECT email FROM usuarios WHERE id = $1 AND anonimizado_em IS NULL`, usuarioID).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Usuário não encontrado."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar usuário."})
+		}
+		return
+	}
+
+	consentimento := models.Consentimento{
+		UsuarioID:  usuarioID,
+		Email:      email,
+		Finalidade: req.Finalidade,
+		Concedido:  *req.Concedido,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+
+	err := db.QueryRow(`
+		INSERT INTO consentimentos (usuario_id, email, finalidade, concedido, ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, criado_em`,
+		consentimento.UsuarioID, consentimento.Email, consentimento.Finalidade, consentimento.Concedido, consentimento.IP, consentimento.UserAgent).
+		Scan(&consentimento.ID, &consentimento.CriadoEm)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar consentimento", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, consentimento)
+}
+
+func SolicitarExclusaoConta(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes podem solicitar a exclusão da conta"})
+		return
+	}
+
+	var req models.SolicitarExclusaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	// O email registrado na solicitação é o do cadastro, não o do token.
+	var senhaHashDB, email string
+	err := db.QueryRow(`SELECT senha_hash, email FROM usuarios WHERE id = $1 AND anonimizado_em IS NULL`, usuarioID).Scan(&senhaHashDB, &email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Usuário não encontrado."})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar usuário."})
+		}
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(senhaHashDB), []byte(req.Senha)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Senha incorreta."})
+		return
+	}
+
+	var pendentes int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM solicitacoes_lgpd
+		WHERE usuario_id = $1 AND tipo = 'exclusao' AND status IN ('pendente', 'aprovada')`, usuarioID).Scan(&pendentes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar solicitações existentes"})
+		return
+	}
+	if pendentes > 0 {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Já existe uma solicitação de exclusão em análise para esta conta."})
+		return
+	}
+
+	var solicitacaoID int
+	err = db.QueryRow(`
+		INSERT INTO solicitacoes_lgpd (usuario_id, email, tipo, status, motivo)
+		VALUES ($1, $2, 'exclusao', 'pendente', $3)
+		RETURNING id`,
+		usuarioID, email, sql.NullString{String: req.Motivo, Valid: req.Motivo != ""}).
+		Scan(&solicitacaoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar solicitação de exclusão", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"mensagem": "Solicitação de exclusão registrada. Ela será analisada pela nossa equipe.",
+		"id":       solicitacaoID,
+	})
+}
+
+func ListarMinhasSolicitacoesLGPD(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Apenas clientes possuem solicitações LGPD"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT id, usuario_id, email, tipo, status, motivo, observacao_admin, processado_por, processado_em, criado_em, atualizado_em
+		FROM solicitacoes_lgpd
+		WHERE usuario_id = $1
+		ORDER BY criado_em DESC`, usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar solicitações", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	solicitacoes := make([]models.SolicitacaoLGPD, 0)
+	for rows.Next() {
+		s, err := scanSolicitacaoLGPD(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler solicitações", "detalhes": err.Error()})
+			return
+		}
+		solicitacoes = append(solicitacoes, s)
+	}
+
+	c.JSON(http.StatusOK, solicitacoes)
+}
+
+func ListarSolicitacoesLGPD(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	status := c.Query("status")
+	tipo := c.Query("tipo")
+
+	query := `
+		SELECT id, usuario_id, email, tipo, status, motivo, observacao_admin, processado_por, processado_em, criado_em, atualizado_em
+		FROM solicitacoes_lgpd`
+	args := []interface{}{}
+	whereClauses := []string{}
+	argCounter := 1
+
+	if status != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf("status = $%d", argCounter))
+		args = append(args, status)
+		argCounter++
+	}
+	if tipo != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf("tipo = $%d", argCounter))
+		args = append(args, tipo)
+		argCounter++
+	}
+
+	if len(whereClauses) > 0 {
+		query += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	query += " ORDER BY criado_em ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar solicitações LGPD", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	solicitacoes := make([]models.SolicitacaoLGPD, 0)
+	for rows.Next() {
+		s, err := scanSolicitacaoLGPD(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler solicitações LGPD", "detalhes": err.Error()})
+			return
+		}
+		solicitacoes = append(solicitacoes, s)
+	}
+
+	c.JSON(http.StatusOK, solicitacoes)
+}
+
+func AvaliarSolicitacaoLGPD(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	id := c.Param("id")
+
+	var req models.AvaliarSolicitacaoLGPDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	adminID, _ := obterAdminID(c)
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	solicitacao, err := scanSolicitacaoLGPD(tx.QueryRow(`
+		SELECT id, usuario_id, email, tipo, status, motivo, observacao_admin, processado_por, processado_em, criado_em, atualizado_em
+		FROM solicitacoes_lgpd
+		WHERE id = $1
+		FOR UPDATE`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Solicitação não encontrada"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar solicitação", "detalhes": err.Error()})
+		}
+		return
+	}
+
+	if solicitacao.Status != "pendente" {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Esta solicitação já foi processada."})
+		return
+	}
+
+	novoStatus := req.Status
+	if req.Status == "aprovada" && solicitacao.Tipo == "exclusao" {
+		if err := anonimizarTitular(tx, solicitacao.UsuarioID); err != nil {
+			log.Printf("ERRO BD: Falha ao anonimizar usuário %d: %v", solicitacao.UsuarioID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao anonimizar dados do titular", "detalhes": err.Error()})
+			return
+		}
+		novoStatus = "concluida"
+	}
+
+	agora := time.Now()
+	_, err = tx.Exec(`
+		UPDATE solicitacoes_lgpd
+		SET status = $1, observacao_admin = $2, processado_por = $3, processado_em = $4, atualizado_em = $4
+		WHERE id = $5`,
+		novoStatus, sql.NullString{String: req.Observacao, Valid: req.Observacao != ""}, sql.NullInt64{Int64: int64(adminID), Valid: adminID != 0}, agora, solicitacao.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar solicitação", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao comitar transação"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Solicitação processada com sucesso", "status": novoStatus})
+}
+
+// anonimizarTitular remove os dados pessoais do titular mantendo os registros
+// fiscais dos pedidos (valores, itens e endereço de entrega), que passam a
+// referenciar um identificador pseudônimo no lugar do email.
+func anonimizarTitular(tx *sql.Tx, usuarioID int) error {
+	var email string
+	err := tx.QueryRow(`SELECT email FROM usuarios WHERE id = $1 AND anonimizado_em IS NULL FOR UPDATE`, usuarioID).Scan(&email)
+	if err != nil {
+		return err
+	}
+
+	anonimo := emailAnonimizado(usuarioID)
+	agora := time.Now()
+
+	statements := []struct {
+		descricao string
+		query     string
+		args      []interface{}
+	}{
+		{"pedidos", `UPDATE pedidos SET cliente_email = $1 WHERE cliente_email = $2`, []interface{}{anonimo, email}},
+		{"suporte", `
+			UPDATE suporte
+			SET nome = $1, email = $2, mensagem = $3,
+				cliente_email = CASE WHEN cliente_email IS NULL THEN NULL ELSE $2 END
+			WHERE email = $4 OR cliente_email = $4`, []interface{}{nomeAnonimizado, anonimo, conteudoAnonimizado, email}},
+		{"orcamentos", `
+			UPDATE orcamentos
+			SET nome_cliente = $1, email_cliente = $2, telefone = '', descricao = $3, atualizado_em = $4
+			WHERE email_cliente = $5`, []interface{}{nomeAnonimizado, anonimo, conteudoAnonimizado, agora, email}},
+		{"consentimentos", `UPDATE consentimentos SET email = $1, ip = NULL, user_agent = NULL WHERE usuario_id = $2`, []interface{}{anonimo, usuarioID}},
+		{"solicitacoes_lgpd", `UPDATE solicitacoes_lgpd SET email = $1 WHERE usuario_id = $2`, []interface{}{anonimo, usuarioID}},
+		{"identidades_externas", `DELETE FROM identidades_externas WHERE usuario_id = $1`, []interface{}{usuarioID}},
+		{"sessoes", `DELETE FROM sessoes WHERE usuario_id = $1`, []interface{}{usuarioID}},
+		{"enderecos", `DELETE FROM enderecos WHERE usuario_id = $1`, []interface{}{usuarioID}},
+		{"estoque_movimentos", `UPDATE estoque_movimentos SET ator_email = NULL WHERE ator_tipo = 'cliente' AND ator_id = $1`, []interface{}{usuarioID}},
+		{"notificacoes", `DELETE FROM notificacoes WHERE destinatario_tipo = 'cliente' AND destinatario_id = $1`, []interface{}{usuarioID}},
+		{"avaliacoes", `DELETE FROM avaliacoes WHERE usuario_id = $1`, []interface{}{usuarioID}},
+		{"lista_desejos", `DELETE FROM lista_desejos WHERE usuario_id = $1`, []interface{}{usuarioID}},
+		{"usuarios", `
+			UPDATE usuarios
+			SET nome_completo = $1, email = $2, telefone = '', cpf = NULL, senha_hash = '!', atualizado_em = $3, anonimizado_em = $3
+			WHERE id = $4`, []interface{}{nomeAnonimizado, anonimo, agora, usuarioID}},
+	}
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return fmt.Errorf("erro ao anonimizar %s: %w", stmt.descricao, err)
+		}
+	}
+
+	return nil
+}
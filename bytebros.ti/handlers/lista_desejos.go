@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// enfileirarAvisosDisponibilidade marca para aviso os clientes que pediram
+// para saber quando o produto voltasse, se a entrada m levou o estoque total
+// (produto mais variantes ativas) de zero para positivo. O envio fica com a
+// tarefa periódica, fora da transação do movimento.
+func enfileirarAvisosDisponibilidade(tx *sql.Tx, m *models.MovimentoEstoque) error {
+	if m.Quantidade <= 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		WITH estoque AS (
+			SELECT `+sqlEstoqueTotal+` AS depois,
+				`+sqlEstoqueTotal+` - CASE
+					WHEN $3::int IS NULL OR EXISTS (SELECT 1 FROM produto_variantes WHERE id = $3 AND ativo) THEN $2
+					ELSE 0 END AS antes
+			FROM produtos p
+			WHERE p.id = $1
+		)
+		UPDATE lista_desejos l SET aviso_pendente = true
+		FROM estoque
+		WHERE l.produto_id = $1 AND l.avisar_disponibilidade AND NOT l.aviso_pendente
+			AND estoque.antes <= 0 AND estoque.depois > 0`, m.ProdutoID, m.Quantidade, m.VarianteID)
+	return err
+}
+
+// intervaloAvisosDisponibilidade lê AVISOS_ESTOQUE_MINUTOS (padrão 1; 0 desativa).
+func intervaloAvisosDisponibilidade() time.Duration {
+	minutos := 1
+	if v, err := strconv.Atoi(os.Getenv("AVISOS_ESTOQUE_MINUTOS")); err == nil && v >= 0 {
+		minutos = v
+	}
+	return time.Duration(minutos) * time.Minute
+}
+
+type avisoDisponibilidade struct {
+	usuarioID int
+	email     string
+	nome      string
+	produtoID int
+	produto   string
+}
+
+// enviarAvisosDisponibilidade entrega os avisos enfileirados de produtos que
+// continuam em estoque: manda um email por cliente e grava a notificação no
+// painel. Os avisos são retirados da fila antes do envio, para que duas
+// execuções não mandem o mesmo email; se o envio falhar, voltam para a fila e
+// são tentados de novo na próxima execução. Para ser avisado de novo depois de
+// uma entrega, o cliente reativa o aviso na lista de desejos.
+func enviarAvisosDisponibilidade(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `
+		UPDATE lista_desejos l
+		SET aviso_pendente = false, avisar_disponibilidade = false
+		FROM produtos p, usuarios u
+		WHERE l.aviso_pendente AND p.id = l.produto_id AND u.id = l.usuario_id
+			AND u.anonimizado_em IS NULL AND `+sqlEstoqueTotal+` > 0
+		RETURNING l.usuario_id, u.email, u.nome_completo, p.id, p.nome`)
+	if err != nil {
+		return fmt.Errorf("retirar avisos de disponibilidade da fila: %w", err)
+	}
+	var avisos []avisoDisponibilidade
+	for rows.Next() {
+		var a avisoDisponibilidade
+		if err := rows.Scan(&a.usuarioID, &a.email, &a.nome, &a.produtoID, &a.produto); err != nil {
+			rows.Close()
+			return err
+		}
+		avisos = append(avisos, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(avisos) == 0 {
+		return nil
+	}
+
+	porCliente := map[int][]avisoDisponibilidade{}
+	var ordem []int
+	for _, a := range avisos {
+		if _, ok := porCliente[a.usuarioID]; !ok {
+			ordem = append(ordem, a.usuarioID)
+		}
+		porCliente[a.usuarioID] = append(porCliente[a.usuarioID], a)
+	}
+	entregues := 0
+	for _, usuarioID := range ordem {
+		lista := porCliente[usuarioID]
+		produtoIDs := make([]int64, len(lista))
+		for i, a := range lista {
+			produtoIDs[i] = int64(a.produtoID)
+		}
+
+		if err := enviarEmailAvisosDisponibilidade(ctx, lista); err != nil {
+			log.Printf("ERRO: Falha ao enviar aviso de disponibilidade ao cliente %d; aviso volta para a fila: %v", usuarioID, err)
+			// Só volta o que continua como foi deixado: se o cliente mexeu no
+			// item nesse meio-tempo, vale a escolha dele. Sem cancelamento, para
+			// não perder o aviso quando o envio falhou pelo desligamento.
+			if _, err := db.ExecContext(context.WithoutCancel(ctx), `
+				UPDATE lista_desejos SET aviso_pendente = true, avisar_disponibilidade = true
+				WHERE usuario_id = $1 AND produto_id = ANY($2) AND NOT avisar_disponibilidade AND NOT aviso_pendente`,
+				usuarioID, pq.Array(produtoIDs)); err != nil {
+				log.Printf("ERRO BD: Falha ao devolver avisos do cliente %d para a fila: %v", usuarioID, err)
+			}
+			continue
+		}
+
+		if err := registrarAvisosEntregues(ctx, db, usuarioID, lista, produtoIDs); err != nil {
+			log.Printf("ERRO BD: Falha ao registrar avisos entregues ao cliente %d: %v", usuarioID, err)
+			continue
+		}
+		entregues += len(lista)
+	}
+	log.Printf("Avisos de disponibilidade: %d enviado(s) de %d", entregues, len(avisos))
+	return nil
+}
+
+// enviarEmailAvisosDisponibilidade manda um único email com os produtos do cliente.
+func enviarEmailAvisosDisponibilidade(ctx context.Context, lista []avisoDisponibilidade) error {
+	saudacao := "Olá!"
+	if nomes := strings.Fields(lista[0].nome); len(nomes) > 0 {
+		saudacao = "Olá, " + nomes[0] + "!"
+	}
+	var corpo strings.Builder
+	fmt.Fprintf(&corpo, "%s\n\nVocê pediu para ser avisado quando estes produtos voltassem ao estoque:\n\n", saudacao)
+	for _, a := range lista {
+		fmt.Fprintf(&corpo, "- %s (#%d)\n", a.produto, a.produtoID)
+	}
+	corpo.WriteString("\nCorra, o estoque pode acabar de novo.\n")
+
+	assunto := "De volta ao estoque: " + lista[0].produto
+	if len(lista) > 1 {
+		assunto = fmt.Sprintf("%d produtos da sua lista de desejos voltaram ao estoque", len(lista))
+	}
+	envio, cancelar := context.WithTimeout(ctx, 30*time.Second)
+	defer cancelar()
+	return obterMailer().Enviar(envio, []string{lista[0].email}, assunto, corpo.String())
+}
+
+// registrarAvisosEntregues grava a data da entrega e as notificações do painel
+// depois que o email saiu.
+func registrarAvisosEntregues(ctx context.Context, db *sql.DB, usuarioID int, lista []avisoDisponibilidade, produtoIDs []int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE lista_desejos SET avisado_em = CURRENT_TIMESTAMP
+		WHERE usuario_id = $1 AND produto_id = ANY($2)`, usuarioID, pq.Array(produtoIDs)); err != nil {
+		return err
+	}
+	for _, a := range lista {
+		err := criarNotificacao(tx, "cliente", a.usuarioID, "produto_disponivel",
+			"De volta ao estoque: "+a.produto,
+			a.produto+" está disponível novamente.",
+			gin.H{"produto_id": a.produtoID})
+		if err != nil {
+			return fmt.Errorf("notificar cliente %d: %w", a.usuarioID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// listarItensListaDesejos devolve a lista do cliente com o preço efetivo e a
+// disponibilidade atuais de cada produto.
+func listarItensListaDesejos(db *sql.DB, usuarioID int) ([]models.ItemListaDesejos, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.nome, p.imagem, p.preco, p.oferta, `+sqlProdutoEmEstoque+`,
+			l.avisar_disponibilidade, l.avisado_em, l.criado_em
+		FROM lista_desejos l
+		JOIN produtos p ON p.id = l.produto_id
+		WHERE l.usuario_id = $1
+		ORDER BY l.criado_em DESC`, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	itens := make([]models.ItemListaDesejos, 0)
+	var produtos []models.Produto
+	for rows.Next() {
+		var item models.ItemListaDesejos
+		var imagem sql.NullString
+		var avisadoEm sql.NullTime
+		if err := rows.Scan(&item.ProdutoID, &item.Nome, &imagem, &item.Preco, &item.Oferta, &item.EmEstoque,
+			&item.AvisarDisponibilidade, &avisadoEm, &item.CriadoEm); err != nil {
+			return nil, err
+		}
+		item.Imagem = imagem.String
+		if avisadoEm.Valid {
+			item.AvisadoEm = &avisadoEm.Time
+		}
+		itens = append(itens, item)
+		produtos = append(produtos, models.Produto{ID: item.ProdutoID, Preco: item.Preco, Oferta: item.Oferta})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := aplicarPromocoesProdutos(db, produtos); err != nil {
+		return nil, err
+	}
+	for i := range itens {
+		itens[i].PrecoEfetivo = produtos[i].PrecoEfetivo
+		itens[i].Oferta = produtos[i].Oferta
+	}
+	return itens, nil
+}
+
+func ListarListaDesejos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Lista de desejos disponível apenas para clientes"})
+		return
+	}
+
+	itens, err := listarItensListaDesejos(db, usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar lista de desejos", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, itens)
+}
+
+// AdicionarListaDesejos inclui o produto na lista ou, se já estiver nela,
+// atualiza o pedido de aviso de disponibilidade.
+func AdicionarListaDesejos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Lista de desejos disponível apenas para clientes"})
+		return
+	}
+
+	var req models.ItemListaDesejosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	var existe bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM produtos WHERE id = $1)`, req.ProdutoID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+
+	var criado bool
+	err := db.QueryRow(`
+		INSERT INTO lista_desejos (usuario_id, produto_id, avisar_disponibilidade)
+		VALUES ($1, $2, COALESCE($3, false))
+		ON CONFLICT (usuario_id, produto_id) DO UPDATE
+		SET avisar_disponibilidade = COALESCE($3, lista_desejos.avisar_disponibilidade),
+			aviso_pendente = lista_desejos.aviso_pendente AND COALESCE($3, true)
+		RETURNING xmax = 0`, usuarioID, req.ProdutoID, req.AvisarDisponibilidade).Scan(&criado)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar lista de desejos", "detalhes": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if criado {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"mensagem": "Lista de desejos atualizada com sucesso", "produto_id": req.ProdutoID})
+}
+
+func RemoverListaDesejos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Lista de desejos disponível apenas para clientes"})
+		return
+	}
+
+	result, err := db.Exec(`DELETE FROM lista_desejos WHERE usuario_id = $1 AND produto_id = $2`, usuarioID, c.Param("produto_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao remover da lista de desejos", "detalhes": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não está na lista de desejos"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Produto removido da lista de desejos"})
+}
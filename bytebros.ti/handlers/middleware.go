@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
 	"os"
 	"strings"
@@ -13,6 +14,13 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := extractToken(c)
 		if tokenString == "" {
+			if chave := c.GetHeader("X-API-Key"); chave != "" {
+				if autenticarChaveAPI(c, chave) {
+					c.Next()
+				}
+				return
+			}
+
 			c.JSON(http.StatusUnauthorized, gin.H{"erro": "Token não fornecido"})
 			c.Abort()
 			return
@@ -38,6 +46,9 @@ func AuthMiddleware() gin.HandlerFunc {
 			if cargo, exists := claims["cargo"]; exists {
 				c.Set("cargo", cargo)
 			}
+			if sid, ok := claims["sid"].(string); ok && !validarSessao(c, sid) {
+				return
+			}
 		}
 
 		c.Next()
@@ -56,6 +67,54 @@ func FuncMiddleware() gin.HandlerFunc {
 	}
 }
 
+// FuncionarioMiddleware libera a rota para qualquer funcionário ativo,
+// independentemente do cargo. O cargo do token precisa ser o cargo atual do
+// cadastro, para que uma troca de cargo invalide os tokens antigos.
+func FuncionarioMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		funcionarioID, ok := obterFuncionarioLogado(c)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"erro": "Acesso restrito a funcionários"})
+			c.Abort()
+			return
+		}
+		cargoToken, _ := c.Get("cargo")
+
+		db := c.MustGet("db").(*sql.DB)
+		var ativo bool
+		var cargo string
+		if err := db.QueryRow(`SELECT ativo, cargo FROM funcionarios WHERE id = $1`, funcionarioID).Scan(&ativo, &cargo); err != nil || !ativo {
+			c.JSON(http.StatusForbidden, gin.H{"erro": "Funcionário inexistente ou desativado"})
+			c.Abort()
+			return
+		}
+		if cargo == "" || cargoToken != cargo {
+			c.JSON(http.StatusForbidden, gin.H{"erro": "Cargo do funcionário alterado; faça login novamente"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// obterFuncionarioLogado retorna o ID do funcionário quando o token pertence a um
+// funcionário (tokens de funcionário carregam o claim "cargo").
+func obterFuncionarioLogado(c *gin.Context) (int, bool) {
+	if _, exists := c.Get("cargo"); !exists {
+		return 0, false
+	}
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return 0, false
+	}
+	id, ok := userID.(float64)
+	if !ok {
+		return 0, false
+	}
+	return int(id), true
+}
+
 func extractToken(c *gin.Context) string {
 	bearerToken := c.GetHeader("Authorization")
 	if strings.HasPrefix(bearerToken, "Bearer ") {
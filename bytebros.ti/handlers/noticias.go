@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"bytebros.ti/models"
@@ -32,7 +33,14 @@ func CriarNoticia(c *gin.Context) {
 		Data:      time.Now(),
 	}
 
-	err := db.QueryRow(`
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
         INSERT INTO noticias (titulo, subtitulo, conteudo, autor, data)
         VALUES ($1, $2, $3, $4, $5)
         RETURNING id`,
@@ -44,6 +52,16 @@ func CriarNoticia(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criar notícia"})
 		return
 	}
+
+	if noticia.Slug, err = atribuirSlug(tx, "noticias", int(noticia.ID), noticia.Titulo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar notícia"})
+		return
+	}
 	log.Printf("DEBUG: Notícia criada com ID: %d", noticia.ID)
 	c.JSON(http.StatusCreated, noticia)
 	log.Println("DEBUG: Resposta CriarNoticia enviada.")
@@ -53,7 +71,7 @@ func ListarNoticias(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 
 	rows, err := db.Query(`
-        SELECT id, titulo, subtitulo, conteudo, autor, data
+        SELECT id, titulo, slug, subtitulo, conteudo, autor, data
         FROM noticias
         ORDER BY data DESC`)
 	if err != nil {
@@ -65,7 +83,7 @@ func ListarNoticias(c *gin.Context) {
 	var noticias []models.Noticia
 	for rows.Next() {
 		var n models.Noticia
-		if err := rows.Scan(&n.ID, &n.Titulo, &n.Subtitulo, &n.Conteudo, &n.Autor, &n.Data); err != nil {
+		if err := rows.Scan(&n.ID, &n.Titulo, &n.Slug, &n.Subtitulo, &n.Conteudo, &n.Autor, &n.Data); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler notícias"})
 			return
 		}
@@ -75,16 +93,20 @@ func ListarNoticias(c *gin.Context) {
 	c.JSON(http.StatusOK, noticias)
 }
 
+// ObterNoticia aceita o ID ou o slug da notícia.
 func ObterNoticia(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
-	id := c.Param("id")
+	id, ok := identificadorDaRota(c, db, "noticias", "Notícia não encontrada")
+	if !ok {
+		return
+	}
 
 	var noticia models.Noticia
 	err := db.QueryRow(`
-        SELECT id, titulo, subtitulo, conteudo, autor, data
+        SELECT id, titulo, slug, subtitulo, conteudo, autor, data
         FROM noticias
         WHERE id = $1`, id).
-		Scan(&noticia.ID, &noticia.Titulo, &noticia.Subtitulo, &noticia.Conteudo, &noticia.Autor, &noticia.Data)
+		Scan(&noticia.ID, &noticia.Titulo, &noticia.Slug, &noticia.Subtitulo, &noticia.Conteudo, &noticia.Autor, &noticia.Data)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -109,17 +131,45 @@ func AtualizarNoticia(c *gin.Context) {
 
 	db := c.MustGet("db").(*sql.DB)
 
-	_, err := db.Exec(`
+	noticiaID, err := strconv.Atoi(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
         UPDATE noticias
         SET titulo = $1, subtitulo = $2, conteudo = $3, autor = $4
         WHERE id = $5`,
-		noticiaReq.Titulo, noticiaReq.Subtitulo, noticiaReq.Conteudo, noticiaReq.Autor, id)
+		noticiaReq.Titulo, noticiaReq.Subtitulo, noticiaReq.Conteudo, noticiaReq.Autor, noticiaID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar notícia"})
 		return
 	}
 
+	// Um título novo gera outro slug; o anterior continua redirecionando.
+	if _, err := atribuirSlug(tx, "noticias", noticiaID, noticiaReq.Titulo); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Notícia não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar notícia"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"mensagem": "Notícia atualizada com sucesso"})
 }
 
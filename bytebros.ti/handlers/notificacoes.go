@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// executorSQL é satisfeito por *sql.DB e *sql.Tx.
+type executorSQL interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// criarNotificacao grava uma notificação no painel do destinatário. dados é
+// serializado como JSON e pode ser nil.
+func criarNotificacao(db executorSQL, destinatarioTipo string, destinatarioID int, tipo, titulo, mensagem string, dados interface{}) error {
+	var dadosJSON []byte
+	if dados != nil {
+		var err error
+		if dadosJSON, err = json.Marshal(dados); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec(`
+		INSERT INTO notificacoes (destinatario_tipo, destinatario_id, tipo, titulo, mensagem, dados)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		destinatarioTipo, destinatarioID, tipo, titulo, mensagem, dadosJSON)
+	return err
+}
+
+// destinatarioNotificacoes identifica o dono das notificações pelo token.
+// Chaves de API não têm caixa de notificações.
+func destinatarioNotificacoes(c *gin.Context) (string, int, bool) {
+	tipo, id, _, _ := identificarAtor(c)
+	switch tipo {
+	case "admin", "funcionario", "cliente":
+		return tipo, id, true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"erro": "Notificações disponíveis apenas para usuários autenticados"})
+	return "", 0, false
+}
+
+// ListarNotificacoes devolve as notificações do usuário logado, das mais
+// recentes para as mais antigas. ?nao_lidas=true filtra as pendentes.
+func ListarNotificacoes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	destinatarioTipo, destinatarioID, ok := destinatarioNotificacoes(c)
+	if !ok {
+		return
+	}
+
+	limite := 50
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= 200 {
+		limite = v
+	}
+	pagina := 1
+	if v, err := strconv.Atoi(c.Query("pagina")); err == nil && v > 0 {
+		pagina = v
+	}
+
+	filtro := ""
+	if c.Query("nao_lidas") == "true" {
+		filtro = " AND lida_em IS NULL"
+	}
+
+	rows, err := db.Query(`
+		SELECT id, tipo, titulo, mensagem, dados, lida_em, criado_em
+		FROM notificacoes
+		WHERE destinatario_tipo = $1 AND destinatario_id = $2`+filtro+`
+		ORDER BY criado_em DESC, id DESC
+		LIMIT $3 OFFSET $4`, destinatarioTipo, destinatarioID, limite, (pagina-1)*limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar notificações", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	notificacoes := make([]models.Notificacao, 0)
+	for rows.Next() {
+		var n models.Notificacao
+		var dados []byte
+		if err := rows.Scan(&n.ID, &n.Tipo, &n.Titulo, &n.Mensagem, &dados, &n.LidaEm, &n.CriadoEm); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler notificações", "detalhes": err.Error()})
+			return
+		}
+		if len(dados) > 0 {
+			n.Dados = json.RawMessage(dados)
+		}
+		notificacoes = append(notificacoes, n)
+	}
+
+	var naoLidas int
+	if err := db.QueryRow(`
+		SELECT COUNT(*) FROM notificacoes
+		WHERE destinatario_tipo = $1 AND destinatario_id = $2 AND lida_em IS NULL`,
+		destinatarioTipo, destinatarioID).Scan(&naoLidas); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao contar notificações", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notificacoes": notificacoes, "nao_lidas": naoLidas, "pagina": pagina, "limite": limite})
+}
+
+func MarcarNotificacaoLida(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	destinatarioTipo, destinatarioID, ok := destinatarioNotificacoes(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE notificacoes SET lida_em = COALESCE(lida_em, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND destinatario_tipo = $2 AND destinatario_id = $3`,
+		id, destinatarioTipo, destinatarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar notificação", "detalhes": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Notificação não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Notificação marcada como lida"})
+}
+
+func MarcarTodasNotificacoesLidas(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	destinatarioTipo, destinatarioID, ok := destinatarioNotificacoes(c)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE notificacoes SET lida_em = CURRENT_TIMESTAMP
+		WHERE destinatario_tipo = $1 AND destinatario_id = $2 AND lida_em IS NULL`,
+		destinatarioTipo, destinatarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar notificações", "detalhes": err.Error()})
+		return
+	}
+	atualizadas, _ := result.RowsAffected()
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Notificações marcadas como lidas", "atualizadas": atualizadas})
+}
+
+// notificarEquipe cria a mesma notificação para todos os administradores
+// ativos e para os funcionários ativos dos cargos informados (todos, se
+// cargos for vazio).
+func notificarEquipe(db executorSQL, cargos []string, tipo, titulo, mensagem string, dados interface{}) error {
+	var dadosJSON []byte
+	if dados != nil {
+		var err error
+		if dadosJSON, err = json.Marshal(dados); err != nil {
+			return err
+		}
+	}
+	if cargos == nil {
+		cargos = []string{}
+	}
+	_, err := db.Exec(`
+		INSERT INTO notificacoes (destinatario_tipo, destinatario_id, tipo, titulo, mensagem, dados)
+		SELECT d.tipo, d.id, $1, $2, $3, $4
+		FROM (
+			SELECT 'admin' AS tipo, id FROM admin WHERE ativo
+			UNION ALL
+			SELECT 'funcionario', id FROM funcionarios
+			WHERE ativo AND (cardinality($5::text[]) = 0 OR LOWER(cargo) = ANY($5::text[]))
+		) d`, tipo, titulo, mensagem, dadosJSON, pq.Array(cargos))
+	return err
+}
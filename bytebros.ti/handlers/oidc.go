@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const validadeEstadoOIDC = 10 * time.Minute
+
+// cookieEstadoOIDC guarda o state no navegador que iniciou o login. Sem ele o
+// callback é recusado, o que impede alguém de enviar à vítima o link de
+// callback de um fluxo iniciado por outra pessoa (login CSRF).
+const cookieEstadoOIDC = "oidc_state"
+
+func caminhoCookieEstadoOIDC(provedor string) string {
+	return "/api/auth/oidc/" + provedor
+}
+
+func definirCookieEstadoOIDC(c *gin.Context, provedor, valor string, validade int) {
+	segura := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cookieEstadoOIDC, valor, validade, caminhoCookieEstadoOIDC(provedor), "", segura, true)
+}
+
+func valorAleatorio(tamanho int) (string, error) {
+	b := make([]byte, tamanho)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IniciarLoginOIDC gera state, nonce e code_verifier, guarda-os no banco (e o
+// state também num cookie) e redireciona o navegador para a tela de login do provedor.
+func IniciarLoginOIDC(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	provedor, err := obterProvedorOIDC(c.Param("provedor"))
+	if err != nil {
+		log.Printf("AVISO: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Provedor de login não disponível"})
+		return
+	}
+
+	state, errState := valorAleatorio(24)
+	nonce, errNonce := valorAleatorio(24)
+	codeVerifier, errVerifier := valorAleatorio(48)
+	if errState != nil || errNonce != nil || errVerifier != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar login"})
+		return
+	}
+
+	urlAutorizacao, err := provedor.URLAutorizacao(c.Request.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		log.Printf("ERRO: Falha ao montar URL de autorização OIDC (%s): %v", provedor.Nome, err)
+		c.JSON(http.StatusBadGateway, gin.H{"erro": "Erro ao comunicar com o provedor de login"})
+		return
+	}
+
+	if _, err := db.Exec(`DELETE FROM oidc_estados WHERE expira_em < $1`, time.Now()); err != nil {
+		log.Printf("ERRO BD: Falha ao limpar estados OIDC expirados: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO oidc_estados (state, provedor, code_verifier, nonce, expira_em)
+		VALUES ($1, $2, $3, $4, $5)`,
+		state, provedor.Nome, codeVerifier, nonce, time.Now().Add(validadeEstadoOIDC))
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao gravar estado OIDC: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar login"})
+		return
+	}
+	definirCookieEstadoOIDC(c, provedor.Nome, state, int(validadeEstadoOIDC.Seconds()))
+
+	if c.Query("formato") == "json" {
+		c.JSON(http.StatusOK, gin.H{"url": urlAutorizacao})
+		return
+	}
+	c.Redirect(http.StatusFound, urlAutorizacao)
+}
+
+// CallbackOIDC conclui o login: confere o state com o cookie e o banco, troca o código pelo ID token,
+// vincula a identidade externa a um usuário e emite o mesmo token de LoginUsuario.
+func CallbackOIDC(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	if erro := c.Query("error"); erro != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Login cancelado ou negado pelo provedor", "detalhes": erro})
+		return
+	}
+
+	provedor, err := obterProvedorOIDC(c.Param("provedor"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Provedor de login não disponível"})
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Parâmetros 'state' e 'code' são obrigatórios"})
+		return
+	}
+
+	cookie, _ := c.Cookie(cookieEstadoOIDC)
+	definirCookieEstadoOIDC(c, provedor.Nome, "", -1)
+	if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Sessão de login inválida ou expirada. Tente novamente."})
+		return
+	}
+
+	var codeVerifier, nonce string
+	var expiraEm time.Time
+	err = db.QueryRow(`
+		DELETE FROM oidc_estados
+		WHERE state = $1 AND provedor = $2
+		RETURNING code_verifier, nonce, expira_em`, state, provedor.Nome).
+		Scan(&codeVerifier, &nonce, &expiraEm)
+	if err != nil || expiraEm.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Sessão de login inválida ou expirada. Tente novamente."})
+		return
+	}
+
+	identidade, err := provedor.TrocarCodigo(c.Request.Context(), code, codeVerifier, nonce)
+	if err != nil {
+		log.Printf("ERRO: Falha ao validar login OIDC (%s): %v", provedor.Nome, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Não foi possível validar o login com o provedor"})
+		return
+	}
+
+	user, err := vincularIdentidadeOIDC(db, provedor.Nome, identidade)
+	if err != nil {
+		if err == errEmailNaoVerificado {
+			c.JSON(http.StatusForbidden, gin.H{"erro": "O provedor não confirmou a verificação do seu email"})
+			return
+		}
+		log.Printf("ERRO BD: Falha ao vincular identidade OIDC (%s/%s): %v", provedor.Nome, identidade.Sujeito, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao concluir login"})
+		return
+	}
+
+	token, err := iniciarSessaoUsuario(c, db, user.ID, user.Email)
+	if err != nil {
+		log.Printf("ERRO: Falha ao gerar token JWT para usuário %s: %v", user.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.LoginResponse{
+		ID:       user.ID,
+		Nome:     user.Nome,
+		Email:    user.Email,
+		Token:    token,
+		Telefone: user.Telefone,
+	})
+}
+
+var errEmailNaoVerificado = errors.New("email não verificado pelo provedor")
+
+// vincularIdentidadeOIDC resolve o usuário da identidade externa: primeiro pelo
+// vínculo existente, depois pelo email verificado e, por fim, criando a conta.
+func vincularIdentidadeOIDC(db *sql.DB, provedor string, identidade *identidadeOIDC) (models.Usuario, error) {
+	var user models.Usuario
+
+	tx, err := db.Begin()
+	if err != nil {
+		return user, err
+	}
+	defer tx.Rollback()
+
+	var telefone sql.NullString
+	err = tx.QueryRow(`
+		SELECT u.id, u.nome_completo, u.email, u.telefone
+		FROM identidades_externas ie
+		JOIN usuarios u ON u.id = ie.usuario_id
+		WHERE ie.provedor = $1 AND ie.sujeito = $2 AND u.anonimizado_em IS NULL`,
+		provedor, identidade.Sujeito).
+		Scan(&user.ID, &user.Nome, &user.Email, &telefone)
+	if err == nil {
+		user.Telefone = telefone.String
+		if _, err := tx.Exec(`UPDATE identidades_externas SET ultimo_login_em = $1 WHERE provedor = $2 AND sujeito = $3`, time.Now(), provedor, identidade.Sujeito); err != nil {
+			return user, err
+		}
+		return user, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return user, err
+	}
+
+	if identidade.Email == "" || !identidade.EmailVerificado {
+		return user, errEmailNaoVerificado
+	}
+
+	err = tx.QueryRow(`
+		SELECT id, nome_completo, email, telefone
+		FROM usuarios
+		WHERE LOWER(email) = LOWER($1) AND anonimizado_em IS NULL`, identidade.Email).
+		Scan(&user.ID, &user.Nome, &user.Email, &telefone)
+	if err == sql.ErrNoRows {
+		nome := identidade.Nome
+		if nome == "" {
+			nome = strings.SplitN(identidade.Email, "@", 2)[0]
+		}
+
+		// A conta criada pelo login social não tem senha utilizável; o cliente
+		// pode continuar entrando pelo provedor.
+		senhaAleatoria, errSenha := valorAleatorio(32)
+		if errSenha != nil {
+			return user, errSenha
+		}
+		hashedPassword, errHash := gerarHashSenha(senhaAleatoria)
+		if errHash != nil {
+			return user, errHash
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO usuarios (nome_completo, email, senha_hash, telefone)
+			VALUES ($1, $2, $3, '')
+			RETURNING id, nome_completo, email, telefone`,
+			nome, identidade.Email, hashedPassword).
+			Scan(&user.ID, &user.Nome, &user.Email, &telefone)
+	}
+	if err != nil {
+		return user, err
+	}
+	user.Telefone = telefone.String
+
+	_, err = tx.Exec(`
+		INSERT INTO identidades_externas (usuario_id, provedor, sujeito, email, ultimo_login_em)
+		VALUES ($1, $2, $3, $4, $5)`,
+		user.ID, provedor, identidade.Sujeito, identidade.Email, time.Now())
+	if err != nil {
+		return user, err
+	}
+
+	return user, tx.Commit()
+}
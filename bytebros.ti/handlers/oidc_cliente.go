@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// provedorOIDC é um cliente OpenID Connect genérico (fluxo authorization code
+// com PKCE). A configuração vem de variáveis de ambiente com o prefixo
+// OIDC_<NOME>_, o que permite apontar o issuer para um provedor local de testes.
+type provedorOIDC struct {
+	Nome         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Escopos      []string
+
+	mu         sync.Mutex
+	descoberta *documentoDescobertaOIDC
+	chaves     map[string]interface{}
+	chavesEm   time.Time
+}
+
+type documentoDescobertaOIDC struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type respostaTokenOIDC struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	Erro        string `json:"error"`
+	ErroDescr   string `json:"error_description"`
+}
+
+// identidadeOIDC reúne os claims do ID token usados para vincular a conta.
+type identidadeOIDC struct {
+	Sujeito         string
+	Email           string
+	EmailVerificado bool
+	Nome            string
+}
+
+var (
+	httpClienteOIDC = &http.Client{Timeout: 10 * time.Second}
+
+	provedoresOIDCMu sync.Mutex
+	provedoresOIDC   = map[string]*provedorOIDC{}
+)
+
+// obterProvedorOIDC carrega (uma única vez) a configuração do provedor a partir
+// das variáveis OIDC_<NOME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL e
+// _ESCOPOS. O provedor precisa constar em OIDC_PROVEDORES.
+func obterProvedorOIDC(nome string) (*provedorOIDC, error) {
+	nome = strings.ToLower(nome)
+
+	habilitado := false
+	for _, p := range strings.Split(os.Getenv("OIDC_PROVEDORES"), ",") {
+		if strings.TrimSpace(strings.ToLower(p)) == nome {
+			habilitado = true
+			break
+		}
+	}
+	if !habilitado {
+		return nil, fmt.Errorf("provedor OIDC não habilitado: %s", nome)
+	}
+
+	provedoresOIDCMu.Lock()
+	defer provedoresOIDCMu.Unlock()
+
+	if p, ok := provedoresOIDC[nome]; ok {
+		return p, nil
+	}
+
+	prefixo := "OIDC_" + strings.ToUpper(nome) + "_"
+	p := &provedorOIDC{
+		Nome:         nome,
+		Issuer:       strings.TrimSuffix(os.Getenv(prefixo+"ISSUER"), "/"),
+		ClientID:     os.Getenv(prefixo + "CLIENT_ID"),
+		ClientSecret: os.Getenv(prefixo + "CLIENT_SECRET"),
+		RedirectURL:  os.Getenv(prefixo + "REDIRECT_URL"),
+		Escopos:      []string{"openid", "email", "profile"},
+	}
+	if escopos := os.Getenv(prefixo + "ESCOPOS"); escopos != "" {
+		p.Escopos = strings.Fields(strings.ReplaceAll(escopos, ",", " "))
+	}
+	if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+		return nil, fmt.Errorf("configuração incompleta para o provedor OIDC %s", nome)
+	}
+
+	provedoresOIDC[nome] = p
+	return p, nil
+}
+
+func (p *provedorOIDC) obterDescoberta(ctx context.Context) (*documentoDescobertaOIDC, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.descoberta != nil {
+		return p.descoberta, nil
+	}
+
+	var doc documentoDescobertaOIDC
+	if err := obterJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("erro na descoberta OIDC: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("issuer divergente na descoberta OIDC: %s", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("documento de descoberta OIDC incompleto")
+	}
+
+	p.descoberta = &doc
+	return p.descoberta, nil
+}
+
+// URLAutorizacao monta a URL de login do provedor com state, nonce e o desafio PKCE (S256).
+func (p *provedorOIDC) URLAutorizacao(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	doc, err := p.obterDescoberta(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	desafio := sha256.Sum256([]byte(codeVerifier))
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Escopos, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(desafio[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separador := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separador = "&"
+	}
+	return doc.AuthorizationEndpoint + separador + params.Encode(), nil
+}
+
+// TrocarCodigo troca o código de autorização pelo ID token e devolve a identidade validada.
+func (p *provedorOIDC) TrocarCodigo(ctx context.Context, code, codeVerifier, nonce string) (*identidadeOIDC, error) {
+	doc, err := p.obterDescoberta(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClienteOIDC.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao chamar token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tokenResp respostaTokenOIDC
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("resposta inválida do token endpoint: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tokenResp.Erro != "" {
+		return nil, fmt.Errorf("token endpoint retornou %d: %s %s", resp.StatusCode, tokenResp.Erro, tokenResp.ErroDescr)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, errors.New("token endpoint não retornou id_token")
+	}
+
+	return p.validarIDToken(ctx, doc, tokenResp.IDToken, nonce)
+}
+
+func (p *provedorOIDC) validarIDToken(ctx context.Context, doc *documentoDescobertaOIDC, idToken, nonce string) (*identidadeOIDC, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}))
+	token, err := parser.Parse(idToken, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.obterChavePublica(ctx, doc.JWKSURI, kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("id_token inválido: %v", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("claims do id_token inválidos")
+	}
+	if !claims.VerifyIssuer(p.Issuer, true) && !claims.VerifyIssuer(p.Issuer+"/", true) {
+		return nil, errors.New("issuer do id_token não confere")
+	}
+	if !claims.VerifyAudience(p.ClientID, true) {
+		return nil, errors.New("audience do id_token não confere")
+	}
+	// O parser só confere exp quando presente; no OIDC ele é obrigatório.
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id_token sem claim exp")
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, errors.New("nonce do id_token não confere")
+	}
+
+	identidade := &identidadeOIDC{}
+	identidade.Sujeito, _ = claims["sub"].(string)
+	identidade.Email, _ = claims["email"].(string)
+	identidade.Nome, _ = claims["name"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identidade.EmailVerificado = v
+	case string:
+		identidade.EmailVerificado = v == "true"
+	}
+	if identidade.Sujeito == "" {
+		return nil, errors.New("id_token sem claim sub")
+	}
+
+	return identidade, nil
+}
+
+// obterChavePublica busca a chave no JWKS do provedor, recarregando o conjunto
+// quando o kid não é conhecido (rotação de chaves) ou o cache tem mais de 1 hora.
+func (p *provedorOIDC) obterChavePublica(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if chave, ok := p.chaves[kid]; ok && time.Since(p.chavesEm) < time.Hour {
+		return chave, nil
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := obterJSON(ctx, jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("erro ao obter JWKS: %w", err)
+	}
+
+	chaves := map[string]interface{}{}
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			chaves[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curva elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curva = elliptic.P256()
+			case "P-384":
+				curva = elliptic.P384()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			chaves[k.Kid] = &ecdsa.PublicKey{Curve: curva, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	p.chaves = chaves
+	p.chavesEm = time.Now()
+
+	if chave, ok := chaves[kid]; ok {
+		return chave, nil
+	}
+	// Sem kid no cabeçalho: aceita quando o JWKS possui uma única chave.
+	if kid == "" && len(chaves) == 1 {
+		for _, chave := range chaves {
+			return chave, nil
+		}
+	}
+	return nil, fmt.Errorf("chave %q não encontrada no JWKS", kid)
+}
+
+func obterJSON(ctx context.Context, endereco string, destino interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endereco, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClienteOIDC.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s retornou %d", endereco, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(destino)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// emissorOIDCFalso é um provedor OpenID Connect mínimo (descoberta, JWKS e
+// token endpoint com PKCE) para exercitar o cliente sem rede externa.
+type emissorOIDCFalso struct {
+	servidor *httptest.Server
+	chave    *rsa.PrivateKey
+	kid      string
+
+	mu      sync.Mutex
+	codigos map[string]string // código -> code_challenge
+	claims  jwt.MapClaims     // claims do próximo id_token emitido
+}
+
+func novoEmissorOIDCFalso(t *testing.T) *emissorOIDCFalso {
+	t.Helper()
+	chave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("gerar chave RSA: %v", err)
+	}
+	e := &emissorOIDCFalso{chave: chave, kid: "chave-1", codigos: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(documentoDescobertaOIDC{
+			Issuer:                e.servidor.URL,
+			AuthorizationEndpoint: e.servidor.URL + "/authorize",
+			TokenEndpoint:         e.servidor.URL + "/token",
+			JWKSURI:               e.servidor.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": e.kid,
+				"kty": "RSA",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(chave.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(chave.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		e.mu.Lock()
+		desafio, ok := e.codigos[r.PostForm.Get("code")]
+		delete(e.codigos, r.PostForm.Get("code"))
+		claims := e.claims
+		e.mu.Unlock()
+
+		soma := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("grant_type") != "authorization_code" || !ok ||
+			base64.RawURLEncoding.EncodeToString(soma[:]) != desafio {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(respostaTokenOIDC{
+			AccessToken: "acesso",
+			IDToken:     e.assinar(t, claims, e.chave),
+			TokenType:   "Bearer",
+		})
+	})
+	e.servidor = httptest.NewServer(mux)
+	t.Cleanup(e.servidor.Close)
+	return e
+}
+
+// autorizar simula o login no provedor: guarda o desafio PKCE da URL de
+// autorização e devolve o código que o callback receberia.
+func (e *emissorOIDCFalso) autorizar(t *testing.T, urlAutorizacao string) string {
+	t.Helper()
+	u, err := url.Parse(urlAutorizacao)
+	if err != nil {
+		t.Fatalf("URL de autorização inválida: %v", err)
+	}
+	if u.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, esperado S256", u.Query().Get("code_challenge_method"))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.codigos["codigo-1"] = u.Query().Get("code_challenge")
+	return "codigo-1"
+}
+
+func (e *emissorOIDCFalso) assinar(t *testing.T, claims jwt.MapClaims, chave *rsa.PrivateKey) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = e.kid
+	assinado, err := token.SignedString(chave)
+	if err != nil {
+		t.Fatalf("assinar id_token: %v", err)
+	}
+	return assinado
+}
+
+func (e *emissorOIDCFalso) claimsValidos(nonce string) jwt.MapClaims {
+	agora := time.Now()
+	return jwt.MapClaims{
+		"iss":            e.servidor.URL,
+		"aud":            "cliente-teste",
+		"sub":            "usuario-123",
+		"email":          "cliente@exemplo.com",
+		"email_verified": true,
+		"name":           "Cliente Teste",
+		"nonce":          nonce,
+		"iat":            agora.Unix(),
+		"exp":            agora.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func (e *emissorOIDCFalso) provedor() *provedorOIDC {
+	return &provedorOIDC{
+		Nome:        "teste",
+		Issuer:      e.servidor.URL,
+		ClientID:    "cliente-teste",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/teste/callback",
+		Escopos:     []string{"openid", "email", "profile"},
+	}
+}
+
+func TestTrocarCodigoComPKCE(t *testing.T) {
+	emissor := novoEmissorOIDCFalso(t)
+	provedor := emissor.provedor()
+	ctx := context.Background()
+
+	endereco, err := provedor.URLAutorizacao(ctx, "estado", "nonce-1", "verificador-secreto")
+	if err != nil {
+		t.Fatalf("URLAutorizacao: %v", err)
+	}
+	codigo := emissor.autorizar(t, endereco)
+	emissor.claims = emissor.claimsValidos("nonce-1")
+
+	identidade, err := provedor.TrocarCodigo(ctx, codigo, "verificador-secreto", "nonce-1")
+	if err != nil {
+		t.Fatalf("TrocarCodigo: %v", err)
+	}
+	if identidade.Sujeito != "usuario-123" || identidade.Email != "cliente@exemplo.com" ||
+		!identidade.EmailVerificado || identidade.Nome != "Cliente Teste" {
+		t.Errorf("identidade inesperada: %+v", identidade)
+	}
+}
+
+func TestTrocarCodigoRecusaVerificadorPKCEIncorreto(t *testing.T) {
+	emissor := novoEmissorOIDCFalso(t)
+	provedor := emissor.provedor()
+	ctx := context.Background()
+
+	endereco, err := provedor.URLAutorizacao(ctx, "estado", "nonce-1", "verificador-secreto")
+	if err != nil {
+		t.Fatalf("URLAutorizacao: %v", err)
+	}
+	codigo := emissor.autorizar(t, endereco)
+	emissor.claims = emissor.claimsValidos("nonce-1")
+
+	if _, err := provedor.TrocarCodigo(ctx, codigo, "outro-verificador", "nonce-1"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("esperado erro invalid_grant, obtido %v", err)
+	}
+}
+
+func TestValidarIDTokenRecusaTokensInvalidos(t *testing.T) {
+	emissor := novoEmissorOIDCFalso(t)
+	provedor := emissor.provedor()
+	ctx := context.Background()
+
+	doc, err := provedor.obterDescoberta(ctx)
+	if err != nil {
+		t.Fatalf("descoberta: %v", err)
+	}
+
+	outraChave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("gerar chave RSA: %v", err)
+	}
+
+	casos := []struct {
+		nome    string
+		alterar func(jwt.MapClaims)
+		chave   *rsa.PrivateKey
+	}{
+		{"assinatura de outra chave", func(jwt.MapClaims) {}, outraChave},
+		{"audience errada", func(c jwt.MapClaims) { c["aud"] = "outro-cliente" }, nil},
+		{"issuer errado", func(c jwt.MapClaims) { c["iss"] = "https://emissor.invalido" }, nil},
+		{"expirado", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, nil},
+		{"sem exp", func(c jwt.MapClaims) { delete(c, "exp") }, nil},
+		{"nonce divergente", func(c jwt.MapClaims) { c["nonce"] = "nonce-de-outra-sessao" }, nil},
+		{"sem sub", func(c jwt.MapClaims) { delete(c, "sub") }, nil},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			claims := emissor.claimsValidos("nonce-1")
+			caso.alterar(claims)
+			chave := caso.chave
+			if chave == nil {
+				chave = emissor.chave
+			}
+			idToken := emissor.assinar(t, claims, chave)
+			if _, err := provedor.validarIDToken(ctx, doc, idToken, "nonce-1"); err == nil {
+				t.Fatal("id_token inválido foi aceito")
+			}
+		})
+	}
+
+	t.Run("algoritmo none", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, emissor.claimsValidos("nonce-1"))
+		idToken, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatalf("assinar: %v", err)
+		}
+		if _, err := provedor.validarIDToken(ctx, doc, idToken, "nonce-1"); err == nil {
+			t.Fatal("id_token sem assinatura foi aceito")
+		}
+	})
+
+	t.Run("token válido", func(t *testing.T) {
+		idToken := emissor.assinar(t, emissor.claimsValidos("nonce-1"), emissor.chave)
+		if _, err := provedor.validarIDToken(ctx, doc, idToken, "nonce-1"); err != nil {
+			t.Fatalf("id_token válido recusado: %v", err)
+		}
+	})
+}
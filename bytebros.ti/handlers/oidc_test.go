@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// O callback é recusado antes de consultar o banco quando o state da URL não
+// vem acompanhado do cookie gravado em IniciarLoginOIDC.
+func TestCallbackOIDCExigeCookieDoState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("OIDC_PROVEDORES", "csrf")
+	t.Setenv("OIDC_CSRF_ISSUER", "http://127.0.0.1:1")
+	t.Setenv("OIDC_CSRF_CLIENT_ID", "cliente")
+	t.Setenv("OIDC_CSRF_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/csrf/callback")
+
+	casos := []struct {
+		nome   string
+		cookie string
+	}{
+		{"sem cookie", ""},
+		{"cookie de outro fluxo", "state-do-navegador-da-vitima"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/csrf/callback?state=state-do-atacante&code=codigo", nil)
+			if caso.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: cookieEstadoOIDC, Value: caso.cookie})
+			}
+			c.Params = gin.Params{{Key: "provedor", Value: "csrf"}}
+			// Sem banco: a recusa precisa acontecer antes de qualquer consulta.
+			c.Set("db", (*sql.DB)(nil))
+
+			CallbackOIDC(c)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, esperado 400; corpo: %s", w.Code, w.Body.String())
+			}
+			apagado := w.Header().Get("Set-Cookie")
+			if !strings.HasPrefix(apagado, cookieEstadoOIDC+"=;") || !strings.Contains(apagado, "Max-Age=0") {
+				t.Errorf("cookie do state não foi apagado: %q", apagado)
+			}
+		})
+	}
+}
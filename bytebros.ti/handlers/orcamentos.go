@@ -120,11 +120,20 @@ func AtualizarStatusOrcamento(c *gin.Context) {
 		return
 	}
 
-	_, err := db.Exec(`
+	var err error
+	if funcionarioID, ok := obterFuncionarioLogado(c); ok {
+		_, err = db.Exec(`
+		UPDATE orcamentos
+		SET status = $1, atualizado_em = $2, processado_por = $3, processado_em = $2
+		WHERE id = $4`,
+			req.Status, time.Now(), funcionarioID, id)
+	} else {
+		_, err = db.Exec(`
 		UPDATE orcamentos
 		SET status = $1, atualizado_em = $2
 		WHERE id = $3`,
-		req.Status, time.Now(), id)
+			req.Status, time.Now(), id)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar status do orçamento", "detalhes": err.Error()})
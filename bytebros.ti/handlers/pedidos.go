@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -26,6 +27,31 @@ func CriarPedido(c *gin.Context) {
 		return
 	}
 
+	if req.EnderecoID == nil && req.Endereco == nil && strings.TrimSpace(req.EnderecoEntrega) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Informe 'endereco_id', 'endereco' ou 'endereco_entrega'"})
+		return
+	}
+
+	// Endereço estruturado enviado no próprio pedido passa pela mesma
+	// normalização por CEP do catálogo de endereços.
+	enderecoEntrega := req.EnderecoEntrega
+	if req.EnderecoID == nil && req.Endereco != nil {
+		if err := normalizarEndereco(c.Request.Context(), db, req.Endereco); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+			return
+		}
+		enderecoEntrega = formatarEndereco(models.Endereco{
+			Destinatario: req.Endereco.Destinatario,
+			CEP:          req.Endereco.CEP,
+			Logradouro:   req.Endereco.Logradouro,
+			Numero:       req.Endereco.Numero,
+			Complemento:  req.Endereco.Complemento,
+			Bairro:       req.Endereco.Bairro,
+			Cidade:       req.Endereco.Cidade,
+			Estado:       req.Endereco.Estado,
+		})
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação do pedido"})
@@ -33,12 +59,37 @@ func CriarPedido(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	// Pedidos de clientes guardam o dono; os demais ficam só com o email.
+	usuarioID, _, ehCliente := obterUsuarioLogado(c)
+	usuarioPedido := sql.NullInt64{Int64: int64(usuarioID), Valid: ehCliente}
+
+	// Com endereco_id o endereço do catálogo do cliente é copiado para o pedido,
+	// de modo que editar o endereço depois não altera pedidos já feitos.
+	var enderecoID sql.NullInt64
+	if req.EnderecoID != nil {
+		if !ehCliente {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "'endereco_id' disponível apenas para clientes"})
+			return
+		}
+		endereco, err := obterEnderecoUsuario(tx, usuarioID, *req.EnderecoID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusBadRequest, gin.H{"erro": "Endereço não encontrado"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar endereço", "detalhes": err.Error()})
+			return
+		}
+		enderecoEntrega = formatarEndereco(endereco)
+		enderecoID = sql.NullInt64{Int64: int64(endereco.ID), Valid: true}
+	}
+
 	var pedidoID int
 	err = tx.QueryRow(`
-		INSERT INTO pedidos (cliente_email, status, endereco_entrega, tipo_frete, valor_frete, valor_total, forma_pagamento, prazo_entrega)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO pedidos (cliente_email, usuario_id, status, endereco_entrega, endereco_id, tipo_frete, valor_frete, valor_total, forma_pagamento, prazo_entrega)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id`,
-		clienteEmailStr, "Processando", req.EnderecoEntrega, req.TipoFrete, req.ValorFrete, req.ValorTotal, req.FormaPagamento, req.PrazoEntrega).
+		clienteEmailStr, usuarioPedido, "Processando", enderecoEntrega, enderecoID, req.TipoFrete, req.ValorFrete, req.ValorTotal, req.FormaPagamento, req.PrazoEntrega).
 		Scan(&pedidoID)
 
 	if err != nil {
@@ -47,16 +98,45 @@ func CriarPedido(c *gin.Context) {
 	}
 
 	for _, itemReq := range req.Itens {
+		// O SKU é copiado para o item para que o pedido continue legível mesmo
+		// se a variante for alterada ou excluída depois.
+		var varianteID sql.NullInt64
+		var sku sql.NullString
+		if itemReq.VarianteID != nil {
+			err := tx.QueryRow(`
+				SELECT sku FROM produto_variantes
+				WHERE id = $1 AND produto_id = $2 AND ativo`,
+				*itemReq.VarianteID, itemReq.ProdutoID).Scan(&sku)
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("Variante %d não encontrada para o produto %d", *itemReq.VarianteID, itemReq.ProdutoID)})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar variante", "detalhes": err.Error()})
+				return
+			}
+			varianteID = sql.NullInt64{Int64: int64(*itemReq.VarianteID), Valid: true}
+		}
+
 		_, err := tx.Exec(`
-			INSERT INTO pedido_itens (pedido_id, produto_id, nome_produto, quantidade, valor_unitario)
-			VALUES ($1, $2, $3, $4, $5)`,
-			pedidoID, itemReq.ProdutoID, itemReq.NomeProduto, itemReq.Quantidade, itemReq.ValorUnitario)
+			INSERT INTO pedido_itens (pedido_id, produto_id, nome_produto, quantidade, valor_unitario, variante_id, sku)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			pedidoID, itemReq.ProdutoID, itemReq.NomeProduto, itemReq.Quantidade, itemReq.ValorUnitario, varianteID, sku)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao inserir item do pedido", "detalhes": err.Error()})
 			return
 		}
 	}
 
+	if err := movimentarItensPedido(c, tx, pedidoID, "venda", fmt.Sprintf("Pedido #%d", pedidoID)); err != nil {
+		if errors.Is(err, errEstoqueInsuficiente) {
+			c.JSON(http.StatusConflict, gin.H{"erro": "Estoque insuficiente", "detalhes": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao baixar estoque do pedido", "detalhes": err.Error()})
+		return
+	}
+
 	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao comitar transação do pedido"})
 		return
@@ -65,6 +145,23 @@ func CriarPedido(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"mensagem": "Pedido criado com sucesso!", "pedido_id": pedidoID})
 }
 
+const colunasPedidoItem = `id, pedido_id, produto_id, nome_produto, quantidade, valor_unitario, variante_id, sku`
+
+func scanPedidoItem(row linhaSQL) (models.PedidoItem, error) {
+	var pi models.PedidoItem
+	var varianteID sql.NullInt64
+	var sku sql.NullString
+	if err := row.Scan(&pi.ID, &pi.PedidoID, &pi.ProdutoID, &pi.NomeProduto, &pi.Quantidade, &pi.ValorUnitario, &varianteID, &sku); err != nil {
+		return pi, err
+	}
+	if varianteID.Valid {
+		id := int(varianteID.Int64)
+		pi.VarianteID = &id
+	}
+	pi.SKU = sku.String
+	return pi, nil
+}
+
 func ListarPedidosCliente(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 
@@ -100,7 +197,7 @@ func ListarPedidosCliente(c *gin.Context) {
 
 	for i := range pedidos {
 		itemRows, err := db.Query(`
-            SELECT id, pedido_id, produto_id, nome_produto, quantidade, valor_unitario
+            SELECT `+colunasPedidoItem+`
             FROM pedido_itens
             WHERE pedido_id = $1`, pedidos[i].ID)
 		if err != nil {
@@ -112,8 +209,8 @@ func ListarPedidosCliente(c *gin.Context) {
 		var itens []models.PedidoItem
 		itens = make([]models.PedidoItem, 0)
 		for itemRows.Next() {
-			var pi models.PedidoItem
-			if err := itemRows.Scan(&pi.ID, &pi.PedidoID, &pi.ProdutoID, &pi.NomeProduto, &pi.Quantidade, &pi.ValorUnitario); err != nil {
+			pi, err := scanPedidoItem(itemRows)
+			if err != nil {
 				continue
 			}
 			itens = append(itens, pi)
@@ -174,7 +271,7 @@ func ListarPedidosAdmin(c *gin.Context) {
 
 	for i := range pedidos {
 		itemRows, err := db.Query(`
-            SELECT id, pedido_id, produto_id, nome_produto, quantidade, valor_unitario
+            SELECT `+colunasPedidoItem+`
             FROM pedido_itens
             WHERE pedido_id = $1`, pedidos[i].ID)
 		if err != nil {
@@ -185,8 +282,8 @@ func ListarPedidosAdmin(c *gin.Context) {
 
 		var itens []models.PedidoItem
 		for itemRows.Next() {
-			var pi models.PedidoItem
-			if err := itemRows.Scan(&pi.ID, &pi.PedidoID, &pi.ProdutoID, &pi.NomeProduto, &pi.Quantidade, &pi.ValorUnitario); err != nil {
+			pi, err := scanPedidoItem(itemRows)
+			if err != nil {
 				continue
 			}
 			itens = append(itens, pi)
@@ -209,12 +306,54 @@ func AtualizarStatusPedido(c *gin.Context) {
 		return
 	}
 
-	_, err := db.Exec(`UPDATE pedidos SET status = $1 WHERE id = $2`, update.Status, pedidoID)
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	var statusAtual string
+	err = tx.QueryRow(`SELECT id, status FROM pedidos WHERE id = $1 FOR UPDATE`, pedidoID).Scan(&id, &statusAtual)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Pedido não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar pedido", "detalhes": err.Error()})
+		return
+	}
+
+	_, err = tx.Exec(`UPDATE pedidos SET status = $1 WHERE id = $2`, update.Status, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar status do pedido", "detalhes": err.Error()})
 		return
 	}
 
+	// Cancelar devolve os itens ao estoque; reativar um pedido cancelado
+	// baixa o estoque novamente.
+	cancelado, eraCancelado := strings.EqualFold(update.Status, "cancelado"), strings.EqualFold(statusAtual, "cancelado")
+	if cancelado != eraCancelado {
+		tipo, motivo := "devolucao", fmt.Sprintf("Pedido #%d cancelado", id)
+		if eraCancelado {
+			tipo, motivo = "venda", fmt.Sprintf("Pedido #%d reativado", id)
+		}
+		if err := movimentarItensPedido(c, tx, id, tipo, motivo); err != nil {
+			if errors.Is(err, errEstoqueInsuficiente) {
+				c.JSON(http.StatusConflict, gin.H{"erro": "Estoque insuficiente para reativar o pedido", "detalhes": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao movimentar estoque do pedido", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao confirmar transação"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"mensagem": "Status do pedido atualizado com sucesso"})
 }
 
@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// somenteDigitos remove pontuação de CPF, CEP e afins.
+func somenteDigitos(valor string) string {
+	var b strings.Builder
+	for _, r := range valor {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validarCPF confere o tamanho e os dois dígitos verificadores do CPF (apenas dígitos).
+func validarCPF(cpf string) bool {
+	if len(cpf) != 11 {
+		return false
+	}
+	if strings.Count(cpf, cpf[:1]) == 11 {
+		return false
+	}
+
+	digito := func(n int) int {
+		soma := 0
+		for i := 0; i < n; i++ {
+			soma += int(cpf[i]-'0') * (n + 1 - i)
+		}
+		resto := soma * 10 % 11
+		if resto == 10 {
+			return 0
+		}
+		return resto
+	}
+
+	return digito(9) == int(cpf[9]-'0') && digito(10) == int(cpf[10]-'0')
+}
+
+func formatarCEP(cep string) string {
+	if len(cep) != 8 {
+		return cep
+	}
+	return cep[:5] + "-" + cep[5:]
+}
+
+// formatarEndereco gera o texto gravado em pedidos.endereco_entrega, que
+// continua sendo a cópia do endereço no momento da compra.
+func formatarEndereco(e models.Endereco) string {
+	var b strings.Builder
+	if e.Destinatario != "" {
+		b.WriteString(e.Destinatario + " - ")
+	}
+	b.WriteString(e.Logradouro + ", " + e.Numero)
+	if e.Complemento != "" {
+		b.WriteString(" - " + e.Complemento)
+	}
+	fmt.Fprintf(&b, ", %s, %s/%s, CEP %s", e.Bairro, e.Cidade, e.Estado, formatarCEP(e.CEP))
+	return b.String()
+}
+
+const colunasEndereco = `id, usuario_id, apelido, destinatario, cep, logradouro, numero, complemento, bairro, cidade, estado, padrao, criado_em, atualizado_em`
+
+func scanEndereco(row linhaSQL) (models.Endereco, error) {
+	var e models.Endereco
+	var apelido, destinatario, complemento sql.NullString
+	err := row.Scan(&e.ID, &e.UsuarioID, &apelido, &destinatario, &e.CEP, &e.Logradouro, &e.Numero, &complemento, &e.Bairro, &e.Cidade, &e.Estado, &e.Padrao, &e.CriadoEm, &e.AtualizadoEm)
+	e.Apelido = apelido.String
+	e.Destinatario = destinatario.String
+	e.Complemento = complemento.String
+	return e, err
+}
+
+func listarEnderecos(db *sql.DB, usuarioID int) ([]models.Endereco, error) {
+	rows, err := db.Query(`SELECT `+colunasEndereco+` FROM enderecos WHERE usuario_id = $1 ORDER BY padrao DESC, criado_em`, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enderecos := make([]models.Endereco, 0)
+	for rows.Next() {
+		e, err := scanEndereco(rows)
+		if err != nil {
+			return nil, err
+		}
+		enderecos = append(enderecos, e)
+	}
+	return enderecos, rows.Err()
+}
+
+// obterEnderecoUsuario busca um endereço garantindo que pertence ao cliente.
+func obterEnderecoUsuario(tx *sql.Tx, usuarioID, enderecoID int) (models.Endereco, error) {
+	return scanEndereco(tx.QueryRow(`SELECT `+colunasEndereco+` FROM enderecos WHERE id = $1 AND usuario_id = $2`, enderecoID, usuarioID))
+}
+
+// normalizarEndereco padroniza o endereço antes de gravar: cidade e UF vêm
+// sempre da base de CEPs, e logradouro/bairro também quando o CEP é de rua.
+// Se o serviço de CEP estiver fora do ar, o endereço digitado é aceito desde
+// que esteja completo.
+func normalizarEndereco(ctx context.Context, db *sql.DB, req *models.EnderecoRequest) error {
+	req.CEP = somenteDigitos(req.CEP)
+	if len(req.CEP) != 8 {
+		return fmt.Errorf("CEP inválido")
+	}
+	req.Estado = strings.ToUpper(strings.TrimSpace(req.Estado))
+	req.Logradouro = strings.TrimSpace(req.Logradouro)
+	req.Numero = strings.TrimSpace(req.Numero)
+	req.Bairro = strings.TrimSpace(req.Bairro)
+	req.Cidade = strings.TrimSpace(req.Cidade)
+
+	oficial, err := consultarCEP(ctx, db, req.CEP)
+	switch {
+	case err == errCEPNaoEncontrado:
+		return fmt.Errorf("CEP não encontrado")
+	case err != nil:
+		log.Printf("AVISO: Endereço gravado sem validação de CEP (%s): %v", req.CEP, err)
+	default:
+		req.Cidade = oficial.Cidade
+		req.Estado = oficial.Estado
+		if oficial.Logradouro != "" {
+			req.Logradouro = oficial.Logradouro
+		}
+		if oficial.Bairro != "" {
+			req.Bairro = oficial.Bairro
+		}
+	}
+
+	if req.Logradouro == "" || req.Bairro == "" || req.Cidade == "" || len(req.Estado) != 2 {
+		return fmt.Errorf("Endereço incompleto: informe logradouro, bairro, cidade e estado")
+	}
+	return nil
+}
+
+func obterPerfilUsuario(db *sql.DB, usuarioID int) (models.PerfilUsuario, error) {
+	var p models.PerfilUsuario
+	var telefone, cpf sql.NullString
+	err := db.QueryRow(`
+		SELECT id, nome_completo, email, telefone, cpf, criado_em, atualizado_em
+		FROM usuarios
+		WHERE id = $1 AND anonimizado_em IS NULL`, usuarioID).
+		Scan(&p.ID, &p.Nome, &p.Email, &telefone, &cpf, &p.CriadoEm, &p.AtualizadoEm)
+	if err != nil {
+		return p, err
+	}
+	p.Telefone = telefone.String
+	p.CPF = cpf.String
+	p.Tipo = "usuario"
+
+	p.Enderecos, err = listarEnderecos(db, usuarioID)
+	return p, err
+}
+
+func AtualizarPerfil(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	var req models.AtualizarPerfilRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	sets := []string{}
+	args := []interface{}{}
+	argCounter := 1
+
+	if req.Nome != nil {
+		nome := strings.TrimSpace(*req.Nome)
+		if nome == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Nome não pode ser vazio"})
+			return
+		}
+		sets = append(sets, fmt.Sprintf("nome_completo = $%d", argCounter))
+		args = append(args, nome)
+		argCounter++
+	}
+	if req.CPF != nil {
+		// CPF vazio remove o documento do cadastro.
+		var cpf interface{}
+		if digitos := somenteDigitos(*req.CPF); digitos != "" {
+			if !validarCPF(digitos) {
+				c.JSON(http.StatusBadRequest, gin.H{"erro": "CPF inválido"})
+				return
+			}
+			cpf = digitos
+		}
+		sets = append(sets, fmt.Sprintf("cpf = $%d", argCounter))
+		args = append(args, cpf)
+		argCounter++
+	}
+	if len(sets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Nenhum campo para atualizar"})
+		return
+	}
+
+	sets = append(sets, fmt.Sprintf("atualizado_em = $%d", argCounter))
+	args = append(args, time.Now())
+	argCounter++
+	args = append(args, usuarioID)
+
+	_, err := db.Exec(fmt.Sprintf("UPDATE usuarios SET %s WHERE id = $%d", strings.Join(sets, ", "), argCounter), args...)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"erro": "CPF já cadastrado em outra conta"})
+			return
+		}
+		log.Printf("ERRO BD: Falha ao atualizar perfil do usuário %d: %v", usuarioID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar perfil", "detalhes": err.Error()})
+		return
+	}
+
+	perfil, err := obterPerfilUsuario(db, usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Perfil atualizado, mas falha ao recarregar", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, perfil)
+}
+
+func ListarEnderecos(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	enderecos, err := listarEnderecos(db, usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar endereços", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, enderecos)
+}
+
+func CriarEndereco(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	var req models.EnderecoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	if err := normalizarEndereco(c.Request.Context(), db, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	// O primeiro endereço do cliente vira o padrão automaticamente.
+	var total int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM enderecos WHERE usuario_id = $1`, usuarioID).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar endereços", "detalhes": err.Error()})
+		return
+	}
+	padrao := req.Padrao || total == 0
+	if padrao {
+		if _, err := tx.Exec(`UPDATE enderecos SET padrao = FALSE WHERE usuario_id = $1 AND padrao`, usuarioID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar endereço padrão", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	endereco, err := scanEndereco(tx.QueryRow(`
+		INSERT INTO enderecos (usuario_id, apelido, destinatario, cep, logradouro, numero, complemento, bairro, cidade, estado, padrao)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+colunasEndereco,
+		usuarioID, req.Apelido, req.Destinatario, req.CEP, req.Logradouro, req.Numero, req.Complemento, req.Bairro, req.Cidade, req.Estado, padrao))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao cadastrar endereço", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar endereço"})
+		return
+	}
+	c.JSON(http.StatusCreated, endereco)
+}
+
+func AtualizarEndereco(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	var req models.EnderecoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	if err := normalizarEndereco(c.Request.Context(), db, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	if req.Padrao {
+		if _, err := tx.Exec(`UPDATE enderecos SET padrao = FALSE WHERE usuario_id = $1 AND padrao AND id <> $2`, usuarioID, c.Param("id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar endereço padrão", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	// Desmarcar "padrao" pelo PUT não é permitido: o padrão só muda quando
+	// outro endereço é marcado.
+	endereco, err := scanEndereco(tx.QueryRow(`
+		UPDATE enderecos
+		SET apelido = $1, destinatario = $2, cep = $3, logradouro = $4, numero = $5, complemento = $6,
+			bairro = $7, cidade = $8, estado = $9, padrao = padrao OR $10, atualizado_em = $11
+		WHERE id = $12 AND usuario_id = $13
+		RETURNING `+colunasEndereco,
+		req.Apelido, req.Destinatario, req.CEP, req.Logradouro, req.Numero, req.Complemento,
+		req.Bairro, req.Cidade, req.Estado, req.Padrao, time.Now(), c.Param("id"), usuarioID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Endereço não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar endereço", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar endereço"})
+		return
+	}
+	c.JSON(http.StatusOK, endereco)
+}
+
+func DefinirEnderecoPadrao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE enderecos SET padrao = FALSE WHERE usuario_id = $1 AND padrao`, usuarioID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar endereço padrão", "detalhes": err.Error()})
+		return
+	}
+	result, err := tx.Exec(`UPDATE enderecos SET padrao = TRUE, atualizado_em = $1 WHERE id = $2 AND usuario_id = $3`, time.Now(), c.Param("id"), usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar endereço padrão", "detalhes": err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Endereço não encontrado"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar endereço padrão"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Endereço padrão atualizado com sucesso"})
+}
+
+func DeletarEndereco(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var eraPadrao bool
+	err = tx.QueryRow(`DELETE FROM enderecos WHERE id = $1 AND usuario_id = $2 RETURNING padrao`, c.Param("id"), usuarioID).Scan(&eraPadrao)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Endereço não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao remover endereço", "detalhes": err.Error()})
+		return
+	}
+
+	// Ao remover o endereço padrão, o mais antigo restante assume o lugar.
+	if eraPadrao {
+		_, err := tx.Exec(`
+			UPDATE enderecos SET padrao = TRUE
+			WHERE id = (SELECT id FROM enderecos WHERE usuario_id = $1 ORDER BY criado_em LIMIT 1)`, usuarioID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar endereço padrão", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao remover endereço"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Endereço removido com sucesso"})
+}
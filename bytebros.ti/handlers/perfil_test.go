@@ -0,0 +1,34 @@
+package handlers
+
+import "testing"
+
+// Os handlers removem a máscara com somenteDigitos antes de validarCPF.
+func TestValidarCPF(t *testing.T) {
+	casos := []struct {
+		nome   string
+		cpf    string
+		valido bool
+	}{
+		{"válido", "52998224725", true},
+		{"válido com máscara", "529.982.247-25", true},
+		{"válido com dígito verificador zero", "123.456.789-09", true},
+		{"válido com espaços", " 111 444 777 35 ", true},
+		{"primeiro dígito verificador errado", "529.982.247-35", false},
+		{"segundo dígito verificador errado", "529.982.247-24", false},
+		{"dígitos verificadores trocados", "529.982.247-52", false},
+		{"dígitos repetidos", "111.111.111-11", false},
+		{"zeros", "000.000.000-00", false},
+		{"noves", "999.999.999-99", false},
+		{"curto", "529.982.247-2", false},
+		{"longo", "529.982.247-250", false},
+		{"vazio", "", false},
+		{"letras", "abc.def.ghi-jk", false},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			if valido := validarCPF(somenteDigitos(caso.cpf)); valido != caso.valido {
+				t.Errorf("validarCPF(%q) = %v, esperado %v", caso.cpf, valido, caso.valido)
+			}
+		})
+	}
+}
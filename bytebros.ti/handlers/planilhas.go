@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Leitura e escrita de planilhas CSV e XLSX (Office Open XML) só com a
+// biblioteca padrão. Do XLSX é lida apenas a primeira aba, como texto.
+
+const tamanhoMaximoParteXLSX = 50 << 20
+
+var errPlanilhaVazia = errors.New("a planilha está vazia")
+
+// lerPlanilha devolve as linhas do arquivo; formato é "csv" ou "xlsx".
+func lerPlanilha(conteudo []byte, formato string) ([][]string, error) {
+	var linhas [][]string
+	var err error
+	if formato == "xlsx" {
+		linhas, err = lerXLSX(conteudo)
+	} else {
+		linhas, err = lerCSV(conteudo)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Linhas totalmente vazias (comuns no fim de planilhas) são descartadas.
+	preenchidas := linhas[:0]
+	for _, linha := range linhas {
+		for _, celula := range linha {
+			if strings.TrimSpace(celula) != "" {
+				preenchidas = append(preenchidas, linha)
+				break
+			}
+		}
+	}
+	if len(preenchidas) == 0 {
+		return nil, errPlanilhaVazia
+	}
+	return preenchidas, nil
+}
+
+// lerCSV aceita vírgula ou ponto e vírgula (padrão do Excel em português),
+// escolhendo o separador mais frequente no cabeçalho.
+func lerCSV(conteudo []byte) ([][]string, error) {
+	conteudo = bytes.TrimPrefix(conteudo, []byte("\xef\xbb\xbf"))
+	cabecalho := conteudo
+	if i := bytes.IndexByte(conteudo, '\n'); i >= 0 {
+		cabecalho = conteudo[:i]
+	}
+
+	leitor := csv.NewReader(bytes.NewReader(conteudo))
+	if bytes.Count(cabecalho, []byte(";")) > bytes.Count(cabecalho, []byte(",")) {
+		leitor.Comma = ';'
+	}
+	leitor.FieldsPerRecord = -1
+	leitor.LazyQuotes = true
+	return leitor.ReadAll()
+}
+
+func escreverCSV(w io.Writer, linhas [][]string) error {
+	// O BOM faz o Excel abrir o arquivo como UTF-8.
+	if _, err := w.Write([]byte("\xef\xbb\xbf")); err != nil {
+		return err
+	}
+	escritor := csv.NewWriter(w)
+	if err := escritor.WriteAll(linhas); err != nil {
+		return err
+	}
+	return escritor.Error()
+}
+
+type xlsxTexto struct {
+	Texto   string `xml:"t"`
+	Trechos []struct {
+		Texto string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxTexto) String() string {
+	var b strings.Builder
+	b.WriteString(t.Texto)
+	for _, trecho := range t.Trechos {
+		b.WriteString(trecho.Texto)
+	}
+	return b.String()
+}
+
+type xlsxAba struct {
+	Linhas []struct {
+		Celulas []struct {
+			Referencia string    `xml:"r,attr"`
+			Tipo       string    `xml:"t,attr"`
+			Valor      string    `xml:"v"`
+			Inline     xlsxTexto `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func lerParteXLSX(arquivos map[string]*zip.File, nome string, destino interface{}) error {
+	arquivo, ok := arquivos[nome]
+	if !ok {
+		return fmt.Errorf("arquivo XLSX sem %s", nome)
+	}
+	leitor, err := arquivo.Open()
+	if err != nil {
+		return err
+	}
+	defer leitor.Close()
+	return xml.NewDecoder(io.LimitReader(leitor, tamanhoMaximoParteXLSX)).Decode(destino)
+}
+
+// primeiraAbaXLSX segue workbook.xml e seus relacionamentos até o XML da
+// primeira aba; planilhas simples caem no caminho padrão.
+func primeiraAbaXLSX(arquivos map[string]*zip.File) string {
+	var livro struct {
+		Abas []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var relacionamentos struct {
+		Itens []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	padrao := "xl/worksheets/sheet1.xml"
+	if lerParteXLSX(arquivos, "xl/workbook.xml", &livro) != nil || len(livro.Abas) == 0 {
+		return padrao
+	}
+	if lerParteXLSX(arquivos, "xl/_rels/workbook.xml.rels", &relacionamentos) != nil {
+		return padrao
+	}
+	for _, rel := range relacionamentos.Itens {
+		if rel.ID != livro.Abas[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+	return padrao
+}
+
+// indiceColunaXLSX converte a referência "C12" no índice de coluna 2.
+func indiceColunaXLSX(referencia string) int {
+	indice := 0
+	for _, r := range referencia {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		indice = indice*26 + int(r-'A'+1)
+	}
+	return indice - 1
+}
+
+func colunaXLSX(indice int) string {
+	nome := ""
+	for indice++; indice > 0; indice = (indice - 1) / 26 {
+		nome = string(rune('A'+(indice-1)%26)) + nome
+	}
+	return nome
+}
+
+func lerXLSX(conteudo []byte) ([][]string, error) {
+	pacote, err := zip.NewReader(bytes.NewReader(conteudo), int64(len(conteudo)))
+	if err != nil {
+		return nil, fmt.Errorf("arquivo XLSX inválido: %w", err)
+	}
+	arquivos := map[string]*zip.File{}
+	for _, arquivo := range pacote.File {
+		arquivos[arquivo.Name] = arquivo
+	}
+
+	var compartilhados struct {
+		Itens []xlsxTexto `xml:"si"`
+	}
+	if _, ok := arquivos["xl/sharedStrings.xml"]; ok {
+		if err := lerParteXLSX(arquivos, "xl/sharedStrings.xml", &compartilhados); err != nil {
+			return nil, fmt.Errorf("arquivo XLSX inválido: %w", err)
+		}
+	}
+
+	var aba xlsxAba
+	if err := lerParteXLSX(arquivos, primeiraAbaXLSX(arquivos), &aba); err != nil {
+		return nil, fmt.Errorf("arquivo XLSX inválido: %w", err)
+	}
+
+	linhas := make([][]string, 0, len(aba.Linhas))
+	for _, linhaXML := range aba.Linhas {
+		var linha []string
+		for _, celula := range linhaXML.Celulas {
+			indice := len(linha)
+			if celula.Referencia != "" {
+				indice = indiceColunaXLSX(celula.Referencia)
+			}
+			if indice < 0 || indice > 16383 {
+				continue
+			}
+			for len(linha) <= indice {
+				linha = append(linha, "")
+			}
+
+			valor := celula.Valor
+			switch celula.Tipo {
+			case "s":
+				i, err := strconv.Atoi(valor)
+				if err != nil || i < 0 || i >= len(compartilhados.Itens) {
+					return nil, fmt.Errorf("arquivo XLSX inválido: texto compartilhado %q inexistente", valor)
+				}
+				valor = compartilhados.Itens[i].String()
+			case "inlineStr":
+				valor = celula.Inline.String()
+			case "b":
+				valor = strconv.FormatBool(valor == "1")
+			case "", "n":
+				// Números são gravados em ponto flutuante binário (19.899999999999999).
+				if numero, err := strconv.ParseFloat(valor, 64); err == nil {
+					valor = strconv.FormatFloat(numero, 'f', -1, 64)
+				}
+			}
+			linha[indice] = valor
+		}
+		linhas = append(linhas, linha)
+	}
+	return linhas, nil
+}
+
+// escreverXLSX gera uma pasta de trabalho com uma única aba. As colunas em
+// numericas são gravadas como número quando o valor é numérico.
+func escreverXLSX(w io.Writer, nomeAba string, linhas [][]string, numericas map[int]bool) error {
+	pacote := zip.NewWriter(w)
+	partes := []struct{ nome, conteudo string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + textoXML(nomeAba) + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+	}
+	for _, parte := range partes {
+		arquivo, err := pacote.Create(parte.nome)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(arquivo, parte.conteudo); err != nil {
+			return err
+		}
+	}
+
+	arquivo, err := pacote.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	var aba bytes.Buffer
+	aba.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	aba.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, linha := range linhas {
+		fmt.Fprintf(&aba, `<row r="%d">`, i+1)
+		for j, valor := range linha {
+			referencia := colunaXLSX(j) + strconv.Itoa(i+1)
+			if _, err := strconv.ParseFloat(valor, 64); err == nil && i > 0 && numericas[j] {
+				fmt.Fprintf(&aba, `<c r="%s"><v>%s</v></c>`, referencia, valor)
+				continue
+			}
+			fmt.Fprintf(&aba, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, referencia, textoXML(valor))
+		}
+		aba.WriteString(`</row>`)
+	}
+	aba.WriteString(`</sheetData></worksheet>`)
+	if _, err := arquivo.Write(aba.Bytes()); err != nil {
+		return err
+	}
+	return pacote.Close()
+}
+
+func textoXML(texto string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(texto))
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// montarXLSX empacota as partes informadas; sem workbook.xml o leitor usa o
+// caminho padrão da primeira aba.
+func montarXLSX(t *testing.T, partes map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	pacote := zip.NewWriter(&buf)
+	for nome, conteudo := range partes {
+		arquivo, err := pacote.Create(nome)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arquivo.Write([]byte(conteudo))
+	}
+	if err := pacote.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func abaXLSX(linhas string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + linhas + `</sheetData></worksheet>`
+}
+
+func TestLerXLSX(t *testing.T) {
+	compartilhados := `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>nome</t></si>
+<si><t>preco</t></si>
+<si><r><t>Mouse </t></r><r><t>Gamer</t></r></si>
+</sst>`
+
+	casos := []struct {
+		nome     string
+		partes   map[string]string
+		esperado [][]string
+	}{
+		{
+			"textos compartilhados, inclusive com trechos formatados",
+			map[string]string{
+				"xl/sharedStrings.xml":     compartilhados,
+				"xl/worksheets/sheet1.xml": abaXLSX(`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row><row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2"><v>19.899999999999999</v></c></row>`),
+			},
+			[][]string{{"nome", "preco"}, {"Mouse Gamer", "19.9"}},
+		},
+		{
+			"textos inline e booleanos",
+			map[string]string{
+				"xl/worksheets/sheet1.xml": abaXLSX(`<row r="1"><c r="A1" t="inlineStr"><is><t>Teclado</t></is></c><c r="B1" t="b"><v>1</v></c><c r="C1" t="b"><v>0</v></c></row>`),
+			},
+			[][]string{{"Teclado", "true", "false"}},
+		},
+		{
+			"células vazias omitidas no meio da linha",
+			map[string]string{
+				"xl/worksheets/sheet1.xml": abaXLSX(`<row r="1"><c r="A1" t="inlineStr"><is><t>a</t></is></c><c r="D1"><v>4</v></c></row><row r="2"></row><row r="3"><c r="B3"><v>2</v></c></row>`),
+			},
+			[][]string{{"a", "", "", "4"}, nil, {"", "2"}},
+		},
+		{
+			"células sem referência seguem a ordem",
+			map[string]string{
+				"xl/worksheets/sheet1.xml": abaXLSX(`<row><c t="inlineStr"><is><t>x</t></is></c><c><v>7</v></c></row>`),
+			},
+			[][]string{{"x", "7"}},
+		},
+		{
+			"colunas depois de Z",
+			map[string]string{
+				"xl/worksheets/sheet1.xml": abaXLSX(`<row r="1"><c r="Z1"><v>26</v></c><c r="AA1"><v>27</v></c><c r="AB1"><v>28</v></c></row>`),
+			},
+			[][]string{append(make([]string, 25), "26", "27", "28")},
+		},
+		{
+			"primeira aba pelo workbook.xml",
+			map[string]string{
+				"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Produtos" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+				"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId7" Target="worksheets/produtos.xml"/></Relationships>`,
+				"xl/worksheets/produtos.xml": abaXLSX(`<row r="1"><c r="A1" t="inlineStr"><is><t>certa</t></is></c></row>`),
+				"xl/worksheets/sheet1.xml":   abaXLSX(`<row r="1"><c r="A1" t="inlineStr"><is><t>errada</t></is></c></row>`),
+			},
+			[][]string{{"certa"}},
+		},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			linhas, err := lerXLSX(montarXLSX(t, caso.partes))
+			if err != nil {
+				t.Fatalf("lerXLSX: %v", err)
+			}
+			if !reflect.DeepEqual(linhas, caso.esperado) {
+				t.Errorf("linhas = %q, esperado %q", linhas, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestLerXLSXRecusaArquivosInvalidos(t *testing.T) {
+	casos := []struct {
+		nome     string
+		conteudo []byte
+	}{
+		{"não é zip", []byte("nome,preco\n")},
+		{"sem aba", montarXLSX(t, map[string]string{"xl/sharedStrings.xml": `<sst/>`})},
+		{"texto compartilhado inexistente", montarXLSX(t, map[string]string{
+			"xl/worksheets/sheet1.xml": abaXLSX(`<row r="1"><c r="A1" t="s"><v>3</v></c></row>`),
+		})},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			if _, err := lerXLSX(caso.conteudo); err == nil {
+				t.Fatal("arquivo inválido foi aceito")
+			}
+		})
+	}
+}
+
+func TestLerPlanilhaCSV(t *testing.T) {
+	casos := []struct {
+		nome     string
+		conteudo string
+		esperado [][]string
+	}{
+		{"vírgula", "nome,preco\nMouse,19.90\n", [][]string{{"nome", "preco"}, {"Mouse", "19.90"}}},
+		{"ponto e vírgula do Excel", "nome;preco\nMouse;19,90\n", [][]string{{"nome", "preco"}, {"Mouse", "19,90"}}},
+		{"BOM UTF-8", "\xef\xbb\xbfnome;preco\nMouse;10\n", [][]string{{"nome", "preco"}, {"Mouse", "10"}}},
+		{"CRLF e aspas", "nome,detalhes\r\n\"Mouse, sem fio\",\"diz \"\"oi\"\"\"\r\n", [][]string{{"nome", "detalhes"}, {"Mouse, sem fio", `diz "oi"`}}},
+		{"linhas vazias descartadas", "nome,preco\n,\nMouse,10\n\n", [][]string{{"nome", "preco"}, {"Mouse", "10"}}},
+		{"quantidade de colunas variável", "nome,preco,sku\nMouse,10\n", [][]string{{"nome", "preco", "sku"}, {"Mouse", "10"}}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			linhas, err := lerPlanilha([]byte(caso.conteudo), "csv")
+			if err != nil {
+				t.Fatalf("lerPlanilha: %v", err)
+			}
+			if !reflect.DeepEqual(linhas, caso.esperado) {
+				t.Errorf("linhas = %q, esperado %q", linhas, caso.esperado)
+			}
+		})
+	}
+
+	if _, err := lerPlanilha([]byte("\xef\xbb\xbf;\n;\n"), "csv"); err != errPlanilhaVazia {
+		t.Errorf("planilha vazia: erro %v, esperado %v", err, errPlanilhaVazia)
+	}
+}
+
+func TestColunaXLSX(t *testing.T) {
+	casos := []struct {
+		indice int
+		nome   string
+	}{
+		{0, "A"}, {25, "Z"}, {26, "AA"}, {27, "AB"}, {51, "AZ"}, {52, "BA"}, {701, "ZZ"}, {702, "AAA"}, {16383, "XFD"},
+	}
+	for _, caso := range casos {
+		if nome := colunaXLSX(caso.indice); nome != caso.nome {
+			t.Errorf("colunaXLSX(%d) = %s, esperado %s", caso.indice, nome, caso.nome)
+		}
+		if indice := indiceColunaXLSX(caso.nome + "12"); indice != caso.indice {
+			t.Errorf("indiceColunaXLSX(%s12) = %d, esperado %d", caso.nome, indice, caso.indice)
+		}
+	}
+}
+
+// O que escreverXLSX exporta volta igual pelo leitor usado na importação.
+func TestEscreverXLSXIdaEVolta(t *testing.T) {
+	linhas := [][]string{
+		{"nome", "preco", "detalhes"},
+		{"Mouse <sem fio>", "19.9", "  com espaços & símbolos  "},
+		{"Cabo", "", "x"},
+	}
+	var buf bytes.Buffer
+	if err := escreverXLSX(&buf, "Produtos", linhas, map[int]bool{1: true}); err != nil {
+		t.Fatalf("escreverXLSX: %v", err)
+	}
+	lidas, err := lerPlanilha(buf.Bytes(), "xlsx")
+	if err != nil {
+		t.Fatalf("lerPlanilha: %v", err)
+	}
+	if !reflect.DeepEqual(lidas, linhas) {
+		t.Errorf("linhas = %q, esperado %q", lidas, linhas)
+	}
+}
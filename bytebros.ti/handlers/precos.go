@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// registrarHistoricoPrecos grava o preço atual (normal e efetivo) dos produtos
+// cujo valor mudou desde o último registro. Sem produtoIDs, confere o
+// catálogo inteiro (usado quando promoções começam, terminam ou mudam).
+func registrarHistoricoPrecos(db executorSQL, origem string, produtoIDs []int) error {
+	filtro := "TRUE"
+	args := []interface{}{origem}
+	if produtoIDs != nil {
+		filtro = "p.id = ANY($2)"
+		args = append(args, pq.Array(produtoIDs))
+	}
+	_, err := db.Exec(`
+		INSERT INTO produto_precos (produto_id, preco, preco_efetivo, origem)
+		SELECT p.id, p.preco, atual.preco_efetivo, $1
+		FROM produtos p
+		CROSS JOIN LATERAL (SELECT `+sqlPrecoEfetivoProduto+` AS preco_efetivo) atual
+		LEFT JOIN LATERAL (
+			SELECT h.preco, h.preco_efetivo FROM produto_precos h
+			WHERE h.produto_id = p.id
+			ORDER BY h.criado_em DESC, h.id DESC
+			LIMIT 1
+		) ultimo ON true
+		WHERE `+filtro+`
+			AND (ultimo.preco IS NULL OR ultimo.preco <> p.preco OR ultimo.preco_efetivo <> atual.preco_efetivo)`, args...)
+	return err
+}
+
+// registrarHistoricoPrecosCatalogo é a versão para depois de mudanças em
+// promoções: a falha não desfaz a operação, apenas fica no log.
+func registrarHistoricoPrecosCatalogo(db *sql.DB) {
+	if err := registrarHistoricoPrecos(db, "promocao", nil); err != nil {
+		log.Printf("ERRO BD: Falha ao registrar histórico de preços: %v", err)
+	}
+}
+
+// sqlMenorPreco30Dias calcula, para os produtos em $1, o menor preço efetivo
+// nos 30 dias anteriores à entrada em vigor do preço atual (o último
+// registro), contando também o preço que já valia no início da janela.
+const sqlMenorPreco30Dias = `
+	SELECT u.produto_id, MIN(h.preco_efetivo)
+	FROM (
+		SELECT DISTINCT ON (produto_id) produto_id, criado_em
+		FROM produto_precos
+		WHERE produto_id = ANY($1)
+		ORDER BY produto_id, criado_em DESC, id DESC
+	) u
+	JOIN produto_precos h ON h.produto_id = u.produto_id AND h.criado_em < u.criado_em
+	WHERE h.criado_em >= u.criado_em - INTERVAL '30 days'
+		OR h.id = (
+			SELECT anterior.id FROM produto_precos anterior
+			WHERE anterior.produto_id = u.produto_id AND anterior.criado_em < u.criado_em - INTERVAL '30 days'
+			ORDER BY anterior.criado_em DESC, anterior.id DESC
+			LIMIT 1)
+	GROUP BY u.produto_id`
+
+// aplicarMenorPreco30Dias informa o menor preço recente dos produtos em
+// oferta; deve ser chamada depois de aplicarPromocoesProdutos.
+func aplicarMenorPreco30Dias(db *sql.DB, produtos []models.Produto) error {
+	var ids []int
+	for _, p := range produtos {
+		if p.Oferta {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(sqlMenorPreco30Dias, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	menores := map[int]float64{}
+	for rows.Next() {
+		var produtoID int
+		var menor float64
+		if err := rows.Scan(&produtoID, &menor); err != nil {
+			return err
+		}
+		menores[produtoID] = menor
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range produtos {
+		if !produtos[i].Oferta {
+			continue
+		}
+		// Sem histórico anterior, a referência é o próprio preço normal.
+		menor, ok := menores[produtos[i].ID]
+		if !ok {
+			menor = produtos[i].Preco
+		}
+		produtos[i].MenorPreco30Dias = &menor
+	}
+	return nil
+}
+
+// ListarPrecosProduto devolve o histórico de preços do produto, do mais
+// recente para o mais antigo.
+func ListarPrecosProduto(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	produtos := []models.Produto{{ID: produtoID}}
+	err = db.QueryRow(`SELECT preco, oferta FROM produtos WHERE id = $1`, produtoID).Scan(&produtos[0].Preco, &produtos[0].Oferta)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if err := aplicarPromocoesProdutos(db, produtos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções do produto", "detalhes": err.Error()})
+		return
+	}
+	if err := aplicarMenorPreco30Dias(db, produtos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao calcular menor preço recente", "detalhes": err.Error()})
+		return
+	}
+
+	limite := 50
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= 200 {
+		limite = v
+	}
+	pagina := 1
+	if v, err := strconv.Atoi(c.Query("pagina")); err == nil && v > 0 {
+		pagina = v
+	}
+
+	rows, err := db.Query(`
+		SELECT id, preco, preco_efetivo, origem, criado_em
+		FROM produto_precos
+		WHERE produto_id = $1
+		ORDER BY criado_em DESC, id DESC
+		LIMIT $2 OFFSET $3`, produtoID, limite, (pagina-1)*limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar histórico de preços", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	historico := make([]models.PrecoHistorico, 0)
+	for rows.Next() {
+		var h models.PrecoHistorico
+		if err := rows.Scan(&h.ID, &h.Preco, &h.PrecoEfetivo, &h.Origem, &h.CriadoEm); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler histórico de preços", "detalhes": err.Error()})
+			return
+		}
+		historico = append(historico, h)
+	}
+
+	p := produtos[0]
+	c.JSON(http.StatusOK, gin.H{
+		"produto_id":          produtoID,
+		"preco":               p.Preco,
+		"preco_efetivo":       p.PrecoEfetivo,
+		"menor_preco_30_dias": p.MenorPreco30Dias,
+		"historico":           historico,
+		"pagina":              pagina,
+		"limite":              limite,
+	})
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"bytebros.ti/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 func CriarProduto(c *gin.Context) {
@@ -17,6 +20,10 @@ func CriarProduto(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
 		return
 	}
+	if produtoReq.SKU != nil {
+		sku := normalizarSKU(*produtoReq.SKU)
+		produtoReq.SKU = &sku
+	}
 
 	db := c.MustGet("db").(*sql.DB)
 
@@ -24,19 +31,69 @@ func CriarProduto(c *gin.Context) {
 	detalhesNull := sql.NullString{String: produtoReq.Detalhes, Valid: produtoReq.Detalhes != ""}
 	imagemNull := sql.NullString{String: produtoReq.Imagem, Valid: produtoReq.Imagem != ""}
 
-	err := db.QueryRow(`
-        INSERT INTO produtos (nome, quantidade, preco, oferta, detalhes, imagem)
-        VALUES ($1, $2, $3, $4, $5, $6)
-        RETURNING id`,
-		produtoReq.Nome, produtoReq.Quantidade, produtoReq.Preco, produtoReq.Oferta, detalhesNull, imagemNull).
-		Scan(&produto.ID)
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+        INSERT INTO produtos (nome, quantidade, preco, oferta, detalhes, imagem, estoque_minimo, sku)
+        VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, 0), NULLIF($8, ''))
+        RETURNING id, criado_em, estoque_minimo`,
+		produtoReq.Nome, 0, produtoReq.Preco, produtoReq.Oferta, detalhesNull, imagemNull, produtoReq.EstoqueMinimo, produtoReq.SKU).
+		Scan(&produto.ID, &produto.CriadoEm, &produto.EstoqueMinimo)
 
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"erro": "SKU já utilizado por outro produto"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao criar produto", "detalhes": err.Error()})
 		return
 	}
 
+	if produto.Slug, err = atribuirSlug(tx, "produtos", produto.ID, produtoReq.Nome); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+		return
+	}
+
+	// O estoque inicial entra pelo livro de estoque, como qualquer outra entrada.
+	if produtoReq.Quantidade > 0 {
+		movimento := novoMovimentoEstoque(c, produto.ID, nil, "entrada", produtoReq.Quantidade, "Estoque inicial")
+		if err := registrarMovimentoEstoque(tx, movimento); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar estoque inicial", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	if produtoReq.CategoriaIDs != nil {
+		if !vincularCategoriasProduto(c, tx, produto.ID, produtoReq.CategoriaIDs) {
+			return
+		}
+	}
+
+	// Validadas mesmo quando ausentes, para cobrar as obrigatórias.
+	especificacoes, ok := gravarEspecificacoesProduto(c, tx, produto.ID, produtoReq.Especificacoes)
+	if !ok {
+		return
+	}
+
+	if err := registrarHistoricoPrecos(tx, "cadastro", []int{produto.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar histórico de preços", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar produto"})
+		return
+	}
+
 	produto.Nome = produtoReq.Nome
+	if produtoReq.SKU != nil {
+		produto.SKU = *produtoReq.SKU
+	}
 	produto.Quantidade = produtoReq.Quantidade
 	produto.Preco = produtoReq.Preco
 	produto.Oferta = produtoReq.Oferta
@@ -45,54 +102,223 @@ func CriarProduto(c *gin.Context) {
 	produto.Imagem.String = produtoReq.Imagem
 	produto.Imagem.Valid = (produtoReq.Imagem != "")
 
+	categorias, _ := carregarCategoriasProdutos(db, []int{produto.ID})
+	produto.Categorias = categorias[produto.ID]
+	if produto.Categorias == nil {
+		produto.Categorias = []models.CategoriaResumo{}
+	}
+	produto.Variantes = []models.Variante{}
+	produto.Imagens = []models.ProdutoImagem{}
+	produto.Especificacoes = especificacoes
+
 	c.JSON(http.StatusCreated, produto)
 }
 
+// normalizarSKU padroniza o código como nas variantes: sem espaços nas
+// pontas e em maiúsculas.
+func normalizarSKU(sku string) string {
+	return strings.ToUpper(strings.TrimSpace(sku))
+}
+
+// vincularCategoriasProduto aplica categoria_ids do ProdutoRequest dentro da
+// transação do produto, respondendo 400 para categorias inexistentes.
+func vincularCategoriasProduto(c *gin.Context, tx *sql.Tx, produtoID int, categoriaIDs []int) bool {
+	if err := definirCategoriasProduto(tx, produtoID, categoriaIDs); err != nil {
+		if err == errCategoriaInexistente {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Uma ou mais categorias não existem"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao vincular categorias", "detalhes": err.Error()})
+		}
+		return false
+	}
+	return true
+}
+
+// ListarProdutos aceita filtros (ofertas, em_estoque, categoria, preco_min,
+// preco_max, atributos[chave], q), ordenação e paginação por cursor, e devolve
+// as facetas do conjunto filtrado para a barra lateral da loja.
 func ListarProdutos(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 
-	somenteOfertas := c.Query("ofertas") == "true"
+	ordenar := c.DefaultQuery("ordenar", "nome")
+	ordenacao, ok := ordenacoesCatalogo[ordenar]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Ordenação inválida", "opcoes": []string{"nome", "menor_preco", "maior_preco", "recentes", "mais_vendidos", "avaliacao"}})
+		return
+	}
+
+	paginado, ok := paginacaoCatalogo(c, ordenar)
+	if !ok {
+		return
+	}
+
+	clausulas, args, ok := filtrosCatalogo(c, db)
+	if !ok {
+		return
+	}
 
-	var rows *sql.Rows
-	var err error
+	var total int
+	if paginado != nil {
+		if err := db.QueryRow(`SELECT COUNT(*) FROM produtos p`+whereCatalogo(clausulas), args...).Scan(&total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao contar produtos", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	var facetas *models.FacetasProdutos
+	if paginado != nil && c.Query("facetas") != "false" {
+		calculadas, err := calcularFacetas(db, clausulas, args)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao calcular facetas", "detalhes": err.Error()})
+			return
+		}
+		facetas = &calculadas
+	}
+
+	direcao, comparacao := "ASC", ">"
+	if !ordenacao.crescente {
+		direcao, comparacao = "DESC", "<"
+	}
+
+	query := `
+		SELECT id, nome, slug, sku, quantidade, preco, preco_efetivo, oferta, estoque_minimo, detalhes, imagem, criado_em, avaliacao, avaliacoes,
+			` + ordenacao.coluna + `::text
+		FROM (
+			SELECT p.id, p.nome, p.slug, p.sku, p.quantidade, p.preco, p.oferta, p.estoque_minimo, p.detalhes, p.imagem, p.criado_em,
+				` + sqlQuantidadeVendida + ` AS vendidos,
+				` + sqlPrecoEfetivoProduto + ` AS preco_efetivo,
+				COALESCE(` + sqlMediaAvaliacoes + `, 0) AS avaliacao,
+				` + sqlTotalAvaliacoes + ` AS avaliacoes
+			FROM produtos p` + whereCatalogo(clausulas) + `
+		) p`
+	paginaArgs := append([]interface{}{}, args...)
+
+	if paginado != nil && paginado.cursor != nil {
+		paginaArgs = append(paginaArgs, paginado.cursor.Valor, paginado.cursor.ID)
+		query += fmt.Sprintf(` WHERE (p.%s, p.id) %s ($%d::%s, $%d)`,
+			ordenacao.coluna, comparacao, len(paginaArgs)-1, ordenacao.tipo, len(paginaArgs))
+	}
 
-	query := `SELECT id, nome, quantidade, preco, oferta, detalhes, imagem FROM produtos `
-	if somenteOfertas {
-		query += `WHERE oferta = true ORDER BY nome`
-	} else {
-		query += `ORDER BY nome`
+	query += fmt.Sprintf(` ORDER BY p.%s %s, p.id %s`, ordenacao.coluna, direcao, direcao)
+	if paginado != nil {
+		paginaArgs = append(paginaArgs, paginado.limite+1)
+		query += fmt.Sprintf(` LIMIT $%d`, len(paginaArgs))
 	}
 
-	rows, err = db.Query(query)
+	rows, err := db.Query(query, paginaArgs...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produtos", "detalhes": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	var produtos []models.Produto
+	// O preço efetivo e o valor do cursor vêm do SQL, o mesmo que ordena e
+	// filtra a página seguinte.
+	produtos := []models.Produto{}
+	var precosEfetivos []float64
+	var valoresCursor []string
 	for rows.Next() {
 		var p models.Produto
-		if err := rows.Scan(&p.ID, &p.Nome, &p.Quantidade, &p.Preco, &p.Oferta, &p.Detalhes, &p.Imagem); err != nil {
+		var sku sql.NullString
+		var media, precoEfetivo float64
+		var valorCursor string
+		if err := rows.Scan(&p.ID, &p.Nome, &p.Slug, &sku, &p.Quantidade, &p.Preco, &precoEfetivo, &p.Oferta, &p.EstoqueMinimo, &p.Detalhes, &p.Imagem, &p.CriadoEm, &media, &p.TotalAvaliacoes, &valorCursor); err != nil {
 			log.Printf("ERRO BD: Erro ao ler produto durante Scan: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler produtos", "detalhes": err.Error()})
 			return
 		}
+		p.SKU = sku.String
+		if p.TotalAvaliacoes > 0 {
+			p.AvaliacaoMedia = &media
+		}
 		produtos = append(produtos, p)
+		precosEfetivos = append(precosEfetivos, precoEfetivo)
+		valoresCursor = append(valoresCursor, valorCursor)
+	}
+
+	var proximoCursor *string
+	if paginado != nil {
+		produtos, proximoCursor = cortarPaginaCatalogo(produtos, valoresCursor, paginado.limite, ordenar)
+	}
+
+	ids := make([]int, len(produtos))
+	for i, p := range produtos {
+		ids[i] = p.ID
 	}
-	c.JSON(http.StatusOK, produtos)
+	categorias, err := carregarCategoriasProdutos(db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categorias dos produtos", "detalhes": err.Error()})
+		return
+	}
+	variantes, err := carregarVariantesProdutos(db, ids, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar variantes dos produtos", "detalhes": err.Error()})
+		return
+	}
+	imagens, err := carregarImagensProdutos(db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar imagens dos produtos", "detalhes": err.Error()})
+		return
+	}
+	for i := range produtos {
+		produtos[i].Categorias = categorias[produtos[i].ID]
+		if produtos[i].Categorias == nil {
+			produtos[i].Categorias = []models.CategoriaResumo{}
+		}
+		produtos[i].Variantes = variantes[produtos[i].ID]
+		if produtos[i].Variantes == nil {
+			produtos[i].Variantes = []models.Variante{}
+		}
+		produtos[i].Imagens = imagens[produtos[i].ID]
+		if produtos[i].Imagens == nil {
+			produtos[i].Imagens = []models.ProdutoImagem{}
+		}
+	}
+
+	if err := aplicarPromocoesProdutos(db, produtos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções dos produtos", "detalhes": err.Error()})
+		return
+	}
+	for i := range produtos {
+		produtos[i].PrecoEfetivo = precosEfetivos[i]
+	}
+	if err := aplicarMenorPreco30Dias(db, produtos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao calcular menor preço recente", "detalhes": err.Error()})
+		return
+	}
+
+	if paginado == nil {
+		c.JSON(http.StatusOK, produtos)
+		return
+	}
+	c.JSON(http.StatusOK, models.ListaProdutosResponse{
+		Produtos:      produtos,
+		Total:         total,
+		Limite:        paginado.limite,
+		ProximoCursor: proximoCursor,
+		Facetas:       facetas,
+	})
 }
 
+// ObterProduto aceita o ID ou o slug do produto.
 func ObterProduto(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
-	id := c.Param("id")
+	id, ok := identificadorDaRota(c, db, "produtos", "Produto não encontrado")
+	if !ok {
+		return
+	}
 
 	var produto models.Produto
+	var sku sql.NullString
+	var media sql.NullFloat64
+	var especificacoes []byte
 	err := db.QueryRow(`
-        SELECT id, nome, quantidade, preco, oferta, detalhes, imagem
-        FROM produtos
+        SELECT id, nome, slug, sku, quantidade, preco, oferta, estoque_minimo, detalhes, imagem, criado_em,
+            `+sqlMediaAvaliacoes+`, `+sqlTotalAvaliacoes+`, especificacoes
+        FROM produtos p
         WHERE id = $1`, id).
-		Scan(&produto.ID, &produto.Nome, &produto.Quantidade, &produto.Preco, &produto.Oferta, &produto.Detalhes, &produto.Imagem)
+		Scan(&produto.ID, &produto.Nome, &produto.Slug, &sku, &produto.Quantidade, &produto.Preco, &produto.Oferta, &produto.EstoqueMinimo, &produto.Detalhes, &produto.Imagem, &produto.CriadoEm,
+			&media, &produto.TotalAvaliacoes, &especificacoes)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -102,7 +328,60 @@ func ObterProduto(c *gin.Context) {
 		}
 		return
 	}
-	c.JSON(http.StatusOK, produto)
+
+	produto.SKU = sku.String
+	if media.Valid {
+		produto.AvaliacaoMedia = &media.Float64
+	}
+
+	categorias, err := carregarCategoriasProdutos(db, []int{produto.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar categorias do produto", "detalhes": err.Error()})
+		return
+	}
+	produto.Categorias = categorias[produto.ID]
+	if produto.Categorias == nil {
+		produto.Categorias = []models.CategoriaResumo{}
+	}
+
+	variantes, err := carregarVariantesProdutos(db, []int{produto.ID}, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar variantes do produto", "detalhes": err.Error()})
+		return
+	}
+	produto.Variantes = variantes[produto.ID]
+	if produto.Variantes == nil {
+		produto.Variantes = []models.Variante{}
+	}
+
+	imagens, err := carregarImagensProdutos(db, []int{produto.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar imagens do produto", "detalhes": err.Error()})
+		return
+	}
+	produto.Imagens = imagens[produto.ID]
+	if produto.Imagens == nil {
+		produto.Imagens = []models.ProdutoImagem{}
+	}
+
+	definicoes, err := carregarEspecificacoes(db, sqlCategoriasDosProdutos, pq.Array([]int{produto.ID}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar especificações do produto", "detalhes": err.Error()})
+		return
+	}
+	produto.Especificacoes = montarEspecificacoesProduto(definicoes, lerValoresEspecificacoes(especificacoes))
+
+	lista := []models.Produto{produto}
+	if err := aplicarPromocoesProdutos(db, lista); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções do produto", "detalhes": err.Error()})
+		return
+	}
+	if err := aplicarMenorPreco30Dias(db, lista); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao calcular menor preço recente", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lista[0])
 }
 
 func AtualizarProduto(c *gin.Context) {
@@ -113,22 +392,82 @@ func AtualizarProduto(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
 		return
 	}
+	if produtoReq.SKU != nil {
+		sku := normalizarSKU(*produtoReq.SKU)
+		produtoReq.SKU = &sku
+	}
 
 	db := c.MustGet("db").(*sql.DB)
 
 	detalhesNull := sql.NullString{String: produtoReq.Detalhes, Valid: produtoReq.Detalhes != ""}
 	imagemNull := sql.NullString{String: produtoReq.Imagem, Valid: produtoReq.Imagem != ""}
 
-	_, err := db.Exec(`
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	var produtoID int
+	err = tx.QueryRow(`SELECT id FROM produtos WHERE id = $1 FOR UPDATE`, id).Scan(&produtoID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+
+	_, err = tx.Exec(`
         UPDATE produtos
-        SET nome = $1, quantidade = $2, preco = $3, oferta = $4, detalhes = $5, imagem = $6
-        WHERE id = $7`,
-		produtoReq.Nome, produtoReq.Quantidade, produtoReq.Preco, produtoReq.Oferta, detalhesNull, imagemNull, id)
+        SET nome = $1, preco = $2, oferta = $3, detalhes = $4, imagem = $5,
+            estoque_minimo = COALESCE($7, estoque_minimo), sku = NULLIF(COALESCE($8, sku), '')
+        WHERE id = $6`,
+		produtoReq.Nome, produtoReq.Preco, produtoReq.Oferta, detalhesNull, imagemNull, produtoID, produtoReq.EstoqueMinimo, produtoReq.SKU)
 
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"erro": "SKU já utilizado por outro produto"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar produto", "detalhes": err.Error()})
 		return
 	}
+
+	// Um nome novo gera outro slug; o anterior continua redirecionando.
+	if _, err := atribuirSlug(tx, "produtos", produtoID, produtoReq.Nome); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+		return
+	}
+
+	if produtoReq.CategoriaIDs != nil {
+		if !vincularCategoriasProduto(c, tx, produtoID, produtoReq.CategoriaIDs) {
+			return
+		}
+	}
+
+	if produtoReq.Especificacoes != nil {
+		if _, ok := gravarEspecificacoesProduto(c, tx, produtoID, produtoReq.Especificacoes); !ok {
+			return
+		}
+	} else if produtoReq.CategoriaIDs != nil {
+		if !conferirEspecificacoesProduto(c, tx, produtoID) {
+			return
+		}
+	}
+
+	// Só grava quando o preço (ou o efetivo, se as categorias mudaram) mudou.
+	if err := registrarHistoricoPrecos(tx, "edicao", []int{produtoID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar histórico de preços", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar produto"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"mensagem": "Produto atualizado com sucesso"})
 }
 
@@ -136,10 +475,18 @@ func DeletarProduto(c *gin.Context) {
 	id := c.Param("id")
 	db := c.MustGet("db").(*sql.DB)
 
-	_, err := db.Exec("DELETE FROM produtos WHERE id = $1", id)
+	// As linhas da galeria somem em cascata; os arquivos são removidos depois.
+	arquivos, err := arquivosImagensProduto(db, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar imagens do produto", "detalhes": err.Error()})
+		return
+	}
+
+	_, err = db.Exec("DELETE FROM produtos WHERE id = $1", id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao deletar produto", "detalhes": err.Error()})
 		return
 	}
+	removerArquivosArmazenados(arquivos)
 	c.JSON(http.StatusOK, gin.H{"mensagem": "Produto deletado com sucesso"})
 }
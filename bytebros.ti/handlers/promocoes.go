@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// sqlPromocaoVigente restringe o alias pr às promoções em vigor agora.
+const sqlPromocaoVigente = `pr.ativo AND pr.inicio <= NOW() AND pr.fim > NOW()`
+
+// sqlAlvoPromocaoProduto: a promoção pr alcança o produto p diretamente ou
+// por uma das categorias dele, inclusive as categorias-pai.
+const sqlAlvoPromocaoProduto = `EXISTS (
+	SELECT 1 FROM promocao_alvos a
+	WHERE a.promocao_id = pr.id AND (
+		(a.tipo = 'produto' AND a.alvo_id = p.id)
+		OR (a.tipo = 'categoria' AND a.alvo_id IN (
+			WITH RECURSIVE ancestrais AS (
+				SELECT c.id, c.categoria_pai_id
+				FROM categorias c JOIN produto_categorias pc ON pc.categoria_id = c.id
+				WHERE pc.produto_id = p.id
+				UNION
+				SELECT c.id, c.categoria_pai_id FROM categorias c JOIN ancestrais an ON c.id = an.categoria_pai_id
+			)
+			SELECT id FROM ancestrais))))`
+
+const sqlAlvoPromocaoServico = `EXISTS (
+	SELECT 1 FROM promocao_alvos a
+	WHERE a.promocao_id = pr.id AND a.tipo = 'servico' AND a.alvo_id = s.id)`
+
+// sqlPrecoEfetivoProduto e sqlPrecoEfetivoServico calculam o menor preço entre
+// o normal e o de cada promoção vigente (mesma regra de melhorPromocao).
+const sqlPrecoEfetivoProduto = `LEAST(p.preco, COALESCE((
+	SELECT MIN(CASE WHEN pr.tipo = 'percentual' THEN ROUND(p.preco * (100 - pr.valor) / 100, 2) ELSE pr.valor END)
+	FROM promocoes pr WHERE ` + sqlPromocaoVigente + ` AND ` + sqlAlvoPromocaoProduto + `), p.preco))`
+
+const sqlPrecoEfetivoServico = `LEAST(s.preco, COALESCE((
+	SELECT MIN(CASE WHEN pr.tipo = 'percentual' THEN ROUND(s.preco * (100 - pr.valor) / 100, 2) ELSE pr.valor END)
+	FROM promocoes pr WHERE ` + sqlPromocaoVigente + ` AND ` + sqlAlvoPromocaoServico + `), s.preco))`
+
+const sqlProdutoEmOferta = `(p.oferta OR EXISTS (
+	SELECT 1 FROM promocoes pr WHERE ` + sqlPromocaoVigente + ` AND ` + sqlAlvoPromocaoProduto + `))`
+
+const sqlServicoEmOferta = `(s.oferta OR EXISTS (
+	SELECT 1 FROM promocoes pr WHERE ` + sqlPromocaoVigente + ` AND ` + sqlAlvoPromocaoServico + `))`
+
+// consultasPromocoesVigentes lista, por tipo de item, as promoções em vigor
+// para os IDs em $1.
+var consultasPromocoesVigentes = map[string]string{
+	"produto": `SELECT p.id, pr.id, pr.nome, pr.tipo, pr.valor, pr.fim
+		FROM produtos p JOIN promocoes pr ON ` + sqlPromocaoVigente + ` AND ` + sqlAlvoPromocaoProduto + `
+		WHERE p.id = ANY($1)`,
+	"servico": `SELECT s.id, pr.id, pr.nome, pr.tipo, pr.valor, pr.fim
+		FROM servicos s JOIN promocoes pr ON ` + sqlPromocaoVigente + ` AND ` + sqlAlvoPromocaoServico + `
+		WHERE s.id = ANY($1)`,
+}
+
+// tabelasAlvoPromocao valida a existência dos alvos.
+var tabelasAlvoPromocao = map[string]string{
+	"produto":   "produtos",
+	"servico":   "servicos",
+	"categoria": "categorias",
+}
+
+const sqlSituacaoPromocao = `CASE
+	WHEN NOT pr.ativo THEN 'inativa'
+	WHEN NOW() < pr.inicio THEN 'agendada'
+	WHEN NOW() >= pr.fim THEN 'encerrada'
+	ELSE 'vigente' END`
+
+const colunasPromocao = `pr.id, pr.nome, pr.descricao, pr.tipo, pr.valor, pr.inicio, pr.fim, pr.ativo, ` + sqlSituacaoPromocao + `,
+	pr.iniciada_em, pr.encerrada_em, pr.criado_em, pr.atualizado_em`
+
+func scanPromocao(row linhaSQL) (models.Promocao, error) {
+	var pr models.Promocao
+	var descricao sql.NullString
+	err := row.Scan(&pr.ID, &pr.Nome, &descricao, &pr.Tipo, &pr.Valor, &pr.Inicio, &pr.Fim, &pr.Ativo, &pr.Situacao,
+		&pr.IniciadaEm, &pr.EncerradaEm, &pr.CriadoEm, &pr.AtualizadoEm)
+	pr.Descricao = descricao.String
+	pr.Alvos = []models.AlvoPromocao{}
+	return pr, err
+}
+
+func carregarPromocoesVigentes(db *sql.DB, tipo string, ids []int) (map[int][]models.PromocaoAplicada, error) {
+	resultado := map[int][]models.PromocaoAplicada{}
+	if len(ids) == 0 {
+		return resultado, nil
+	}
+	rows, err := db.Query(consultasPromocoesVigentes[tipo], pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemID int
+		var pr models.PromocaoAplicada
+		if err := rows.Scan(&itemID, &pr.ID, &pr.Nome, &pr.Tipo, &pr.Valor, &pr.Fim); err != nil {
+			return nil, err
+		}
+		resultado[itemID] = append(resultado[itemID], pr)
+	}
+	return resultado, rows.Err()
+}
+
+// melhorPromocao devolve o menor preço obtido com as promoções e a promoção
+// responsável (nil se nenhuma baixa o preço). Em empate vence a que termina
+// antes. precoFixo=false ignora promoções de preço fixo.
+func melhorPromocao(preco float64, promocoes []models.PromocaoAplicada, precoFixo bool) (float64, *models.PromocaoAplicada) {
+	melhor := preco
+	var escolhida *models.PromocaoAplicada
+	for i := range promocoes {
+		pr := &promocoes[i]
+		var candidato float64
+		switch {
+		case pr.Tipo == "percentual":
+			candidato = precoComDesconto(preco, pr.Valor)
+		case precoFixo:
+			candidato = pr.Valor
+		default:
+			continue
+		}
+		if candidato < melhor || (escolhida != nil && candidato == melhor && pr.Fim.Before(escolhida.Fim)) {
+			melhor, escolhida = candidato, pr
+		}
+	}
+	return melhor, escolhida
+}
+
+// precoComDesconto calcula ROUND(preco * (100 - percentual) / 100, 2) como o
+// PostgreSQL: em centavos inteiros, arredondando a metade para cima. Com
+// float64 direto, 1.15 a 50% daria 0.57 em vez de 0.58.
+func precoComDesconto(preco, percentual float64) float64 {
+	centavos := math.Round(preco * 100)
+	fator := math.Round((100 - percentual) * 100)
+	return math.Round(centavos*fator/10000) / 100
+}
+
+// aplicarPromocoesProdutos preenche preço efetivo, promoção e oferta dos
+// produtos e de suas variantes (já carregadas).
+func aplicarPromocoesProdutos(db *sql.DB, produtos []models.Produto) error {
+	ids := make([]int, len(produtos))
+	for i, p := range produtos {
+		ids[i] = p.ID
+	}
+	promocoes, err := carregarPromocoesVigentes(db, "produto", ids)
+	if err != nil {
+		return err
+	}
+	for i := range produtos {
+		p := &produtos[i]
+		p.PrecoEfetivo, p.Promocao = melhorPromocao(p.Preco, promocoes[p.ID], true)
+		if p.Promocao != nil {
+			p.Oferta = true
+		}
+		for j := range p.Variantes {
+			v := &p.Variantes[j]
+			v.PrecoEfetivo, _ = melhorPromocao(v.Preco, promocoes[p.ID], v.PrecoProprio == nil)
+		}
+	}
+	return nil
+}
+
+func aplicarPromocoesServicos(db *sql.DB, servicos []models.Servico) error {
+	ids := make([]int, len(servicos))
+	for i, s := range servicos {
+		ids[i] = s.ID
+	}
+	promocoes, err := carregarPromocoesVigentes(db, "servico", ids)
+	if err != nil {
+		return err
+	}
+	for i := range servicos {
+		s := &servicos[i]
+		s.PrecoEfetivo, s.Promocao = melhorPromocao(s.Preco, promocoes[s.ID], true)
+		if s.Promocao != nil {
+			s.Oferta = true
+		}
+	}
+	return nil
+}
+
+func carregarAlvosPromocoes(db *sql.DB, promocoes []models.Promocao) error {
+	if len(promocoes) == 0 {
+		return nil
+	}
+	indices := map[int]int{}
+	ids := make([]int, len(promocoes))
+	for i, pr := range promocoes {
+		indices[pr.ID] = i
+		ids[i] = pr.ID
+	}
+
+	rows, err := db.Query(`
+		SELECT a.promocao_id, a.tipo, a.alvo_id, COALESCE(p.nome, s.nome, c.nome, '')
+		FROM promocao_alvos a
+		LEFT JOIN produtos p ON a.tipo = 'produto' AND p.id = a.alvo_id
+		LEFT JOIN servicos s ON a.tipo = 'servico' AND s.id = a.alvo_id
+		LEFT JOIN categorias c ON a.tipo = 'categoria' AND c.id = a.alvo_id
+		WHERE a.promocao_id = ANY($1)
+		ORDER BY a.tipo, a.alvo_id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var promocaoID int
+		var alvo models.AlvoPromocao
+		if err := rows.Scan(&promocaoID, &alvo.Tipo, &alvo.ID, &alvo.Nome); err != nil {
+			return err
+		}
+		i := indices[promocaoID]
+		promocoes[i].Alvos = append(promocoes[i].Alvos, alvo)
+	}
+	return rows.Err()
+}
+
+func listarPromocoes(db *sql.DB, filtro string, args ...interface{}) ([]models.Promocao, error) {
+	rows, err := db.Query(`SELECT `+colunasPromocao+` FROM promocoes pr`+filtro+` ORDER BY pr.inicio DESC, pr.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promocoes := make([]models.Promocao, 0)
+	for rows.Next() {
+		pr, err := scanPromocao(rows)
+		if err != nil {
+			return nil, err
+		}
+		promocoes = append(promocoes, pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return promocoes, carregarAlvosPromocoes(db, promocoes)
+}
+
+// ListarPromocoesVigentes é a vitrine pública das promoções em andamento.
+func ListarPromocoesVigentes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	promocoes, err := listarPromocoes(db, ` WHERE `+sqlPromocaoVigente)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, promocoes)
+}
+
+// ListarPromocoes aceita ?situacao= (agendada, vigente, encerrada, inativa).
+func ListarPromocoes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	filtro := ""
+	var args []interface{}
+	if situacao := c.Query("situacao"); situacao != "" {
+		switch situacao {
+		case "agendada", "vigente", "encerrada", "inativa":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Situação inválida", "opcoes": []string{"agendada", "vigente", "encerrada", "inativa"}})
+			return
+		}
+		filtro = ` WHERE ` + sqlSituacaoPromocao + ` = $1`
+		args = append(args, situacao)
+	}
+
+	promocoes, err := listarPromocoes(db, filtro, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, promocoes)
+}
+
+func ObterPromocao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	promocoes, err := listarPromocoes(db, ` WHERE pr.id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoção", "detalhes": err.Error()})
+		return
+	}
+	if len(promocoes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Promoção não encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, promocoes[0])
+}
+
+// validarPromocao confere as regras que o binding não cobre e a existência
+// dos alvos. Quando retorna false a resposta de erro já foi enviada.
+func validarPromocao(c *gin.Context, db *sql.DB, req *models.PromocaoRequest) bool {
+	if !req.Fim.After(req.Inicio) {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "'fim' deve ser posterior a 'inicio'"})
+		return false
+	}
+	if req.Tipo == "percentual" && req.Valor >= 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "O desconto percentual deve ser menor que 100"})
+		return false
+	}
+
+	porTipo := map[string][]int{}
+	vistos := map[models.AlvoPromocao]bool{}
+	for _, alvo := range req.Alvos {
+		if req.Tipo == "preco" && alvo.Tipo == "categoria" {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "Promoções de preço fixo não podem ter categorias como alvo; use desconto percentual"})
+			return false
+		}
+		chave := models.AlvoPromocao{Tipo: alvo.Tipo, ID: alvo.ID}
+		if !vistos[chave] {
+			vistos[chave] = true
+			porTipo[alvo.Tipo] = append(porTipo[alvo.Tipo], alvo.ID)
+		}
+	}
+	for tipo, ids := range porTipo {
+		var encontrados int
+		err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE id = ANY($1)`, tabelasAlvoPromocao[tipo]), pq.Array(ids)).Scan(&encontrados)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao validar alvos da promoção", "detalhes": err.Error()})
+			return false
+		}
+		if encontrados != len(ids) {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("Um ou mais alvos do tipo '%s' não existem", tipo)})
+			return false
+		}
+	}
+	return true
+}
+
+// salvarPromocao cria (id == 0) ou substitui uma promoção e seus alvos.
+func salvarPromocao(c *gin.Context, id int) {
+	var req models.PromocaoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	req.Nome = strings.TrimSpace(req.Nome)
+
+	db := c.MustGet("db").(*sql.DB)
+	if !validarPromocao(c, db, &req) {
+		return
+	}
+	ativo := req.Ativo == nil || *req.Ativo
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	status := http.StatusOK
+	if id == 0 {
+		status = http.StatusCreated
+		err = tx.QueryRow(`
+			INSERT INTO promocoes (nome, descricao, tipo, valor, inicio, fim, ativo)
+			VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7)
+			RETURNING id`,
+			req.Nome, req.Descricao, req.Tipo, req.Valor, req.Inicio, req.Fim, ativo).Scan(&id)
+	} else {
+		// Mudar o período reabre os marcos registrados pela tarefa periódica.
+		var result sql.Result
+		result, err = tx.Exec(`
+			UPDATE promocoes
+			SET nome = $1, descricao = NULLIF($2, ''), tipo = $3, valor = $4, inicio = $5, fim = $6, ativo = $7,
+				iniciada_em = CASE WHEN inicio = $5 THEN iniciada_em END,
+				encerrada_em = CASE WHEN fim = $6 THEN encerrada_em END,
+				atualizado_em = CURRENT_TIMESTAMP
+			WHERE id = $8`,
+			req.Nome, req.Descricao, req.Tipo, req.Valor, req.Inicio, req.Fim, ativo, id)
+		if err == nil {
+			if n, _ := result.RowsAffected(); n == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"erro": "Promoção não encontrada"})
+				return
+			}
+			_, err = tx.Exec(`DELETE FROM promocao_alvos WHERE promocao_id = $1`, id)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar promoção", "detalhes": err.Error()})
+		return
+	}
+
+	for _, alvo := range req.Alvos {
+		if _, err := tx.Exec(`
+			INSERT INTO promocao_alvos (promocao_id, tipo, alvo_id) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`, id, alvo.Tipo, alvo.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar alvos da promoção", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar promoção"})
+		return
+	}
+	registrarHistoricoPrecosCatalogo(db)
+
+	promocoes, err := listarPromocoes(db, ` WHERE pr.id = $1`, id)
+	if err != nil || len(promocoes) == 0 {
+		c.JSON(status, gin.H{"id": id, "mensagem": "Promoção salva com sucesso"})
+		return
+	}
+	c.JSON(status, promocoes[0])
+}
+
+func CriarPromocao(c *gin.Context) {
+	salvarPromocao(c, 0)
+}
+
+func AtualizarPromocao(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+	salvarPromocao(c, id)
+}
+
+// EncerrarPromocao antecipa o fim de uma promoção para agora.
+func EncerrarPromocao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE promocoes
+		SET fim = GREATEST(inicio + INTERVAL '1 second', NOW()), encerrada_em = NOW(), atualizado_em = CURRENT_TIMESTAMP
+		WHERE id = $1 AND fim > NOW()`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao encerrar promoção", "detalhes": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Promoção não encontrada ou já encerrada"})
+		return
+	}
+	registrarHistoricoPrecosCatalogo(db)
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Promoção encerrada com sucesso"})
+}
+
+func DeletarPromocao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	result, err := db.Exec(`DELETE FROM promocoes WHERE id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir promoção", "detalhes": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Promoção não encontrada"})
+		return
+	}
+	registrarHistoricoPrecosCatalogo(db)
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Promoção excluída com sucesso"})
+}
+
+// intervaloPromocoes lê PROMOCOES_VERIFICACAO_MINUTOS (padrão 1; 0 desativa).
+func intervaloPromocoes() time.Duration {
+	minutos := 1
+	if v, err := strconv.Atoi(os.Getenv("PROMOCOES_VERIFICACAO_MINUTOS")); err == nil && v >= 0 {
+		minutos = v
+	}
+	return time.Duration(minutos) * time.Minute
+}
+
+// atualizarPromocoes registra quando cada promoção começou e terminou. O
+// preço efetivo já é calculado na hora da consulta; os marcos servem à
+// listagem por situação e disparam o registro no histórico de preços.
+func atualizarPromocoes(ctx context.Context, db *sql.DB) error {
+	iniciadas, err := db.ExecContext(ctx, `
+		UPDATE promocoes SET iniciada_em = inicio
+		WHERE ativo AND iniciada_em IS NULL AND inicio <= NOW() AND fim > NOW()`)
+	if err != nil {
+		return fmt.Errorf("marcar promoções iniciadas: %w", err)
+	}
+	encerradas, err := db.ExecContext(ctx, `
+		UPDATE promocoes SET encerrada_em = fim
+		WHERE encerrada_em IS NULL AND fim <= NOW()`)
+	if err != nil {
+		return fmt.Errorf("encerrar promoções: %w", err)
+	}
+	n, _ := iniciadas.RowsAffected()
+	m, _ := encerradas.RowsAffected()
+	if n > 0 || m > 0 {
+		log.Printf("Promoções: %d iniciada(s), %d encerrada(s)", n, m)
+		if err := registrarHistoricoPrecos(db, "promocao", nil); err != nil {
+			return fmt.Errorf("registrar histórico de preços: %w", err)
+		}
+	}
+	return nil
+}
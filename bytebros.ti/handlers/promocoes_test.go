@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"bytebros.ti/models"
+)
+
+// Os valores esperados são os de ROUND(preco * (100 - valor) / 100, 2) no
+// PostgreSQL, usado em sqlPrecoEfetivoProduto.
+func TestMelhorPromocaoArredondaComoSQL(t *testing.T) {
+	casos := []struct {
+		preco, percentual, esperado float64
+	}{
+		{1.15, 50, 0.58},
+		{0.05, 50, 0.03},
+		{10.05, 50, 5.03},
+		{19.99, 15, 16.99},
+		{99.90, 12.5, 87.41},
+		{100, 99.99, 0.01},
+	}
+	for _, caso := range casos {
+		promocoes := []models.PromocaoAplicada{{ID: 1, Tipo: "percentual", Valor: caso.percentual, Fim: time.Now()}}
+		obtido, escolhida := melhorPromocao(caso.preco, promocoes, true)
+		if obtido != caso.esperado {
+			t.Errorf("%.2f com %.2f%% = %v, esperado %v", caso.preco, caso.percentual, obtido, caso.esperado)
+		}
+		if escolhida == nil {
+			t.Errorf("%.2f com %.2f%%: promoção não foi escolhida", caso.preco, caso.percentual)
+		}
+	}
+}
+
+func TestMelhorPromocaoPrecoFixoEEmpate(t *testing.T) {
+	agora := time.Now()
+	promocoes := []models.PromocaoAplicada{
+		{ID: 1, Tipo: "percentual", Valor: 50, Fim: agora.Add(48 * time.Hour)},
+		{ID: 2, Tipo: "preco", Valor: 5, Fim: agora.Add(24 * time.Hour)},
+	}
+
+	preco, escolhida := melhorPromocao(10, promocoes, true)
+	if preco != 5 || escolhida == nil || escolhida.ID != 2 {
+		t.Errorf("empate: preço %v, promoção %+v; esperado 5 pela que termina antes", preco, escolhida)
+	}
+
+	preco, escolhida = melhorPromocao(10, promocoes, false)
+	if preco != 5 || escolhida == nil || escolhida.ID != 1 {
+		t.Errorf("sem preço fixo: preço %v, promoção %+v; esperado 5 pela percentual", preco, escolhida)
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	diasPadraoRelacionados   = 365
+	maximoRelacionadosGravar = 20
+	limitePadraoRelacionados = 8
+)
+
+// intervaloProdutosRelacionados lê RELACIONADOS_ATUALIZACAO_MINUTOS (padrão
+// 360; 0 desativa).
+func intervaloProdutosRelacionados() time.Duration {
+	minutos := 360
+	if v, err := strconv.Atoi(os.Getenv("RELACIONADOS_ATUALIZACAO_MINUTOS")); err == nil && v >= 0 {
+		minutos = v
+	}
+	return time.Duration(minutos) * time.Minute
+}
+
+// atualizarProdutosRelacionados recalcula, a partir dos pedidos não
+// cancelados dos últimos RELACIONADOS_DIAS (padrão 365), quais produtos são
+// comprados juntos. Cada produto guarda os 20 pares mais frequentes; a troca
+// acontece numa transação, então as consultas nunca veem a tabela vazia.
+func atualizarProdutosRelacionados(ctx context.Context, db *sql.DB) error {
+	dias := diasPadraoRelacionados
+	if v, err := strconv.Atoi(os.Getenv("RELACIONADOS_DIAS")); err == nil && v > 0 {
+		dias = v
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM produtos_relacionados`); err != nil {
+		return fmt.Errorf("limpar produtos relacionados: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO produtos_relacionados (produto_id, relacionado_id, pedidos)
+		SELECT produto_id, relacionado_id, pedidos
+		FROM (
+			SELECT a.produto_id, b.produto_id AS relacionado_id, COUNT(DISTINCT a.pedido_id) AS pedidos,
+				ROW_NUMBER() OVER (PARTITION BY a.produto_id ORDER BY COUNT(DISTINCT a.pedido_id) DESC, b.produto_id) AS posicao
+			FROM pedido_itens a
+			JOIN pedido_itens b ON b.pedido_id = a.pedido_id AND b.produto_id <> a.produto_id
+			JOIN pedidos pe ON pe.id = a.pedido_id
+			WHERE LOWER(pe.status) <> 'cancelado' AND pe.data_pedido >= NOW() - make_interval(days => $1::int)
+			GROUP BY a.produto_id, b.produto_id
+		) pares
+		WHERE posicao <= $2`, dias, maximoRelacionadosGravar)
+	if err != nil {
+		return fmt.Errorf("calcular produtos relacionados: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	n, _ := result.RowsAffected()
+	log.Printf("Produtos relacionados: %d par(es) gravado(s)", n)
+	return nil
+}
+
+// sugerirProdutosRelacionados monta até limite sugestões para os produtos
+// base: primeiro os mais comprados junto com eles, depois os que dividem mais
+// categorias com eles (desempatando pelos mais vendidos). Ficam de fora os
+// próprios produtos base e os sem estoque.
+func sugerirProdutosRelacionados(db *sql.DB, base []int, limite int) ([]models.ProdutoRelacionado, error) {
+	var ids []int
+	origens := map[int]string{}
+	pedidos := map[int]int{}
+
+	rows, err := db.Query(`
+		SELECT r.relacionado_id, SUM(r.pedidos) AS total
+		FROM produtos_relacionados r
+		JOIN produtos p ON p.id = r.relacionado_id
+		WHERE r.produto_id = ANY($1) AND NOT r.relacionado_id = ANY($1) AND `+sqlProdutoEmEstoque+`
+		GROUP BY r.relacionado_id
+		ORDER BY total DESC, r.relacionado_id
+		LIMIT $2`, pq.Array(base), limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, total int
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		origens[id] = "comprados_juntos"
+		pedidos[id] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) < limite {
+		excluidos := append(append([]int{}, base...), ids...)
+		categoriaRows, err := db.Query(`
+			SELECT p.id
+			FROM produtos p
+			JOIN produto_categorias pc ON pc.produto_id = p.id
+			WHERE pc.categoria_id IN (SELECT categoria_id FROM produto_categorias WHERE produto_id = ANY($1))
+				AND NOT p.id = ANY($2) AND `+sqlProdutoEmEstoque+`
+			GROUP BY p.id
+			ORDER BY COUNT(*) DESC, `+sqlQuantidadeVendida+` DESC, p.id
+			LIMIT $3`, pq.Array(base), pq.Array(excluidos), limite-len(ids))
+		if err != nil {
+			return nil, err
+		}
+		defer categoriaRows.Close()
+		for categoriaRows.Next() {
+			var id int
+			if err := categoriaRows.Scan(&id); err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+			origens[id] = "mesma_categoria"
+		}
+		if err := categoriaRows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sugestoes := make([]models.ProdutoRelacionado, 0, len(ids))
+	if len(ids) == 0 {
+		return sugestoes, nil
+	}
+
+	detalhes := map[int]models.ProdutoRelacionado{}
+	detalheRows, err := db.Query(`
+		SELECT p.id, p.nome, p.imagem, p.preco, p.oferta, `+sqlMediaAvaliacoes+`, `+sqlTotalAvaliacoes+`
+		FROM produtos p
+		WHERE p.id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer detalheRows.Close()
+	for detalheRows.Next() {
+		var s models.ProdutoRelacionado
+		var imagem sql.NullString
+		var media sql.NullFloat64
+		if err := detalheRows.Scan(&s.ID, &s.Nome, &imagem, &s.Preco, &s.Oferta, &media, &s.TotalAvaliacoes); err != nil {
+			return nil, err
+		}
+		s.Imagem = imagem.String
+		if media.Valid {
+			s.AvaliacaoMedia = &media.Float64
+		}
+		detalhes[s.ID] = s
+	}
+	if err := detalheRows.Err(); err != nil {
+		return nil, err
+	}
+
+	produtos := make([]models.Produto, 0, len(ids))
+	for _, id := range ids {
+		s, ok := detalhes[id]
+		if !ok {
+			continue
+		}
+		s.Origem = origens[id]
+		s.PedidosEmComum = pedidos[id]
+		sugestoes = append(sugestoes, s)
+		produtos = append(produtos, models.Produto{ID: s.ID, Preco: s.Preco, Oferta: s.Oferta})
+	}
+	if err := aplicarPromocoesProdutos(db, produtos); err != nil {
+		return nil, err
+	}
+	for i := range sugestoes {
+		sugestoes[i].PrecoEfetivo = produtos[i].PrecoEfetivo
+		sugestoes[i].Oferta = produtos[i].Oferta
+	}
+	return sugestoes, nil
+}
+
+func limiteRelacionados(c *gin.Context) int {
+	limite := limitePadraoRelacionados
+	if v, err := strconv.Atoi(c.Query("limite")); err == nil && v > 0 && v <= maximoRelacionadosGravar {
+		limite = v
+	}
+	return limite
+}
+
+// ListarProdutosRelacionados atende a página do produto.
+func ListarProdutosRelacionados(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	var existe bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM produtos WHERE id = $1)`, produtoID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+
+	sugestoes, err := sugerirProdutosRelacionados(db, []int{produtoID}, limiteRelacionados(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produtos relacionados", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"produto_id": produtoID, "relacionados": sugestoes})
+}
+
+// lerIDsQuery lê ?ids=1,2,3 exigindo entre minimo e maximo IDs. Com ok false a
+// resposta de erro já foi enviada.
+func lerIDsQuery(c *gin.Context, minimo, maximo int) (ids []int, ok bool) {
+	for _, texto := range strings.Split(c.Query("ids"), ",") {
+		if texto = strings.TrimSpace(texto); texto == "" {
+			continue
+		}
+		id, err := strconv.Atoi(texto)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": "'ids' deve ser uma lista de IDs separados por vírgula"})
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) < minimo || len(ids) > maximo {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("Informe de %d a %d produtos em 'ids'", minimo, maximo)})
+		return nil, false
+	}
+	return ids, true
+}
+
+// ListarRelacionadosCarrinho sugere produtos para o conjunto do carrinho
+// (?ids=1,2,3), sem repetir os que já estão nele.
+func ListarRelacionadosCarrinho(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	ids, ok := lerIDsQuery(c, 1, 100)
+	if !ok {
+		return
+	}
+
+	sugestoes, err := sugerirProdutosRelacionados(db, ids, limiteRelacionados(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produtos relacionados", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"produto_ids": ids, "relacionados": sugestoes})
+}
+
+// AtualizarProdutosRelacionados recalcula a tabela na hora, sem esperar a
+// tarefa periódica.
+func AtualizarProdutosRelacionados(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	if err := atualizarProdutosRelacionados(c.Request.Context(), db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar produtos relacionados", "detalhes": err.Error()})
+		return
+	}
+	var pares int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM produtos_relacionados`).Scan(&pares); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao contar produtos relacionados", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Produtos relacionados atualizados", "pares": pares})
+}
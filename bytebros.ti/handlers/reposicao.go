@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// sqlEstoqueTotal soma o saldo do produto p com o das variantes ativas.
+const sqlEstoqueTotal = `(p.quantidade + COALESCE((
+	SELECT SUM(v.quantidade) FROM produto_variantes v WHERE v.produto_id = p.id AND v.ativo), 0))`
+
+const (
+	diasVendasReposicao      = 30
+	diasCoberturaReposicao   = 30
+	maximoDiasReposicao      = 365
+	intervaloPadraoAlertaMin = 15
+)
+
+// intervaloAlertasEstoque lê ESTOQUE_VERIFICACAO_MINUTOS (padrão 15; 0 desativa).
+func intervaloAlertasEstoque() time.Duration {
+	minutos := intervaloPadraoAlertaMin
+	if v, err := strconv.Atoi(os.Getenv("ESTOQUE_VERIFICACAO_MINUTOS")); err == nil && v >= 0 {
+		minutos = v
+	}
+	return time.Duration(minutos) * time.Minute
+}
+
+// listaEnv separa uma variável de ambiente por vírgulas, em minúsculas.
+func listaEnv(nome string) []string {
+	var itens []string
+	for _, item := range strings.Split(os.Getenv(nome), ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			itens = append(itens, item)
+		}
+	}
+	return itens
+}
+
+func executarAlertasEstoque(ctx context.Context, db *sql.DB) error {
+	_, err := verificarEstoqueMinimo(ctx, db)
+	return err
+}
+
+// verificarEstoqueMinimo alerta a equipe sobre produtos que chegaram ao
+// estoque mínimo. Cada produto é alertado uma vez até o estoque voltar acima
+// do mínimo; devolve os produtos alertados nesta execução.
+func verificarEstoqueMinimo(ctx context.Context, db *sql.DB) ([]models.SugestaoReposicao, error) {
+	if _, err := db.ExecContext(ctx, `
+		UPDATE produtos p SET alerta_estoque_em = NULL
+		WHERE p.alerta_estoque_em IS NOT NULL
+			AND (p.estoque_minimo = 0 OR `+sqlEstoqueTotal+` > p.estoque_minimo)`); err != nil {
+		return nil, fmt.Errorf("rearmar alertas: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE produtos p SET alerta_estoque_em = CURRENT_TIMESTAMP
+		WHERE p.estoque_minimo > 0 AND p.alerta_estoque_em IS NULL
+			AND `+sqlEstoqueTotal+` <= p.estoque_minimo
+		RETURNING p.id, p.nome, `+sqlEstoqueTotal+`, p.estoque_minimo`)
+	if err != nil {
+		return nil, fmt.Errorf("marcar produtos abaixo do mínimo: %w", err)
+	}
+	var abaixo []models.SugestaoReposicao
+	for rows.Next() {
+		var s models.SugestaoReposicao
+		if err := rows.Scan(&s.ProdutoID, &s.Nome, &s.Estoque, &s.EstoqueMinimo); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		abaixo = append(abaixo, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(abaixo) == 0 {
+		return []models.SugestaoReposicao{}, nil
+	}
+
+	cargos := listaEnv("ALERTA_ESTOQUE_CARGOS")
+	for _, s := range abaixo {
+		err := notificarEquipe(tx, cargos, "estoque_baixo",
+			"Estoque baixo: "+s.Nome,
+			fmt.Sprintf("%s está com %d unidade(s); o mínimo configurado é %d.", s.Nome, s.Estoque, s.EstoqueMinimo),
+			gin.H{"produto_id": s.ProdutoID, "estoque": s.Estoque, "estoque_minimo": s.EstoqueMinimo})
+		if err != nil {
+			return nil, fmt.Errorf("notificar equipe: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// O email é um resumo com a sugestão de compra; falhas não desfazem os
+	// alertas já gravados no painel.
+	ids := make([]int, len(abaixo))
+	for i, s := range abaixo {
+		ids[i] = s.ProdutoID
+	}
+	sugestoes, err := calcularSugestoesReposicao(ctx, db, diasVendasReposicao, diasCoberturaReposicao, ids)
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao calcular sugestões de reposição: %v", err)
+		return abaixo, nil
+	}
+	if err := enviarEmailEstoqueBaixo(ctx, db, cargos, sugestoes); err != nil {
+		log.Printf("ERRO: Falha ao enviar email de estoque baixo: %v", err)
+	}
+	return sugestoes, nil
+}
+
+// enviarEmailEstoqueBaixo manda o resumo para ALERTA_ESTOQUE_EMAILS ou, sem
+// essa variável, para os emails da mesma equipe que recebe as notificações.
+func enviarEmailEstoqueBaixo(ctx context.Context, db *sql.DB, cargos []string, sugestoes []models.SugestaoReposicao) error {
+	destinatarios := listaEnv("ALERTA_ESTOQUE_EMAILS")
+	if len(destinatarios) == 0 {
+		if cargos == nil {
+			cargos = []string{}
+		}
+		rows, err := db.QueryContext(ctx, `
+			SELECT email FROM admin WHERE ativo
+			UNION
+			SELECT email FROM funcionarios
+			WHERE ativo AND (cardinality($1::text[]) = 0 OR LOWER(cargo) = ANY($1::text[]))`, pq.Array(cargos))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var email string
+			if err := rows.Scan(&email); err != nil {
+				return err
+			}
+			destinatarios = append(destinatarios, email)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	if len(destinatarios) == 0 {
+		return nil
+	}
+
+	var corpo strings.Builder
+	corpo.WriteString("Os produtos abaixo atingiram o estoque mínimo:\n\n")
+	for _, s := range sugestoes {
+		fmt.Fprintf(&corpo, "- %s (#%d): %d em estoque, mínimo %d", s.Nome, s.ProdutoID, s.Estoque, s.EstoqueMinimo)
+		if s.Sugestao > 0 {
+			fmt.Fprintf(&corpo, "; sugestão de compra: %d", s.Sugestao)
+		}
+		corpo.WriteString("\n")
+	}
+	fmt.Fprintf(&corpo, "\nSugestões calculadas com as vendas dos últimos %d dias para cobrir %d dias.\n", diasVendasReposicao, diasCoberturaReposicao)
+
+	envio, cancelar := context.WithTimeout(ctx, 30*time.Second)
+	defer cancelar()
+	assunto := fmt.Sprintf("Estoque baixo em %d produto(s)", len(sugestoes))
+	return obterMailer().Enviar(envio, destinatarios, assunto, corpo.String())
+}
+
+// calcularSugestoesReposicao estima a velocidade diária de vendas dos últimos
+// dias (pedidos não cancelados) e sugere comprar o suficiente para cobrir
+// cobertura dias mantendo o estoque mínimo. Sem produtoIDs, considera os
+// produtos com mínimo configurado ou com vendas no período.
+func calcularSugestoesReposicao(ctx context.Context, db *sql.DB, dias, cobertura int, produtoIDs []int) ([]models.SugestaoReposicao, error) {
+	filtro := "p.estoque_minimo > 0 OR v.vendidos > 0"
+	args := []interface{}{dias}
+	if produtoIDs != nil {
+		filtro = "p.id = ANY($2)"
+		args = append(args, pq.Array(produtoIDs))
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		WITH vendas AS (
+			SELECT pi.produto_id, SUM(pi.quantidade) AS vendidos
+			FROM pedido_itens pi
+			JOIN pedidos pe ON pe.id = pi.pedido_id
+			WHERE pe.data_pedido >= NOW() - make_interval(days => $1::int)
+				AND LOWER(pe.status) <> 'cancelado'
+			GROUP BY pi.produto_id
+		)
+		SELECT p.id, p.nome, `+sqlEstoqueTotal+`, p.estoque_minimo, COALESCE(v.vendidos, 0)
+		FROM produtos p
+		LEFT JOIN vendas v ON v.produto_id = p.id
+		WHERE `+filtro, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sugestoes := make([]models.SugestaoReposicao, 0)
+	for rows.Next() {
+		var s models.SugestaoReposicao
+		if err := rows.Scan(&s.ProdutoID, &s.Nome, &s.Estoque, &s.EstoqueMinimo, &s.VendidosPeriodo); err != nil {
+			return nil, err
+		}
+		velocidade := float64(s.VendidosPeriodo) / float64(dias)
+		s.VelocidadeDiaria = math.Round(velocidade*100) / 100
+		if velocidade > 0 {
+			restantes := math.Round(float64(s.Estoque)/velocidade*10) / 10
+			s.DiasRestantes = &restantes
+		}
+		s.AbaixoMinimo = s.EstoqueMinimo > 0 && s.Estoque <= s.EstoqueMinimo
+		s.Sugestao = max(0, int(math.Ceil(velocidade*float64(cobertura)))+s.EstoqueMinimo-s.Estoque)
+		sugestoes = append(sugestoes, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Primeiro o que já está abaixo do mínimo, depois o que acaba antes.
+	sort.SliceStable(sugestoes, func(i, j int) bool {
+		a, b := sugestoes[i], sugestoes[j]
+		if a.AbaixoMinimo != b.AbaixoMinimo {
+			return a.AbaixoMinimo
+		}
+		if (a.DiasRestantes == nil) != (b.DiasRestantes == nil) {
+			return a.DiasRestantes != nil
+		}
+		if a.DiasRestantes != nil && *a.DiasRestantes != *b.DiasRestantes {
+			return *a.DiasRestantes < *b.DiasRestantes
+		}
+		return a.Nome < b.Nome
+	})
+	return sugestoes, nil
+}
+
+// RelatorioReposicao sugere quantidades de compra. ?dias= define a janela de
+// vendas, ?cobertura= quantos dias o estoque deve durar e ?todos=true inclui
+// produtos sem sugestão.
+func RelatorioReposicao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	parametros := map[string]int{"dias": diasVendasReposicao, "cobertura": diasCoberturaReposicao}
+	for nome := range parametros {
+		texto := c.Query(nome)
+		if texto == "" {
+			continue
+		}
+		valor, err := strconv.Atoi(texto)
+		if err != nil || valor < 1 || valor > maximoDiasReposicao {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": fmt.Sprintf("'%s' deve ser um número entre 1 e %d", nome, maximoDiasReposicao)})
+			return
+		}
+		parametros[nome] = valor
+	}
+
+	sugestoes, err := calcularSugestoesReposicao(c.Request.Context(), db, parametros["dias"], parametros["cobertura"], nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao calcular sugestões de reposição", "detalhes": err.Error()})
+		return
+	}
+
+	if c.Query("todos") != "true" {
+		filtradas := make([]models.SugestaoReposicao, 0, len(sugestoes))
+		for _, s := range sugestoes {
+			if s.Sugestao > 0 {
+				filtradas = append(filtradas, s)
+			}
+		}
+		sugestoes = filtradas
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"produtos":  sugestoes,
+		"total":     len(sugestoes),
+		"dias":      parametros["dias"],
+		"cobertura": parametros["cobertura"],
+	})
+}
+
+// VerificarAlertasEstoque executa a verificação de estoque mínimo na hora,
+// sem esperar a tarefa periódica.
+func VerificarAlertasEstoque(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	alertas, err := verificarEstoqueMinimo(c.Request.Context(), db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar estoque mínimo", "detalhes": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alertas": alertas, "total": len(alertas)})
+}
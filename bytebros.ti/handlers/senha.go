@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//go:embed dados/senhas_comuns.txt
+var senhasComunsTxt []byte
+
+// politicaSenha é lida das variáveis SENHA_TAMANHO_MINIMO (padrão 8),
+// SENHA_EXIGIR_MAIUSCULA, SENHA_EXIGIR_MINUSCULA, SENHA_EXIGIR_NUMERO (padrão
+// true), SENHA_EXIGIR_SIMBOLO (padrão false) e SENHA_BLOQUEAR_COMUNS (padrão true).
+type politicaSenha struct {
+	TamanhoMinimo   int
+	ExigirMaiuscula bool
+	ExigirMinuscula bool
+	ExigirNumero    bool
+	ExigirSimbolo   bool
+	BloquearComuns  bool
+}
+
+var (
+	senhasComunsOnce sync.Once
+	senhasComuns     map[string]bool
+)
+
+func envBool(nome string, padrao bool) bool {
+	valor, err := strconv.ParseBool(os.Getenv(nome))
+	if err != nil {
+		return padrao
+	}
+	return valor
+}
+
+func obterPoliticaSenha() politicaSenha {
+	p := politicaSenha{
+		TamanhoMinimo:   8,
+		ExigirMaiuscula: envBool("SENHA_EXIGIR_MAIUSCULA", true),
+		ExigirMinuscula: envBool("SENHA_EXIGIR_MINUSCULA", true),
+		ExigirNumero:    envBool("SENHA_EXIGIR_NUMERO", true),
+		ExigirSimbolo:   envBool("SENHA_EXIGIR_SIMBOLO", false),
+		BloquearComuns:  envBool("SENHA_BLOQUEAR_COMUNS", true),
+	}
+	if v, err := strconv.Atoi(os.Getenv("SENHA_TAMANHO_MINIMO")); err == nil && v > 0 {
+		p.TamanhoMinimo = v
+	}
+	return p
+}
+
+func senhaComum(senha string) bool {
+	senhasComunsOnce.Do(func() {
+		senhasComuns = make(map[string]bool)
+		scanner := bufio.NewScanner(bytes.NewReader(senhasComunsTxt))
+		for scanner.Scan() {
+			linha := strings.TrimSpace(scanner.Text())
+			if linha == "" || strings.HasPrefix(linha, "#") {
+				continue
+			}
+			senhasComuns[strings.ToLower(linha)] = true
+		}
+	})
+	return senhasComuns[strings.ToLower(senha)]
+}
+
+// validarSenha retorna a lista de requisitos da política que a senha não
+// atende (vazia quando a senha é aceita). O email do titular é usado para
+// impedir que ele seja reaproveitado como senha.
+func validarSenha(senha, email string) []string {
+	p := obterPoliticaSenha()
+	problemas := []string{}
+
+	if len([]rune(senha)) < p.TamanhoMinimo {
+		problemas = append(problemas, fmt.Sprintf("A senha deve ter pelo menos %d caracteres", p.TamanhoMinimo))
+	}
+	if len(senha) > tamanhoMaximoSenhaBytes {
+		problemas = append(problemas, fmt.Sprintf("A senha deve ter no máximo %d bytes", tamanhoMaximoSenhaBytes))
+	}
+
+	var maiuscula, minuscula, numero, simbolo bool
+	for _, r := range senha {
+		switch {
+		case unicode.IsUpper(r):
+			maiuscula = true
+		case unicode.IsLower(r):
+			minuscula = true
+		case unicode.IsDigit(r):
+			numero = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			simbolo = true
+		}
+	}
+	if p.ExigirMaiuscula && !maiuscula {
+		problemas = append(problemas, "A senha deve conter ao menos uma letra maiúscula")
+	}
+	if p.ExigirMinuscula && !minuscula {
+		problemas = append(problemas, "A senha deve conter ao menos uma letra minúscula")
+	}
+	if p.ExigirNumero && !numero {
+		problemas = append(problemas, "A senha deve conter ao menos um número")
+	}
+	if p.ExigirSimbolo && !simbolo {
+		problemas = append(problemas, "A senha deve conter ao menos um símbolo")
+	}
+
+	if p.BloquearComuns && senhaComum(senha) {
+		problemas = append(problemas, "A senha é muito comum e fácil de adivinhar")
+	}
+
+	if email != "" {
+		usuario := strings.SplitN(email, "@", 2)[0]
+		if strings.EqualFold(senha, email) || strings.EqualFold(senha, usuario) {
+			problemas = append(problemas, "A senha não pode ser igual ao email")
+		}
+	}
+
+	return problemas
+}
+
+// tamanhoMaximoSenhaBytes é o limite do bcrypt: senhas maiores são recusadas
+// por bcrypt.GenerateFromPassword.
+const tamanhoMaximoSenhaBytes = 72
+
+// custoBcrypt lê BCRYPT_CUSTO (padrão bcrypt.DefaultCost).
+func custoBcrypt() int {
+	custo, err := strconv.Atoi(os.Getenv("BCRYPT_CUSTO"))
+	if err != nil || custo < bcrypt.MinCost || custo > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return custo
+}
+
+func gerarHashSenha(senha string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(senha), custoBcrypt())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// atualizarHashSenha regrava o hash com o custo configurado quando o hash
+// armazenado usa um custo menor. Deve ser chamada logo após um login bem-sucedido,
+// único momento em que a senha em texto puro está disponível.
+func atualizarHashSenha(db *sql.DB, tabela string, id int, hashAtual, senha string) {
+	custoAtual, err := bcrypt.Cost([]byte(hashAtual))
+	if err != nil || custoAtual >= custoBcrypt() {
+		return
+	}
+
+	novoHash, err := gerarHashSenha(senha)
+	if err != nil {
+		log.Printf("ERRO: Falha ao recriptografar senha (%s %d): %v", tabela, id, err)
+		return
+	}
+	if _, err := db.Exec("UPDATE "+tabela+" SET senha_hash = $1 WHERE id = $2 AND senha_hash = $3", novoHash, id, hashAtual); err != nil {
+		log.Printf("ERRO BD: Falha ao atualizar hash de senha (%s %d): %v", tabela, id, err)
+		return
+	}
+}
+
+// senhaAtendePolitica responde 400 com os requisitos não atendidos quando a
+// senha é recusada pela política.
+func senhaAtendePolitica(c *gin.Context, senha, email string) bool {
+	if problemas := validarSenha(senha, email); len(problemas) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "A senha não atende à política de senhas", "problemas": problemas})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidarSenha(t *testing.T) {
+	casos := []struct {
+		nome      string
+		ambiente  map[string]string
+		senha     string
+		email     string
+		problemas []string
+	}{
+		{"atende a política padrão", nil, "Teclado42", "ana@exemplo.com", []string{}},
+		{"curta", nil, "Tec42ab", "", []string{"A senha deve ter pelo menos 8 caracteres"}},
+		{"tamanho conta caracteres, não bytes", nil, "Açãoé12", "", []string{"A senha deve ter pelo menos 8 caracteres"}},
+		{"tamanho mínimo configurado", map[string]string{"SENHA_TAMANHO_MINIMO": "12"}, "Teclado42xyz", "", []string{}},
+		{"abaixo do mínimo configurado", map[string]string{"SENHA_TAMANHO_MINIMO": "12"}, "Teclado42xy", "", []string{"A senha deve ter pelo menos 12 caracteres"}},
+		{"sem maiúscula", nil, "teclado42", "", []string{"A senha deve conter ao menos uma letra maiúscula"}},
+		{"maiúscula acentuada conta", nil, "Ávidos42x", "", []string{}},
+		{"sem minúscula", nil, "TECLADO42", "", []string{"A senha deve conter ao menos uma letra minúscula"}},
+		{"sem número", nil, "TecladoAzul", "", []string{"A senha deve conter ao menos um número"}},
+		{"sem símbolo quando exigido", map[string]string{"SENHA_EXIGIR_SIMBOLO": "true"}, "Teclado42", "", []string{"A senha deve conter ao menos um símbolo"}},
+		{"com símbolo quando exigido", map[string]string{"SENHA_EXIGIR_SIMBOLO": "true"}, "Teclado 42!", "", []string{}},
+		{"regras de classe desligadas", map[string]string{
+			"SENHA_EXIGIR_MAIUSCULA": "false", "SENHA_EXIGIR_MINUSCULA": "false", "SENHA_EXIGIR_NUMERO": "false",
+		}, "ppppqqqq", "", []string{}},
+		{"senha comum", nil, "Password1", "", []string{"A senha é muito comum e fácil de adivinhar"}},
+		{"senha comum liberada", map[string]string{"SENHA_BLOQUEAR_COMUNS": "false"}, "Password1", "", []string{}},
+		{"igual ao email", map[string]string{"SENHA_EXIGIR_NUMERO": "false"}, "Ana.Silva@Exemplo.com", "ana.silva@exemplo.com", []string{"A senha não pode ser igual ao email"}},
+		{"igual ao usuário do email", nil, "AnaSilva1990", "anasilva1990@exemplo.com", []string{"A senha não pode ser igual ao email"}},
+		{"contém o email mas é diferente", nil, "AnaSilva1990!", "anasilva1990@exemplo.com", []string{}},
+		{"72 bytes", nil, "Teclado42" + strings.Repeat("x", 63), "", []string{}},
+		{"73 bytes", nil, "Teclado42" + strings.Repeat("x", 64), "", []string{"A senha deve ter no máximo 72 bytes"}},
+		{"limite em bytes com acentos", nil, "Teclado42" + strings.Repeat("é", 32), "", []string{"A senha deve ter no máximo 72 bytes"}},
+		{"vários problemas", nil, "abc", "", []string{
+			"A senha deve ter pelo menos 8 caracteres",
+			"A senha deve conter ao menos uma letra maiúscula",
+			"A senha deve conter ao menos um número",
+		}},
+	}
+	variaveis := []string{"SENHA_TAMANHO_MINIMO", "SENHA_EXIGIR_MAIUSCULA", "SENHA_EXIGIR_MINUSCULA", "SENHA_EXIGIR_NUMERO", "SENHA_EXIGIR_SIMBOLO", "SENHA_BLOQUEAR_COMUNS"}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			for _, nome := range variaveis {
+				t.Setenv(nome, caso.ambiente[nome])
+			}
+			if problemas := validarSenha(caso.senha, caso.email); !reflect.DeepEqual(problemas, caso.problemas) {
+				t.Errorf("validarSenha(%q) = %q, esperado %q", caso.senha, problemas, caso.problemas)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// urlSite é o endereço público da loja usado nos links do sitemap e do feed
+// (SITE_URL; padrão, o front-end publicado).
+func urlSite() string {
+	site := os.Getenv("SITE_URL")
+	if site == "" {
+		site = "https://bytebros.netlify.app"
+	}
+	return strings.TrimSuffix(site, "/")
+}
+
+// urlAPI é o endereço público da própria API (API_URL; padrão, localhost na
+// porta do servidor). Não vem dos cabeçalhos Host e X-Forwarded-Proto, que o
+// cliente controla.
+func urlAPI() string {
+	api := os.Getenv("API_URL")
+	if api == "" {
+		porta := os.Getenv("PORT")
+		if porta == "" {
+			porta = "8080"
+		}
+		api = "http://localhost:" + porta
+	}
+	return strings.TrimSuffix(api, "/")
+}
+
+// urlAbsoluta completa URLs relativas (imagens no disco local) com o endereço
+// da própria API.
+func urlAbsoluta(endereco string) string {
+	if endereco == "" || strings.HasPrefix(endereco, "http://") || strings.HasPrefix(endereco, "https://") {
+		return endereco
+	}
+	return urlAPI() + "/" + strings.TrimPrefix(endereco, "/")
+}
+
+// responderXML envia o documento com a declaração XML, que os leitores de
+// sitemap e feed esperam e c.XML não inclui.
+func responderXML(c *gin.Context, documento interface{}) {
+	dados, err := xml.MarshalIndent(documento, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar XML", "detalhes": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), dados...))
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// Sitemap lista as páginas públicas de produtos, serviços, notícias e
+// categorias pelo slug.
+func Sitemap(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	site := urlSite()
+
+	consultas := []struct {
+		caminho string
+		query   string
+	}{
+		{"/produtos/", `SELECT slug, criado_em FROM produtos WHERE slug IS NOT NULL ORDER BY id`},
+		{"/servicos/", `SELECT slug, NULL::timestamp FROM servicos WHERE slug IS NOT NULL ORDER BY id`},
+		{"/noticias/", `SELECT slug, data FROM noticias WHERE slug IS NOT NULL ORDER BY data DESC`},
+		{"/categorias/", `SELECT slug, atualizado_em FROM categorias ORDER BY id`},
+	}
+
+	conjunto := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", URLs: []sitemapURL{{Loc: site + "/"}}}
+	for _, consulta := range consultas {
+		rows, err := db.Query(consulta.query)
+		if err != nil {
+			log.Printf("ERRO BD: Falha ao gerar sitemap: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar sitemap", "detalhes": err.Error()})
+			return
+		}
+		for rows.Next() {
+			var slug string
+			var data sql.NullTime
+			if err := rows.Scan(&slug, &data); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler sitemap", "detalhes": err.Error()})
+				return
+			}
+			u := sitemapURL{Loc: site + consulta.caminho + slug}
+			if data.Valid {
+				u.LastMod = data.Time.Format("2006-01-02")
+			}
+			conjunto.URLs = append(conjunto.URLs, u)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler sitemap", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	responderXML(c, conjunto)
+}
+
+type itemFeedMerchant struct {
+	ID               string `xml:"g:id"`
+	Titulo           string `xml:"title"`
+	Descricao        string `xml:"description"`
+	Link             string `xml:"link"`
+	Imagem           string `xml:"g:image_link,omitempty"`
+	Disponibilidade  string `xml:"g:availability"`
+	Preco            string `xml:"g:price"`
+	PrecoPromocional string `xml:"g:sale_price,omitempty"`
+	Condicao         string `xml:"g:condition"`
+	IdentificadorGTN string `xml:"g:identifier_exists"`
+	TipoProduto      string `xml:"g:product_type,omitempty"`
+}
+
+type canalFeedMerchant struct {
+	Titulo    string             `xml:"title"`
+	Link      string             `xml:"link"`
+	Descricao string             `xml:"description"`
+	Itens     []itemFeedMerchant `xml:"item"`
+}
+
+type feedMerchant struct {
+	XMLName xml.Name          `xml:"rss"`
+	Versao  string            `xml:"version,attr"`
+	XmlnsG  string            `xml:"xmlns:g,attr"`
+	Canal   canalFeedMerchant `xml:"channel"`
+}
+
+// FeedGoogleMerchant gera o feed de produtos no formato RSS 2.0 do Google
+// Merchant Center, com o preço da promoção vigente em g:sale_price.
+func FeedGoogleMerchant(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	site := urlSite()
+
+	rows, err := db.Query(`
+		SELECT p.id, COALESCE(p.sku, ''), p.nome, p.slug, COALESCE(p.detalhes, ''), p.preco,
+			` + sqlPrecoEfetivoProduto + `, ` + sqlProdutoEmEstoque + `,
+			COALESCE(NULLIF(p.imagem, ''), (
+				SELECT i.url FROM produto_imagens i WHERE i.produto_id = p.id ORDER BY i.ordem, i.id LIMIT 1), ''),
+			COALESCE((
+				SELECT c.nome FROM produto_categorias pc JOIN categorias c ON c.id = pc.categoria_id
+				WHERE pc.produto_id = p.id ORDER BY c.nome LIMIT 1), '')
+		FROM produtos p
+		WHERE p.slug IS NOT NULL
+		ORDER BY p.id`)
+	if err != nil {
+		log.Printf("ERRO BD: Falha ao gerar feed de produtos: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar feed", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	feed := feedMerchant{
+		Versao: "2.0",
+		XmlnsG: "http://base.google.com/ns/1.0",
+		Canal: canalFeedMerchant{
+			Titulo:    "ByteBros",
+			Link:      site,
+			Descricao: "Produtos da loja ByteBros",
+			Itens:     []itemFeedMerchant{},
+		},
+	}
+	for rows.Next() {
+		var id int
+		var sku, nome, slug, detalhes, imagem, categoria string
+		var preco, precoEfetivo float64
+		var emEstoque bool
+		if err := rows.Scan(&id, &sku, &nome, &slug, &detalhes, &preco, &precoEfetivo, &emEstoque, &imagem, &categoria); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler produtos", "detalhes": err.Error()})
+			return
+		}
+
+		item := itemFeedMerchant{
+			ID:               sku,
+			Titulo:           nome,
+			Descricao:        detalhes,
+			Link:             site + "/produtos/" + slug,
+			Imagem:           urlAbsoluta(imagem),
+			Disponibilidade:  "out_of_stock",
+			Preco:            fmt.Sprintf("%.2f BRL", preco),
+			Condicao:         "new",
+			IdentificadorGTN: "no",
+			TipoProduto:      categoria,
+		}
+		if item.ID == "" {
+			item.ID = fmt.Sprint(id)
+		}
+		if item.Descricao == "" {
+			item.Descricao = nome
+		}
+		if emEstoque {
+			item.Disponibilidade = "in_stock"
+		}
+		if precoEfetivo < preco {
+			item.PrecoPromocional = fmt.Sprintf("%.2f BRL", precoEfetivo)
+		}
+		feed.Canal.Itens = append(feed.Canal.Itens, item)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler produtos", "detalhes": err.Error()})
+		return
+	}
+
+	responderXML(c, feed)
+}
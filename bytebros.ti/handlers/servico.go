@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
+	"strconv"
 
 	"bytebros.ti/models"
 
@@ -19,8 +20,15 @@ func CriarServico(c *gin.Context) {
 
 	db := c.MustGet("db").(*sql.DB)
 
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
 	var servico models.Servico
-	err := db.QueryRow(`
+	err = tx.QueryRow(`
         INSERT INTO servicos (nome, preco, oferta, detalhes)
         VALUES ($1, $2, $3, $4)
         RETURNING id`,
@@ -32,6 +40,16 @@ func CriarServico(c *gin.Context) {
 		return
 	}
 
+	if servico.Slug, err = atribuirSlug(tx, "servicos", servico.ID, servicoReq.Nome); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar serviço"})
+		return
+	}
+
 	servico.Nome = servicoReq.Nome
 	servico.Preco = servicoReq.Preco
 	servico.Oferta = servicoReq.Oferta
@@ -47,9 +65,9 @@ func ListarServicos(c *gin.Context) {
 
 	var query string
 	if somenteOfertas {
-		query = `SELECT id, nome, preco, oferta, detalhes FROM servicos WHERE oferta = true ORDER BY nome`
+		query = `SELECT s.id, s.nome, s.slug, s.preco, s.oferta, s.detalhes FROM servicos s WHERE ` + sqlServicoEmOferta + ` ORDER BY s.nome`
 	} else {
-		query = `SELECT id, nome, preco, oferta, detalhes FROM servicos ORDER BY nome`
+		query = `SELECT id, nome, slug, preco, oferta, detalhes FROM servicos ORDER BY nome`
 	}
 
 	rows, err := db.Query(query)
@@ -62,26 +80,35 @@ func ListarServicos(c *gin.Context) {
 	var servicos []models.Servico
 	for rows.Next() {
 		var s models.Servico
-		if err := rows.Scan(&s.ID, &s.Nome, &s.Preco, &s.Oferta, &s.Detalhes); err != nil {
+		if err := rows.Scan(&s.ID, &s.Nome, &s.Slug, &s.Preco, &s.Oferta, &s.Detalhes); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler serviços"})
 			return
 		}
 		servicos = append(servicos, s)
 	}
 
+	if err := aplicarPromocoesServicos(db, servicos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções dos serviços"})
+		return
+	}
+
 	c.JSON(http.StatusOK, servicos)
 }
 
+// ObterServico aceita o ID ou o slug do serviço.
 func ObterServico(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
-	id := c.Param("id")
+	id, ok := identificadorDaRota(c, db, "servicos", "Serviço não encontrado")
+	if !ok {
+		return
+	}
 
 	var servico models.Servico
 	err := db.QueryRow(`
-        SELECT id, nome, preco, oferta, detalhes
+        SELECT id, nome, slug, preco, oferta, detalhes
         FROM servicos
         WHERE id = $1`, id).
-		Scan(&servico.ID, &servico.Nome, &servico.Preco, &servico.Oferta, &servico.Detalhes)
+		Scan(&servico.ID, &servico.Nome, &servico.Slug, &servico.Preco, &servico.Oferta, &servico.Detalhes)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -92,7 +119,13 @@ func ObterServico(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, servico)
+	lista := []models.Servico{servico}
+	if err := aplicarPromocoesServicos(db, lista); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções do serviço"})
+		return
+	}
+
+	c.JSON(http.StatusOK, lista[0])
 }
 
 func AtualizarServico(c *gin.Context) {
@@ -106,17 +139,45 @@ func AtualizarServico(c *gin.Context) {
 
 	db := c.MustGet("db").(*sql.DB)
 
-	_, err := db.Exec(`
+	servicoID, err := strconv.Atoi(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
         UPDATE servicos
         SET nome = $1, preco = $2, oferta = $3, detalhes = $4
         WHERE id = $5`,
-		servicoReq.Nome, servicoReq.Preco, servicoReq.Oferta, servicoReq.Detalhes, id)
+		servicoReq.Nome, servicoReq.Preco, servicoReq.Oferta, servicoReq.Detalhes, servicoID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar serviço"})
 		return
 	}
 
+	// Um nome novo gera outro slug; o anterior continua redirecionando.
+	if _, err := atribuirSlug(tx, "servicos", servicoID, servicoReq.Nome); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Serviço não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao gerar slug", "detalhes": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar serviço"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"mensagem": "Serviço atualizado com sucesso"})
 }
 
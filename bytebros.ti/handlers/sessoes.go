@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// intervaloAtividadeSessao limita a frequência com que a última atividade da
+// sessão é gravada, evitando um UPDATE a cada requisição.
+const intervaloAtividadeSessao = time.Minute
+
+// iniciarSessaoUsuario registra uma nova sessão para o cliente (dispositivo, IP)
+// e emite o token JWT vinculado a ela pelo claim "sid".
+func iniciarSessaoUsuario(c *gin.Context, db *sql.DB, usuarioID int, email string) (string, error) {
+	sid, err := valorAleatorio(24)
+	if err != nil {
+		return "", err
+	}
+
+	userAgent := c.Request.UserAgent()
+	agora := time.Now()
+	_, err = db.Exec(`
+		INSERT INTO sessoes (identificador, usuario_id, user_agent, dispositivo, ip, criado_em, ultima_atividade_em, expira_em)
+		VALUES ($1, $2, $3, $4, $5, $6, $6, $7)`,
+		sid, usuarioID, userAgent, descreverDispositivo(userAgent), c.ClientIP(), agora, agora.Add(validadeTokenJWT))
+	if err != nil {
+		return "", err
+	}
+
+	return generateJWTTokenSessao(usuarioID, email, "", sid)
+}
+
+// validarSessao confere se a sessão do token continua ativa e atualiza a última
+// atividade. Em caso de falha a resposta já é escrita e a requisição abortada.
+func validarSessao(c *gin.Context, sid string) bool {
+	db := c.MustGet("db").(*sql.DB)
+
+	var id int
+	var ultimaAtividade, expiraEm time.Time
+	var encerradaEm sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, ultima_atividade_em, expira_em, encerrada_em
+		FROM sessoes
+		WHERE identificador = $1`, sid).
+		Scan(&id, &ultimaAtividade, &expiraEm, &encerradaEm)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("ERRO BD: Falha ao buscar sessão: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao validar sessão"})
+			c.Abort()
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Sessão encerrada"})
+		c.Abort()
+		return false
+	}
+
+	agora := time.Now()
+	if encerradaEm.Valid || expiraEm.Before(agora) {
+		c.JSON(http.StatusUnauthorized, gin.H{"erro": "Sessão encerrada"})
+		c.Abort()
+		return false
+	}
+
+	if agora.Sub(ultimaAtividade) >= intervaloAtividadeSessao {
+		if _, err := db.Exec(`UPDATE sessoes SET ultima_atividade_em = $1, ip = $2 WHERE id = $3`, agora, c.ClientIP(), id); err != nil {
+			log.Printf("ERRO BD: Falha ao atualizar atividade da sessão %d: %v", id, err)
+		}
+	}
+
+	c.Set("sessao_id", id)
+	return true
+}
+
+// descreverDispositivo resume o User-Agent em algo legível como "Chrome no Windows".
+func descreverDispositivo(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "Desconhecido"
+	}
+
+	navegador := "Navegador desconhecido"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		navegador = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		navegador = "Opera"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		navegador = "Chrome"
+	case strings.Contains(ua, "firefox/") || strings.Contains(ua, "fxios/"):
+		navegador = "Firefox"
+	case strings.Contains(ua, "safari/"):
+		navegador = "Safari"
+	case strings.Contains(ua, "okhttp") || strings.Contains(ua, "dart") || strings.Contains(ua, "cfnetwork"):
+		navegador = "Aplicativo"
+	case strings.Contains(ua, "curl") || strings.Contains(ua, "postman") || strings.Contains(ua, "insomnia"):
+		navegador = "Cliente HTTP"
+	}
+
+	sistema := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		sistema = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ios"):
+		sistema = "iOS"
+	case strings.Contains(ua, "windows"):
+		sistema = "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		sistema = "macOS"
+	case strings.Contains(ua, "linux"):
+		sistema = "Linux"
+	}
+
+	if sistema == "" {
+		return navegador
+	}
+	return navegador + " no " + sistema
+}
+
+func ListarSessoes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+	sessaoAtual := c.GetInt("sessao_id")
+
+	rows, err := db.Query(`
+		SELECT id, dispositivo, user_agent, ip, criado_em, ultima_atividade_em, expira_em
+		FROM sessoes
+		WHERE usuario_id = $1 AND encerrada_em IS NULL AND expira_em > $2
+		ORDER BY ultima_atividade_em DESC`, usuarioID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar sessões", "detalhes": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	sessoes := make([]models.Sessao, 0)
+	for rows.Next() {
+		var s models.Sessao
+		var dispositivo, userAgent, ip sql.NullString
+		if err := rows.Scan(&s.ID, &dispositivo, &userAgent, &ip, &s.CriadoEm, &s.UltimaAtividadeEm, &s.ExpiraEm); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler sessões", "detalhes": err.Error()})
+			return
+		}
+		s.Dispositivo = dispositivo.String
+		s.UserAgent = userAgent.String
+		s.IP = ip.String
+		s.Atual = s.ID == sessaoAtual
+		sessoes = append(sessoes, s)
+	}
+
+	c.JSON(http.StatusOK, sessoes)
+}
+
+func EncerrarSessao(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE sessoes SET encerrada_em = $1
+		WHERE id = $2 AND usuario_id = $3 AND encerrada_em IS NULL`,
+		time.Now(), c.Param("id"), usuarioID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao encerrar sessão", "detalhes": err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Sessão não encontrada ou já encerrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Sessão encerrada com sucesso"})
+}
+
+// EncerrarOutrasSessoes desconecta todos os dispositivos exceto o atual.
+func EncerrarOutrasSessoes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	usuarioID, _, ok := obterUsuarioLogado(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"erro": "Disponível apenas para clientes"})
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE sessoes SET encerrada_em = $1
+		WHERE usuario_id = $2 AND id <> $3 AND encerrada_em IS NULL`,
+		time.Now(), usuarioID, c.GetInt("sessao_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao encerrar sessões", "detalhes": err.Error()})
+		return
+	}
+	encerradas, _ := result.RowsAffected()
+
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Outras sessões encerradas com sucesso", "encerradas": encerradas})
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var substituicoesSlug = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n", "å", "a", "ý", "y", "ÿ", "y", "ø", "o",
+	"ß", "ss", "æ", "ae", "œ", "oe",
+)
+
+// gerarSlug converte um texto em identificador de URL: minúsculo, sem acentos
+// e com hífens no lugar de espaços e pontuação ("Placas de Vídeo" → "placas-de-video").
+func gerarSlug(texto string) string {
+	texto = substituicoesSlug.Replace(strings.ToLower(strings.TrimSpace(texto)))
+
+	var b strings.Builder
+	hifen := false
+	for _, r := range texto {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			hifen = false
+			continue
+		}
+		if !hifen && b.Len() > 0 {
+			b.WriteByte('-')
+			hifen = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > 120 {
+		slug = strings.TrimSuffix(slug[:120], "-")
+	}
+	return slug
+}
+
+// gerarSlugUnico acrescenta "-2", "-3"... até o slug não existir na tabela,
+// ignorando o próprio registro (ignorarID) em atualizações.
+func gerarSlugUnico(tx *sql.Tx, tabela, base string, ignorarID int) (string, error) {
+	if base == "" {
+		base = "item"
+	}
+	slug := base
+	for i := 2; ; i++ {
+		var existe bool
+		err := tx.QueryRow(fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE slug = $1 AND id <> $2)`, tabela), slug, ignorarID).Scan(&existe)
+		if err != nil {
+			return "", err
+		}
+		if !existe {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// slugDerivaDe indica se slug é a base ou a base com um sufixo de desempate
+// que gerarSlugUnico produziria ("mouse-gamer-2"): um inteiro a partir de 2,
+// sem zeros à esquerda. "abc-20" deriva de "abc", mas não de "abc-2".
+func slugDerivaDe(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	sufixo, ok := strings.CutPrefix(slug, base+"-")
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(sufixo)
+	return err == nil && n >= 2 && strconv.Itoa(n) == sufixo
+}
+
+// baseSlug é o slug preferido para o texto. Slugs só de dígitos seriam
+// confundidos com o ID na rota.
+func baseSlug(texto string) string {
+	base := gerarSlug(texto)
+	if base == "" {
+		return "item"
+	}
+	if _, err := strconv.Atoi(base); err == nil {
+		return "item-" + base
+	}
+	return base
+}
+
+// atribuirSlug mantém o slug do registro coerente com o nome/título atual.
+// Quando muda, o slug anterior fica em slugs_antigos para redirecionar links
+// já publicados; um slug antigo reaproveitado por outro registro deixa de
+// redirecionar.
+func atribuirSlug(tx *sql.Tx, tabela string, id int, texto string) (string, error) {
+	var atual sql.NullString
+	if err := tx.QueryRow(fmt.Sprintf(`SELECT slug FROM %s WHERE id = $1`, tabela), id).Scan(&atual); err != nil {
+		return "", err
+	}
+
+	// Um sufixo de desempate só se mantém enquanto a base continua ocupada
+	// por outro registro; sem isso "ABC 20" renomeado para "ABC" ficaria com
+	// "abc-20" para sempre.
+	base := baseSlug(texto)
+	if atual.Valid && slugDerivaDe(atual.String, base) {
+		if atual.String == base {
+			return atual.String, nil
+		}
+		var ocupada bool
+		if err := tx.QueryRow(fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE slug = $1 AND id <> $2)`, tabela), base, id).Scan(&ocupada); err != nil {
+			return "", err
+		}
+		if ocupada {
+			return atual.String, nil
+		}
+	}
+
+	slug, err := gerarSlugUnico(tx, tabela, base, id)
+	if err != nil {
+		return "", err
+	}
+	if atual.Valid && atual.String != "" {
+		if _, err := tx.Exec(`
+			INSERT INTO slugs_antigos (tabela, slug, registro_id) VALUES ($1, $2, $3)
+			ON CONFLICT (tabela, slug) DO UPDATE SET registro_id = EXCLUDED.registro_id, criado_em = CURRENT_TIMESTAMP`,
+			tabela, atual.String, id); err != nil {
+			return "", err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM slugs_antigos WHERE tabela = $1 AND slug = $2`, tabela, slug); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET slug = $1 WHERE id = $2`, tabela), slug, id); err != nil {
+		return "", err
+	}
+	return slug, nil
+}
+
+// PreencherSlugsPendentes gera, com as mesmas regras de atribuirSlug, o slug
+// dos registros que ainda não têm (os anteriores à coluna). Roda na
+// inicialização, depois das migrações.
+func PreencherSlugsPendentes(db *sql.DB) error {
+	for _, alvo := range []struct{ tabela, coluna string }{
+		{"produtos", "nome"},
+		{"servicos", "nome"},
+		{"noticias", "titulo"},
+	} {
+		if err := preencherSlugsTabela(db, alvo.tabela, alvo.coluna); err != nil {
+			return fmt.Errorf("%s: %w", alvo.tabela, err)
+		}
+	}
+	return nil
+}
+
+func preencherSlugsTabela(db *sql.DB, tabela, coluna string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(fmt.Sprintf(`SELECT id, %s FROM %s WHERE slug IS NULL ORDER BY id FOR UPDATE`, coluna, tabela))
+	if err != nil {
+		return err
+	}
+	type pendente struct {
+		id    int
+		texto string
+	}
+	var pendentes []pendente
+	for rows.Next() {
+		var p pendente
+		if err := rows.Scan(&p.id, &p.texto); err != nil {
+			rows.Close()
+			return err
+		}
+		pendentes = append(pendentes, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range pendentes {
+		if _, err := atribuirSlug(tx, tabela, p.id, p.texto); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// identificadorDaRota resolve o parâmetro :id, que aceita o ID numérico ou o
+// slug. Um slug antigo responde 301 para a mesma rota com o slug atual; nos
+// demais casos sem registro responde 404. Com ok false a resposta já foi enviada.
+func identificadorDaRota(c *gin.Context, db *sql.DB, tabela, naoEncontrado string) (id int, ok bool) {
+	identificador := c.Param("id")
+	if id, err := strconv.Atoi(identificador); err == nil {
+		return id, true
+	}
+
+	err := db.QueryRow(fmt.Sprintf(`SELECT id FROM %s WHERE slug = $1`, tabela), identificador).Scan(&id)
+	if err == nil {
+		return id, true
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar registro", "detalhes": err.Error()})
+		return 0, false
+	}
+
+	var slugAtual string
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT t.slug FROM slugs_antigos s
+		JOIN %s t ON t.id = s.registro_id
+		WHERE s.tabela = $1 AND s.slug = $2`, tabela), tabela, identificador).Scan(&slugAtual)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"erro": naoEncontrado})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar registro", "detalhes": err.Error()})
+		return 0, false
+	}
+
+	destino := path.Join(path.Dir(c.Request.URL.Path), slugAtual)
+	if c.Request.URL.RawQuery != "" {
+		destino += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusMovedPermanently, destino)
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGerarSlug(t *testing.T) {
+	casos := []struct{ texto, slug string }{
+		{"Placas de Vídeo", "placas-de-video"},
+		{"  Placa de Vídeo RX 7600  ", "placa-de-video-rx-7600"},
+		{"AÇÃO & Reação!!", "acao-reacao"},
+		{"Mouse---Gamer", "mouse-gamer"},
+		{"--início--", "inicio"},
+		{"Straße Øre Æther Œuvre", "strasse-ore-aether-oeuvre"},
+		{"Core i7-14700K (14ª geração)", "core-i7-14700k-14-geracao"},
+		{"漢字", ""},
+		{"", ""},
+		{"2024", "2024"},
+	}
+	for _, caso := range casos {
+		if slug := gerarSlug(caso.texto); slug != caso.slug {
+			t.Errorf("gerarSlug(%q) = %q, esperado %q", caso.texto, slug, caso.slug)
+		}
+	}
+
+	longo := gerarSlug("abcdefghi " + strings.Repeat("x", 200))
+	if len(longo) > 120 || longo[len(longo)-1] == '-' {
+		t.Errorf("slug longo = %q (%d)", longo, len(longo))
+	}
+	if cortado := gerarSlug(strings.Repeat("a", 119) + " b"); cortado != strings.Repeat("a", 119) {
+		t.Errorf("corte no hífen = %q", cortado)
+	}
+}
+
+func TestBaseSlug(t *testing.T) {
+	casos := []struct{ texto, base string }{
+		{"Mouse Gamer", "mouse-gamer"},
+		{"2024", "item-2024"},
+		{"!!!", "item"},
+		{"", "item"},
+		{"2024 edição", "2024-edicao"},
+	}
+	for _, caso := range casos {
+		if base := baseSlug(caso.texto); base != caso.base {
+			t.Errorf("baseSlug(%q) = %q, esperado %q", caso.texto, base, caso.base)
+		}
+	}
+}
+
+func TestSlugDerivaDe(t *testing.T) {
+	casos := []struct {
+		slug, base string
+		deriva     bool
+	}{
+		{"abc", "abc", true},
+		{"abc-2", "abc", true},
+		{"abc-20", "abc", true},
+		{"abc-20", "abc-2", false},
+		{"abc-2", "abc-20", false},
+		{"abc-2-3", "abc-2", true},
+		{"abc-", "abc", false},
+		{"abc-1", "abc", false},
+		{"abc-0", "abc", false},
+		{"abc-02", "abc", false},
+		{"abc-x", "abc", false},
+		{"abcd-2", "abc", false},
+		{"abc", "abc-2", false},
+		{"item-2024-2", "item-2024", true},
+	}
+	for _, caso := range casos {
+		if deriva := slugDerivaDe(caso.slug, caso.base); deriva != caso.deriva {
+			t.Errorf("slugDerivaDe(%q, %q) = %v, esperado %v", caso.slug, caso.base, deriva, caso.deriva)
+		}
+	}
+}
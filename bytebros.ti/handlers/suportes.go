@@ -198,11 +198,20 @@ func AtualizarStatusSuporte(c *gin.Context) {
 
 	db := c.MustGet("db").(*sql.DB)
 
-	_, err := db.Exec(`
+	var err error
+	if funcionarioID, ok := obterFuncionarioLogado(c); ok {
+		_, err = db.Exec(`
+        UPDATE suporte
+        SET status = $1, atendido_por = $2, atendido_em = $3
+        WHERE id = $4`,
+			update.Status, funcionarioID, time.Now(), id)
+	} else {
+		_, err = db.Exec(`
         UPDATE suporte
         SET status = $1
         WHERE id = $2`,
-		update.Status, id)
+			update.Status, id)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao atualizar status do suporte", "detalhes": err.Error()})
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// tarefaPeriodica é executada em segundo plano enquanto o servidor estiver no
+// ar. Intervalo zero ou negativo desativa a tarefa.
+type tarefaPeriodica struct {
+	nome      string
+	intervalo func() time.Duration
+	executar  func(ctx context.Context, db *sql.DB) error
+}
+
+var tarefasPeriodicas = []tarefaPeriodica{
+	{"alertas de estoque mínimo", intervaloAlertasEstoque, executarAlertasEstoque},
+	{"início e fim de promoções", intervaloPromocoes, atualizarPromocoes},
+	{"avisos de volta ao estoque", intervaloAvisosDisponibilidade, enviarAvisosDisponibilidade},
+	{"produtos comprados juntos", intervaloProdutosRelacionados, atualizarProdutosRelacionados},
+}
+
+// IniciarTarefasPeriodicas dispara cada tarefa em sua própria goroutine; todas
+// param quando ctx é cancelado.
+func IniciarTarefasPeriodicas(ctx context.Context, db *sql.DB) {
+	for _, tarefa := range tarefasPeriodicas {
+		go executarPeriodicamente(ctx, db, tarefa)
+	}
+}
+
+func executarPeriodicamente(ctx context.Context, db *sql.DB, tarefa tarefaPeriodica) {
+	intervalo := tarefa.intervalo()
+	if intervalo <= 0 {
+		log.Printf("Tarefa periódica desativada: %s", tarefa.nome)
+		return
+	}
+	log.Printf("Tarefa periódica iniciada: %s (a cada %s)", tarefa.nome, intervalo)
+
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+	for {
+		if err := tarefa.executar(ctx, db); err != nil && ctx.Err() == nil {
+			log.Printf("ERRO: Tarefa periódica %s falhou: %v", tarefa.nome, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bytebros.ti/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const colunasVariante = `v.id, v.produto_id, v.sku, v.atributos, COALESCE(v.preco, p.preco), v.preco, v.quantidade, v.imagem, v.ativo, v.ordem, v.criado_em, v.atualizado_em`
+
+func scanVariante(row linhaSQL) (models.Variante, error) {
+	var v models.Variante
+	var atributos []byte
+	var precoProprio sql.NullFloat64
+	var imagem sql.NullString
+	err := row.Scan(&v.ID, &v.ProdutoID, &v.SKU, &atributos, &v.Preco, &precoProprio, &v.Quantidade, &imagem, &v.Ativo, &v.Ordem, &v.CriadoEm, &v.AtualizadoEm)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(atributos, &v.Atributos); err != nil {
+		return v, err
+	}
+	if precoProprio.Valid {
+		v.PrecoProprio = &precoProprio.Float64
+	}
+	v.Imagem = imagem.String
+	v.PrecoEfetivo = v.Preco
+	return v, nil
+}
+
+// carregarVariantesProdutos busca as variantes de vários produtos em uma única
+// consulta. Sem incluirInativas, apenas as variantes à venda são retornadas.
+func carregarVariantesProdutos(db *sql.DB, produtoIDs []int, incluirInativas bool) (map[int][]models.Variante, error) {
+	resultado := map[int][]models.Variante{}
+	if len(produtoIDs) == 0 {
+		return resultado, nil
+	}
+
+	query := `SELECT ` + colunasVariante + `
+		FROM produto_variantes v
+		JOIN produtos p ON p.id = v.produto_id
+		WHERE v.produto_id = ANY($1)`
+	if !incluirInativas {
+		query += ` AND v.ativo`
+	}
+	query += ` ORDER BY v.ordem, v.id`
+
+	rows, err := db.Query(query, pq.Array(produtoIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v, err := scanVariante(rows)
+		if err != nil {
+			return nil, err
+		}
+		resultado[v.ProdutoID] = append(resultado[v.ProdutoID], v)
+	}
+	return resultado, rows.Err()
+}
+
+func normalizarVariante(req *models.VarianteRequest) {
+	req.SKU = strings.ToUpper(strings.TrimSpace(req.SKU))
+	atributos := make(map[string]string, len(req.Atributos))
+	for chave, valor := range req.Atributos {
+		chave = strings.ToLower(strings.TrimSpace(chave))
+		if chave != "" {
+			atributos[chave] = strings.TrimSpace(valor)
+		}
+	}
+	req.Atributos = atributos
+}
+
+// salvarVariante insere (varianteID == 0) ou atualiza uma variante e responde
+// com os conflitos de SKU e de combinação de atributos.
+func salvarVariante(c *gin.Context, produtoID, varianteID int) {
+	db := c.MustGet("db").(*sql.DB)
+
+	var req models.VarianteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+	normalizarVariante(&req)
+	if req.SKU == "" || len(req.Atributos) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Informe o SKU e ao menos um atributo"})
+		return
+	}
+
+	var existe bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM produtos WHERE id = $1)`, produtoID).Scan(&existe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar produto", "detalhes": err.Error()})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Produto não encontrado"})
+		return
+	}
+
+	atributos, _ := json.Marshal(req.Atributos)
+
+	var duplicada bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM produto_variantes WHERE produto_id = $1 AND atributos = $2::jsonb AND id <> $3)`,
+		produtoID, string(atributos), varianteID).Scan(&duplicada)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao verificar variantes", "detalhes": err.Error()})
+		return
+	}
+	if duplicada {
+		c.JSON(http.StatusConflict, gin.H{"erro": "Já existe uma variante deste produto com os mesmos atributos"})
+		return
+	}
+
+	ativo := true
+	if req.Ativo != nil {
+		ativo = *req.Ativo
+	}
+	imagem := sql.NullString{String: req.Imagem, Valid: req.Imagem != ""}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao iniciar transação"})
+		return
+	}
+	defer tx.Rollback()
+
+	// A quantidade só vale como estoque inicial, lançado no livro de estoque.
+	// Na edição ela é ignorada: um formulário desatualizado não pode desfazer
+	// vendas, então o saldo só muda por POST /produtos/{id}/estoque.
+	var id int
+	if varianteID == 0 {
+		err = tx.QueryRow(`
+			INSERT INTO produto_variantes (produto_id, sku, atributos, preco, quantidade, imagem, ativo, ordem)
+			VALUES ($1, $2, $3, $4, 0, $5, $6, $7)
+			RETURNING id`,
+			produtoID, req.SKU, string(atributos), req.Preco, imagem, ativo, req.Ordem).Scan(&id)
+	} else {
+		err = tx.QueryRow(`
+			UPDATE produto_variantes
+			SET sku = $1, atributos = $2, preco = $3, imagem = $4, ativo = $5, ordem = $6, atualizado_em = CURRENT_TIMESTAMP
+			WHERE id = $7 AND produto_id = $8
+			RETURNING id`,
+			req.SKU, string(atributos), req.Preco, imagem, ativo, req.Ordem, varianteID, produtoID).Scan(&id)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Variante não encontrada"})
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"erro": "SKU já utilizado por outra variante"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar variante", "detalhes": err.Error()})
+		return
+	}
+
+	if varianteID == 0 && req.Quantidade > 0 {
+		movimento := novoMovimentoEstoque(c, produtoID, &id, "entrada", req.Quantidade, "Estoque inicial")
+		if err := registrarMovimentoEstoque(tx, movimento); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao registrar estoque da variante", "detalhes": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao salvar variante"})
+		return
+	}
+
+	variante, err := scanVariante(db.QueryRow(`SELECT `+colunasVariante+` FROM produto_variantes v JOIN produtos p ON p.id = v.produto_id WHERE v.id = $1`, id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao ler variante", "detalhes": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if varianteID == 0 {
+		status = http.StatusCreated
+	}
+	c.JSON(status, variante)
+}
+
+func ListarVariantes(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+
+	variantes, err := carregarVariantesProdutos(db, []int{produtoID}, c.Query("incluir_inativas") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar variantes", "detalhes": err.Error()})
+		return
+	}
+	lista := variantes[produtoID]
+	if lista == nil {
+		lista = []models.Variante{}
+	}
+
+	// O produto só é lido para aplicar as promoções vigentes aos preços.
+	produtos := []models.Produto{{ID: produtoID, Variantes: lista}}
+	if err := aplicarPromocoesProdutos(db, produtos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao buscar promoções do produto", "detalhes": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+func CriarVariante(c *gin.Context) {
+	produtoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+	salvarVariante(c, produtoID, 0)
+}
+
+func AtualizarVariante(c *gin.Context) {
+	produtoID, errProduto := strconv.Atoi(c.Param("id"))
+	varianteID, errVariante := strconv.Atoi(c.Param("variante_id"))
+	if errProduto != nil || errVariante != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID inválido"})
+		return
+	}
+	salvarVariante(c, produtoID, varianteID)
+}
+
+// DeletarVariante remove a variante; itens de pedidos antigos mantêm o SKU
+// gravado e perdem apenas a referência (variante_id fica nulo).
+func DeletarVariante(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	result, err := db.Exec(`DELETE FROM produto_variantes WHERE id = $1 AND produto_id = $2`, c.Param("variante_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Erro ao excluir variante", "detalhes": err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Variante não encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensagem": "Variante excluída com sucesso"})
+}
@@ -28,7 +28,22 @@ func main() {
 	if err := database.CreateTables(); err != nil {
 		log.Fatalf("Erro ao criar tabelas: %v", err)
 	}
+	if err := handlers.PreencherSlugsPendentes(database.DB); err != nil {
+		log.Fatalf("Erro ao preencher slugs: %v", err)
+	}
+
+	if executado, err := executarComando(os.Args[1:]); executado {
+		if err != nil {
+			log.Fatalf("Erro ao executar comando: %v", err)
+		}
+		return
+	}
+
+	if err := handlers.ValidarArmazenamento(); err != nil {
+		log.Fatalf("Erro na configuração de armazenamento: %v", err)
+	}
 
+	handlers.EncerrarImportacoesInterrompidas(database.DB)
 	handlers.InitializeGeminiClient()
 	log.SetOutput(os.Stderr)
 
@@ -36,31 +51,72 @@ func main() {
 
 	router.RedirectTrailingSlash = false
 
+	router.Use(handlers.RequestIDMiddleware())
+
 	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"https://bytebros.netlify.app/"} 
+	config.AllowOrigins = []string{"https://bytebros.netlify.app/"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Accept", "X-Requested-With"}
-	config.ExposeHeaders = []string{"Content-Length"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Accept", "X-Requested-With", "X-API-Key", "X-Request-ID"}
+	config.ExposeHeaders = []string{"Content-Length", "X-Request-ID"}
 	config.AllowCredentials = true
 	config.MaxAge = 12 * time.Hour
 	config.AddAllowHeaders("x-requested-with")
 	router.Use(cors.New(config))
 
+	if prefixo, diretorio, ok := handlers.DiretorioUploadsLocal(); ok {
+		router.Static(prefixo, diretorio)
+	}
+
 	router.Use(func(c *gin.Context) {
 		c.Set("db", database.DB)
 		c.Next()
 	})
 
+	router.GET("/sitemap.xml", handlers.Sitemap)
+	router.GET("/feeds/google-merchant.xml", handlers.FeedGoogleMerchant)
+
+	router.GET("/api/cep/:cep", handlers.ConsultarCEP)
+	router.GET("/api/busca", handlers.Buscar)
+	router.GET("/api/promocoes", handlers.ListarPromocoesVigentes)
+
 	router.GET("/api/noticias", handlers.ListarNoticias)
 	router.GET("/api/noticias/:id", handlers.ObterNoticia)
 
+	router.GET("/api/categorias", handlers.ListarCategorias)
+	router.GET("/api/categorias/:id", handlers.ObterCategoria)
+	router.GET("/api/categorias/:id/especificacoes", handlers.ListarEspecificacoesCategoria)
+
 	produtoRoutes := router.Group("/api/produtos")
 	{
-		produtoRoutes.POST("", handlers.CriarProduto)
 		produtoRoutes.GET("", handlers.ListarProdutos)
+		produtoRoutes.GET("/relacionados", handlers.ListarRelacionadosCarrinho)
+		produtoRoutes.GET("/comparar", handlers.CompararProdutos)
 		produtoRoutes.GET("/:id", handlers.ObterProduto)
-		produtoRoutes.PUT("/:id", handlers.AtualizarProduto)
-		produtoRoutes.DELETE("/:id", handlers.DeletarProduto)
+		produtoRoutes.GET("/:id/variantes", handlers.ListarVariantes)
+		produtoRoutes.GET("/:id/precos", handlers.ListarPrecosProduto)
+		produtoRoutes.GET("/:id/relacionados", handlers.ListarProdutosRelacionados)
+		produtoRoutes.GET("/:id/avaliacoes", handlers.ListarAvaliacoesProduto)
+		produtoRoutes.POST("/:id/avaliacoes", handlers.AuthMiddleware(), handlers.AvaliarProduto)
+		produtoRoutes.GET("/:id/imagens", handlers.ListarImagensProduto)
+
+		adminProdutos := produtoRoutes.Group("")
+		adminProdutos.Use(handlers.AuthMiddleware(), handlers.AdminMiddleware(), handlers.AuditoriaMiddleware())
+		{
+			adminProdutos.POST("", handlers.CriarProduto)
+			adminProdutos.PUT("/:id", handlers.AtualizarProduto)
+			adminProdutos.DELETE("/:id", handlers.DeletarProduto)
+			adminProdutos.PUT("/:id/categorias", handlers.DefinirCategoriasProduto)
+			adminProdutos.POST("/:id/variantes", handlers.CriarVariante)
+			adminProdutos.PUT("/:id/variantes/:variante_id", handlers.AtualizarVariante)
+			adminProdutos.DELETE("/:id/variantes/:variante_id", handlers.DeletarVariante)
+			adminProdutos.POST("/:id/imagens", handlers.EnviarImagensProduto)
+			adminProdutos.PUT("/:id/imagens", handlers.ReordenarImagensProduto)
+			adminProdutos.PUT("/:id/imagens/:imagem_id", handlers.AtualizarImagemProduto)
+			adminProdutos.DELETE("/:id/imagens/:imagem_id", handlers.DeletarImagemProduto)
+			adminProdutos.GET("/:id/estoque", handlers.ObterSituacaoEstoque)
+			adminProdutos.POST("/:id/estoque", handlers.RegistrarMovimentoEstoque)
+			adminProdutos.GET("/:id/estoque/movimentos", handlers.ListarMovimentosEstoque)
+		}
 	}
 
 	orcamentoRoutes := router.Group("/api/orcamentos")
@@ -73,7 +129,9 @@ func main() {
 		authRoutes.POST("/registrar", handlers.RegistrarUsuario)
 		authRoutes.POST("/login", handlers.LoginUsuario)
 
-		authRoutes.POST("/funcionarios/registrar", handlers.RegistrarFuncionario)
+		authRoutes.GET("/oidc/:provedor/iniciar", handlers.IniciarLoginOIDC)
+		authRoutes.GET("/oidc/:provedor/callback", handlers.CallbackOIDC)
+
 		authRoutes.POST("/funcionarios/login", handlers.LoginFuncionario)
 	}
 
@@ -81,6 +139,15 @@ func main() {
 	protected.Use(handlers.AuthMiddleware())
 	{
 		protected.GET("/perfil", handlers.ObterPerfil)
+		protected.PUT("/perfil", handlers.AtualizarPerfil)
+		protected.GET("/perfil/enderecos", handlers.ListarEnderecos)
+		protected.POST("/perfil/enderecos", handlers.CriarEndereco)
+		protected.PUT("/perfil/enderecos/:id", handlers.AtualizarEndereco)
+		protected.PUT("/perfil/enderecos/:id/padrao", handlers.DefinirEnderecoPadrao)
+		protected.DELETE("/perfil/enderecos/:id", handlers.DeletarEndereco)
+		protected.GET("/perfil/sessoes", handlers.ListarSessoes)
+		protected.DELETE("/perfil/sessoes", handlers.EncerrarOutrasSessoes)
+		protected.DELETE("/perfil/sessoes/:id", handlers.EncerrarSessao)
 		protected.POST("/pedidos", handlers.CriarPedido)
 		protected.GET("/meus-pedidos", handlers.ListarPedidosCliente)
 		protected.GET("/minhas-interacoes", handlers.ListarInteracoesCliente)
@@ -88,11 +155,40 @@ func main() {
 		protected.PUT("/usuarios/email", handlers.AtualizarEmailUsuario)
 		protected.PUT("/usuarios/telefone", handlers.AtualizarTelefoneUsuario)
 
+		protected.GET("/minhas-avaliacoes", handlers.ListarMinhasAvaliacoes)
+		protected.DELETE("/minhas-avaliacoes/:id", handlers.DeletarMinhaAvaliacao)
+		protected.GET("/lista-desejos", handlers.ListarListaDesejos)
+		protected.POST("/lista-desejos", handlers.AdicionarListaDesejos)
+		protected.DELETE("/lista-desejos/:produto_id", handlers.RemoverListaDesejos)
+
+		protected.GET("/notificacoes", handlers.ListarNotificacoes)
+		protected.PUT("/notificacoes/lidas", handlers.MarcarTodasNotificacoesLidas)
+		protected.PUT("/notificacoes/:id/lida", handlers.MarcarNotificacaoLida)
+
+		protected.GET("/minha-conta/exportar", handlers.ExportarDadosPessoais)
+		protected.POST("/minha-conta/exclusao", handlers.SolicitarExclusaoConta)
+		protected.GET("/minha-conta/solicitacoes", handlers.ListarMinhasSolicitacoesLGPD)
+		protected.GET("/minha-conta/consentimentos", handlers.ListarConsentimentos)
+		protected.POST("/minha-conta/consentimentos", handlers.RegistrarConsentimento)
+
 		adminRoutes := protected.Group("/admin")
-		adminRoutes.Use(handlers.AdminMiddleware())
+		adminRoutes.Use(handlers.AdminMiddleware(), handlers.AuditoriaMiddleware())
 		{
+			adminRoutes.GET("/administradores", handlers.ListarAdministradores)
+			adminRoutes.GET("/administradores/:id", handlers.ObterAdministrador)
+			adminRoutes.PUT("/administradores/:id", handlers.AtualizarAdministrador)
+			adminRoutes.PUT("/administradores/:id/senha", handlers.RedefinirSenhaAdministrador)
+			adminRoutes.PUT("/administradores/:id/status", handlers.AlterarStatusAdministrador)
+			adminRoutes.PUT("/administradores/:id/papel", handlers.AlterarPapelAdministrador)
 			adminRoutes.DELETE("/administradores/:id", handlers.DeletarAdministrador)
 			adminRoutes.GET("/funcionarios", handlers.ListarFuncionarios)
+			adminRoutes.POST("/funcionarios", handlers.CriarFuncionario)
+			adminRoutes.GET("/funcionarios/:id", handlers.ObterFuncionario)
+			adminRoutes.PUT("/funcionarios/:id", handlers.AtualizarFuncionario)
+			adminRoutes.PUT("/funcionarios/:id/cargo", handlers.AlterarCargoFuncionario)
+			adminRoutes.PUT("/funcionarios/:id/senha", handlers.RedefinirSenhaFuncionario)
+			adminRoutes.PUT("/funcionarios/:id/status", handlers.AlterarStatusFuncionario)
+			adminRoutes.GET("/funcionarios/:id/estatisticas", handlers.EstatisticasFuncionario)
 			adminRoutes.GET("/usuarios", handlers.ListarUsuarios)
 			adminRoutes.GET("/pedidos", handlers.ListarPedidosAdmin)
 			adminRoutes.PUT("/pedidos/:id/status", handlers.AtualizarStatusPedido)
@@ -106,16 +202,30 @@ func main() {
 			adminRoutes.GET("/orcamentos/:id", handlers.ObterOrcamento)
 			adminRoutes.PUT("/orcamentos/:id/status", handlers.AtualizarStatusOrcamento)
 			adminRoutes.DELETE("/orcamentos/:id", handlers.DeletarOrcamento)
+
+			adminRoutes.GET("/lgpd/solicitacoes", handlers.ListarSolicitacoesLGPD)
+			adminRoutes.PUT("/lgpd/solicitacoes/:id", handlers.AvaliarSolicitacaoLGPD)
 		}
 	}
 
+	equipeRoutes := router.Group("/api/equipe")
+	equipeRoutes.Use(handlers.AuthMiddleware(), handlers.FuncionarioMiddleware(), handlers.AuditoriaMiddleware())
+	{
+		equipeRoutes.GET("/suporte", handlers.ListarMensagensSuporte)
+		equipeRoutes.GET("/suporte/:id", handlers.ObterMensagemSuporte)
+		equipeRoutes.PUT("/suporte/:id/status", handlers.AtualizarStatusSuporte)
+		equipeRoutes.GET("/orcamentos", handlers.ListarOrcamentos)
+		equipeRoutes.GET("/orcamentos/:id", handlers.ObterOrcamento)
+		equipeRoutes.PUT("/orcamentos/:id/status", handlers.AtualizarStatusOrcamento)
+	}
+
 	servicosRoutes := router.Group("/api/servicos")
 	{
 		servicosRoutes.GET("/", handlers.ListarServicos)
 		servicosRoutes.GET("/:id", handlers.ObterServico)
 
 		adminServicos := servicosRoutes.Group("/")
-		adminServicos.Use(handlers.AuthMiddleware(), handlers.AdminMiddleware())
+		adminServicos.Use(handlers.AuthMiddleware(), handlers.AdminMiddleware(), handlers.AuditoriaMiddleware())
 		{
 			adminServicos.POST("/", handlers.CriarServico)
 			adminServicos.PUT("/:id", handlers.AtualizarServico)
@@ -128,7 +238,7 @@ func main() {
 		suporteRoutes.POST("", handlers.CriarMensagemSuporte)
 
 		adminSuporte := suporteRoutes.Group("")
-		adminSuporte.Use(handlers.AuthMiddleware(), handlers.AdminMiddleware())
+		adminSuporte.Use(handlers.AuthMiddleware(), handlers.AdminMiddleware(), handlers.AuditoriaMiddleware())
 		{
 			adminSuporte.GET("", handlers.ListarMensagensSuporte)
 			adminSuporte.GET("/:id", handlers.ObterMensagemSuporte)
@@ -138,10 +248,44 @@ func main() {
 	}
 
 	adminRoutes := router.Group("/api/admin")
-	adminRoutes.Use(handlers.AuthMiddleware(), handlers.AdminMiddleware())
+	adminRoutes.Use(handlers.AuthMiddleware(), handlers.AdminMiddleware(), handlers.AuditoriaMiddleware())
 	{
 		adminRoutes.POST("/administradores", handlers.CriarAdministrador)
 		adminRoutes.GET("/dashboard", handlers.AdminDashboard)
+		adminRoutes.GET("/auditoria", handlers.ListarAuditoria)
+		adminRoutes.GET("/estoque/divergencias", handlers.ListarDivergenciasEstoque)
+		adminRoutes.POST("/estoque/reconciliar", handlers.ReconciliarEstoque)
+		adminRoutes.GET("/estoque/reposicao", handlers.RelatorioReposicao)
+		adminRoutes.POST("/estoque/alertas/verificar", handlers.VerificarAlertasEstoque)
+
+		adminRoutes.GET("/promocoes", handlers.ListarPromocoes)
+		adminRoutes.GET("/promocoes/:id", handlers.ObterPromocao)
+		adminRoutes.POST("/promocoes", handlers.CriarPromocao)
+		adminRoutes.PUT("/promocoes/:id", handlers.AtualizarPromocao)
+		adminRoutes.POST("/promocoes/:id/encerrar", handlers.EncerrarPromocao)
+		adminRoutes.DELETE("/promocoes/:id", handlers.DeletarPromocao)
+
+		adminRoutes.GET("/avaliacoes", handlers.ListarAvaliacoes)
+		adminRoutes.PUT("/avaliacoes/:id/moderacao", handlers.ModerarAvaliacao)
+		adminRoutes.PUT("/avaliacoes/:id/resposta", handlers.ResponderAvaliacao)
+		adminRoutes.DELETE("/avaliacoes/:id", handlers.DeletarAvaliacao)
+
+		adminRoutes.POST("/produtos/importar", handlers.ImportarProdutos)
+		adminRoutes.GET("/produtos/importacoes", handlers.ListarImportacoes)
+		adminRoutes.GET("/produtos/importacoes/:id", handlers.ObterImportacao)
+		adminRoutes.GET("/produtos/exportar", handlers.ExportarProdutos)
+		adminRoutes.POST("/produtos/relacionados/atualizar", handlers.AtualizarProdutosRelacionados)
+
+		adminRoutes.POST("/categorias", handlers.CriarCategoria)
+		adminRoutes.PUT("/categorias/:id", handlers.AtualizarCategoria)
+		adminRoutes.DELETE("/categorias/:id", handlers.DeletarCategoria)
+		adminRoutes.POST("/categorias/:id/especificacoes", handlers.CriarEspecificacao)
+		adminRoutes.PUT("/categorias/:id/especificacoes/:especificacao_id", handlers.AtualizarEspecificacao)
+		adminRoutes.DELETE("/categorias/:id/especificacoes/:especificacao_id", handlers.DeletarEspecificacao)
+
+		adminRoutes.GET("/api-chaves", handlers.ListarChavesAPI)
+		adminRoutes.POST("/api-chaves", handlers.CriarChaveAPI)
+		adminRoutes.DELETE("/api-chaves/:id", handlers.RevogarChaveAPI)
 	}
 
 	router.POST("/api/admin/login", handlers.LoginAdmin)
@@ -153,6 +297,9 @@ func main() {
 		Handler: router,
 	}
 
+	tarefasCtx, pararTarefas := context.WithCancel(context.Background())
+	handlers.IniciarTarefasPeriodicas(tarefasCtx, database.DB)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
@@ -165,6 +312,7 @@ func main() {
 
 	<-quit
 	log.Println("Desligando servidor...")
+	pararTarefas()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -6,7 +6,7 @@ type Administrador struct {
 	ID         int       `json:"id"`
 	Nome       string    `json:"nome" binding:"required,min=3"`
 	Email      string    `json:"email" binding:"required,email"`
-	Senha      string    `json:"senha" binding:"required,min=8"`
+	Senha      string    `json:"senha" binding:"required"`
 	IsAdmin    bool      `json:"is_admin"`
 	CriadoEm   time.Time `json:"criado_em"`
 	Atualizado time.Time `json:"atualizado_em"`
@@ -14,7 +14,7 @@ type Administrador struct {
 
 type AdminLogin struct {
 	Email string `json:"email" binding:"required,email"`
-	Senha string `json:"senha" binding:"required,min=8"`
+	Senha string `json:"senha" binding:"required"`
 }
 
 type AdminResponse struct {
@@ -24,3 +24,30 @@ type AdminResponse struct {
 	IsAdmin bool   `json:"is_admin"`
 	Token   string `json:"token"`
 }
+
+type AdministradorResumo struct {
+	ID           int       `json:"id"`
+	Nome         string    `json:"nome"`
+	Email        string    `json:"email"`
+	IsAdmin      bool      `json:"is_admin"`
+	Ativo        bool      `json:"ativo"`
+	CriadoEm     time.Time `json:"criado_em"`
+	AtualizadoEm time.Time `json:"atualizado_em"`
+}
+
+type AtualizarAdministradorRequest struct {
+	Nome  string `json:"nome" binding:"required,min=3"`
+	Email string `json:"email" binding:"required,email"`
+}
+
+type RedefinirSenhaRequest struct {
+	NovaSenha string `json:"nova_senha" binding:"required"`
+}
+
+type AlterarStatusAdministradorRequest struct {
+	Ativo *bool `json:"ativo" binding:"required"`
+}
+
+type AlterarPapelAdministradorRequest struct {
+	IsAdmin *bool `json:"is_admin" binding:"required"`
+}
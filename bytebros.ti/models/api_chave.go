@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type ChaveAPI struct {
+	ID          int        `json:"id"`
+	Nome        string     `json:"nome"`
+	Prefixo     string     `json:"prefixo"`
+	Escopos     []string   `json:"escopos"`
+	CriadoPor   *int       `json:"criado_por,omitempty"`
+	CriadoEm    time.Time  `json:"criado_em"`
+	ExpiraEm    *time.Time `json:"expira_em,omitempty"`
+	UltimoUsoEm *time.Time `json:"ultimo_uso_em,omitempty"`
+	UltimoUsoIP string     `json:"ultimo_uso_ip,omitempty"`
+	RevogadaEm  *time.Time `json:"revogada_em,omitempty"`
+}
+
+type CriarChaveAPIRequest struct {
+	Nome     string     `json:"nome" binding:"required,min=3"`
+	Escopos  []string   `json:"escopos" binding:"required,min=1"`
+	ExpiraEm *time.Time `json:"expira_em"`
+}
+
+type ChaveAPICriadaResponse struct {
+	ChaveAPI
+	Chave string `json:"chave"`
+}
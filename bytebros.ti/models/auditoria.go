@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RegistroAuditoria struct {
+	ID         int             `json:"id"`
+	AtorTipo   string          `json:"ator_tipo"`
+	AtorID     *int            `json:"ator_id,omitempty"`
+	AtorEmail  string          `json:"ator_email,omitempty"`
+	Papel      string          `json:"papel,omitempty"`
+	Metodo     string          `json:"metodo"`
+	Rota       string          `json:"rota"`
+	Caminho    string          `json:"caminho"`
+	Entidade   string          `json:"entidade"`
+	EntidadeID string          `json:"entidade_id,omitempty"`
+	Status     int             `json:"status"`
+	Antes      json.RawMessage `json:"antes,omitempty"`
+	Depois     json.RawMessage `json:"depois,omitempty"`
+	Diferencas json.RawMessage `json:"diferencas,omitempty"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	CriadoEm   time.Time       `json:"criado_em"`
+}
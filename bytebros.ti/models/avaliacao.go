@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Avaliacao é a nota (1 a 5) e o comentário de um cliente que recebeu o
+// produto. Só aparece na loja depois de aprovada pela moderação.
+type Avaliacao struct {
+	ID             int        `json:"id"`
+	ProdutoID      int        `json:"produto_id"`
+	ProdutoNome    string     `json:"produto_nome,omitempty"`
+	UsuarioID      int        `json:"usuario_id,omitempty"`
+	Autor          string     `json:"autor"`
+	Nota           int        `json:"nota"`
+	Comentario     string     `json:"comentario"`
+	Status         string     `json:"status,omitempty"`
+	MotivoRejeicao string     `json:"motivo_rejeicao,omitempty"`
+	Resposta       *string    `json:"resposta"`
+	RespondidoEm   *time.Time `json:"respondido_em"`
+	CriadoEm       time.Time  `json:"criado_em"`
+	AtualizadoEm   time.Time  `json:"atualizado_em"`
+}
+
+type AvaliacaoRequest struct {
+	Nota       int    `json:"nota" binding:"required,min=1,max=5"`
+	Comentario string `json:"comentario" binding:"required,max=2000"`
+}
+
+type ModerarAvaliacaoRequest struct {
+	Status string `json:"status" binding:"required,oneof=aprovada rejeitada"`
+	Motivo string `json:"motivo" binding:"max=500"`
+}
+
+type ResponderAvaliacaoRequest struct {
+	Resposta string `json:"resposta" binding:"required,max=2000"`
+}
+
+// ResumoAvaliacoes agrega as avaliações aprovadas de um produto. Distribuicao
+// conta as avaliações por nota ("1" a "5").
+type ResumoAvaliacoes struct {
+	Media        *float64       `json:"media"`
+	Total        int            `json:"total"`
+	Distribuicao map[string]int `json:"distribuicao"`
+}
@@ -0,0 +1,12 @@
+package models
+
+type ResultadoBusca struct {
+	Tipo       string   `json:"tipo"`
+	ID         int      `json:"id"`
+	Titulo     string   `json:"titulo"`
+	Trecho     string   `json:"trecho"`
+	Preco      *float64 `json:"preco,omitempty"`
+	Oferta     bool     `json:"oferta,omitempty"`
+	Imagem     string   `json:"imagem,omitempty"`
+	Relevancia float64  `json:"relevancia"`
+}
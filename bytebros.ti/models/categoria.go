@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type Categoria struct {
+	ID             int               `json:"id"`
+	Nome           string            `json:"nome"`
+	Slug           string            `json:"slug"`
+	Descricao      string            `json:"descricao,omitempty"`
+	CategoriaPaiID *int              `json:"categoria_pai_id"`
+	Ordem          int               `json:"ordem"`
+	TotalProdutos  int               `json:"total_produtos"`
+	CriadoEm       time.Time         `json:"criado_em"`
+	AtualizadoEm   time.Time         `json:"atualizado_em"`
+	Subcategorias  []Categoria       `json:"subcategorias,omitempty"`
+	Caminho        []CategoriaResumo `json:"caminho,omitempty"`
+}
+
+type CategoriaRequest struct {
+	Nome           string `json:"nome" binding:"required,max=100"`
+	Slug           string `json:"slug" binding:"max=120"`
+	Descricao      string `json:"descricao"`
+	CategoriaPaiID *int   `json:"categoria_pai_id"`
+	Ordem          int    `json:"ordem"`
+}
+
+type CategoriaResumo struct {
+	ID   int    `json:"id"`
+	Nome string `json:"nome"`
+	Slug string `json:"slug"`
+}
+
+type DefinirCategoriasProdutoRequest struct {
+	CategoriaIDs []int `json:"categoria_ids" binding:"required"`
+}
@@ -0,0 +1,12 @@
+package models
+
+type EnderecoCEP struct {
+	CEP         string `json:"cep"`
+	Logradouro  string `json:"logradouro"`
+	Complemento string `json:"complemento"`
+	Bairro      string `json:"bairro"`
+	Cidade      string `json:"cidade"`
+	Estado      string `json:"estado"`
+	IBGE        string `json:"ibge,omitempty"`
+	Fonte       string `json:"fonte"`
+}
@@ -0,0 +1,62 @@
+package models
+
+// Especificacao é um atributo técnico tipado definido em uma categoria
+// (soquete, capacidade, interface...) e herdado pelas subcategorias.
+type Especificacao struct {
+	ID          int      `json:"id"`
+	CategoriaID int      `json:"categoria_id"`
+	Chave       string   `json:"chave"`
+	Nome        string   `json:"nome"`
+	Tipo        string   `json:"tipo"`
+	Unidade     string   `json:"unidade,omitempty"`
+	Opcoes      []string `json:"opcoes,omitempty"`
+	Obrigatoria bool     `json:"obrigatoria"`
+	Ordem       int      `json:"ordem"`
+}
+
+type EspecificacaoRequest struct {
+	Chave       string   `json:"chave" binding:"required,max=50"`
+	Nome        string   `json:"nome" binding:"required,max=100"`
+	Tipo        string   `json:"tipo" binding:"required,oneof=texto numero booleano opcao"`
+	Unidade     string   `json:"unidade" binding:"max=20"`
+	Opcoes      []string `json:"opcoes"`
+	Obrigatoria bool     `json:"obrigatoria"`
+	Ordem       int      `json:"ordem"`
+}
+
+// EspecificacaoProduto é o valor de uma especificação em um produto,
+// acompanhado da definição para exibição.
+type EspecificacaoProduto struct {
+	Chave   string      `json:"chave"`
+	Nome    string      `json:"nome"`
+	Tipo    string      `json:"tipo"`
+	Unidade string      `json:"unidade,omitempty"`
+	Valor   interface{} `json:"valor"`
+}
+
+type ProdutoComparado struct {
+	ID             int      `json:"id"`
+	Nome           string   `json:"nome"`
+	Slug           string   `json:"slug"`
+	Imagem         *string  `json:"imagem"`
+	Preco          float64  `json:"preco"`
+	PrecoEfetivo   float64  `json:"preco_efetivo"`
+	EmEstoque      bool     `json:"em_estoque"`
+	AvaliacaoMedia *float64 `json:"avaliacao_media"`
+}
+
+// LinhaComparacao traz o valor de uma especificação em cada produto, na mesma
+// ordem de ComparacaoProdutos.Produtos (null quando o produto não informa).
+type LinhaComparacao struct {
+	Chave     string        `json:"chave"`
+	Nome      string        `json:"nome"`
+	Tipo      string        `json:"tipo"`
+	Unidade   string        `json:"unidade,omitempty"`
+	Valores   []interface{} `json:"valores"`
+	Diferente bool          `json:"diferente"`
+}
+
+type ComparacaoProdutos struct {
+	Produtos       []ProdutoComparado `json:"produtos"`
+	Especificacoes []LinhaComparacao  `json:"especificacoes"`
+}
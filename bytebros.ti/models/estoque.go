@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+type MovimentoEstoque struct {
+	ID         int  `json:"id"`
+	ProdutoID  int  `json:"produto_id"`
+	VarianteID *int `json:"variante_id"`
+	// SKU do produto ou da variante no momento do movimento; continua no
+	// histórico depois que o item é excluído.
+	SKU  string `json:"sku,omitempty"`
+	Tipo string `json:"tipo"`
+	// Quantidade é a variação com sinal: positiva para entradas, negativa para saídas.
+	Quantidade int       `json:"quantidade"`
+	SaldoApos  int       `json:"saldo_apos"`
+	Motivo     string    `json:"motivo,omitempty"`
+	PedidoID   *int      `json:"pedido_id,omitempty"`
+	AtorTipo   string    `json:"ator_tipo"`
+	AtorID     *int      `json:"ator_id,omitempty"`
+	AtorEmail  string    `json:"ator_email,omitempty"`
+	CriadoEm   time.Time `json:"criado_em"`
+}
+
+type MovimentoEstoqueRequest struct {
+	Tipo       string `json:"tipo" binding:"required,oneof=entrada devolucao ajuste perda"`
+	Quantidade int    `json:"quantidade" binding:"required"`
+	VarianteID *int   `json:"variante_id"`
+	Motivo     string `json:"motivo" binding:"max=500"`
+}
+
+// SituacaoEstoque compara o saldo gravado no produto (ou na variante) com a
+// soma dos movimentos do livro de estoque.
+type SituacaoEstoque struct {
+	ProdutoID   int    `json:"produto_id"`
+	Nome        string `json:"nome"`
+	VarianteID  *int   `json:"variante_id"`
+	SKU         string `json:"sku,omitempty"`
+	Quantidade  int    `json:"quantidade"`
+	SaldoLivro  int    `json:"saldo_livro"`
+	Divergencia int    `json:"divergencia"`
+}
+
+// SugestaoReposicao projeta quanto comprar de um produto a partir da
+// velocidade de vendas no período analisado.
+type SugestaoReposicao struct {
+	ProdutoID        int      `json:"produto_id"`
+	Nome             string   `json:"nome"`
+	Estoque          int      `json:"estoque"`
+	EstoqueMinimo    int      `json:"estoque_minimo"`
+	AbaixoMinimo     bool     `json:"abaixo_minimo"`
+	VendidosPeriodo  int      `json:"vendidos_periodo"`
+	VelocidadeDiaria float64  `json:"velocidade_diaria"`
+	DiasRestantes    *float64 `json:"dias_restantes"`
+	Sugestao         int      `json:"sugestao_reposicao"`
+}
@@ -1,11 +1,13 @@
 package models
 
+import "time"
+
 type Funcionario struct {
 	ID    int    `json:"id"`
 	Nome  string `json:"nome" binding:"required,min=3"`
 	Cargo string `json:"cargo" binding:"required"`
 	Email string `json:"email" binding:"required,email"`
-	Senha string `json:"senha" binding:"required,min=6"`
+	Senha string `json:"senha" binding:"required"`
 }
 
 type FuncionarioRequest struct {
@@ -17,7 +19,7 @@ type FuncionarioRequest struct {
 
 type FuncionarioLogin struct {
 	Email string `json:"email" binding:"required,email"`
-	Senha string `json:"senha" binding:"required,min=6"`
+	Senha string `json:"senha" binding:"required"`
 }
 
 type FuncionarioResponse struct {
@@ -27,3 +29,38 @@ type FuncionarioResponse struct {
 	Email string `json:"email"`
 	Token string `json:"token,omitempty"`
 }
+
+type FuncionarioResumo struct {
+	ID           int       `json:"id"`
+	Nome         string    `json:"nome"`
+	Cargo        string    `json:"cargo"`
+	Email        string    `json:"email"`
+	Ativo        bool      `json:"ativo"`
+	CriadoEm     time.Time `json:"criado_em"`
+	AtualizadoEm time.Time `json:"atualizado_em"`
+}
+
+type AtualizarFuncionarioRequest struct {
+	Nome  string `json:"nome" binding:"required,min=3"`
+	Email string `json:"email" binding:"required,email"`
+}
+
+type AlterarCargoFuncionarioRequest struct {
+	Cargo string `json:"cargo" binding:"required"`
+}
+
+type AlterarStatusFuncionarioRequest struct {
+	Ativo *bool `json:"ativo" binding:"required"`
+}
+
+type EstatisticasFuncionario struct {
+	FuncionarioID         int        `json:"funcionario_id"`
+	Periodo               string     `json:"periodo"`
+	TicketsAtendidos      int        `json:"tickets_atendidos"`
+	TicketsEmAndamento    int        `json:"tickets_em_andamento"`
+	TicketsResolvidos     int        `json:"tickets_resolvidos"`
+	OrcamentosProcessados int        `json:"orcamentos_processados"`
+	OrcamentosAprovados   int        `json:"orcamentos_aprovados"`
+	OrcamentosRejeitados  int        `json:"orcamentos_rejeitados"`
+	UltimaAtividade       *time.Time `json:"ultima_atividade,omitempty"`
+}
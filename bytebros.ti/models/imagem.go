@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type ProdutoImagem struct {
+	ID               int               `json:"id"`
+	ProdutoID        int               `json:"produto_id"`
+	URL              string            `json:"url"`
+	Miniaturas       map[string]string `json:"miniaturas"`
+	TextoAlternativo string            `json:"texto_alternativo,omitempty"`
+	TipoConteudo     string            `json:"tipo_conteudo"`
+	Largura          int               `json:"largura"`
+	Altura           int               `json:"altura"`
+	TamanhoBytes     int               `json:"tamanho_bytes"`
+	Ordem            int               `json:"ordem"`
+	CriadoEm         time.Time         `json:"criado_em"`
+	// Arquivos guarda as chaves no armazenamento (original e miniaturas).
+	Arquivos []string `json:"-"`
+}
+
+type ReordenarImagensRequest struct {
+	IDs []int `json:"ids" binding:"required,min=1"`
+}
+
+type AtualizarImagemRequest struct {
+	TextoAlternativo string `json:"texto_alternativo" binding:"max=255"`
+}
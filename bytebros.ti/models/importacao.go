@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Importacao acompanha o processamento assíncrono de uma planilha de produtos.
+type Importacao struct {
+	ID          int               `json:"id"`
+	ArquivoNome string            `json:"arquivo_nome"`
+	Formato     string            `json:"formato"`
+	Simulacao   bool              `json:"simulacao"`
+	Status      string            `json:"status"`
+	Mapeamento  map[string]string `json:"mapeamento"`
+	TotalLinhas int               `json:"total_linhas"`
+	Processadas int               `json:"processadas"`
+	Criados     int               `json:"criados"`
+	Atualizados int               `json:"atualizados"`
+	ComErro     int               `json:"com_erro"`
+	Erros       []ErroImportacao  `json:"erros,omitempty"`
+	Mensagem    string            `json:"mensagem,omitempty"`
+	AtorTipo    string            `json:"ator_tipo"`
+	AtorID      *int              `json:"ator_id,omitempty"`
+	AtorEmail   string            `json:"ator_email,omitempty"`
+	CriadoEm    time.Time         `json:"criado_em"`
+	IniciadoEm  *time.Time        `json:"iniciado_em"`
+	ConcluidoEm *time.Time        `json:"concluido_em"`
+}
+
+// ErroImportacao aponta a linha da planilha (contando o cabeçalho como 1).
+type ErroImportacao struct {
+	Linha    int    `json:"linha"`
+	Campo    string `json:"campo,omitempty"`
+	Mensagem string `json:"mensagem"`
+}
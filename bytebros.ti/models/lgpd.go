@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+type DadosTitular struct {
+	ID            int        `json:"id"`
+	Nome          string     `json:"nome_completo"`
+	Email         string     `json:"email"`
+	Telefone      string     `json:"telefone"`
+	CPF           string     `json:"cpf,omitempty"`
+	CriadoEm      time.Time  `json:"criado_em"`
+	AtualizadoEm  time.Time  `json:"atualizado_em"`
+	AnonimizadoEm *time.Time `json:"anonimizado_em,omitempty"`
+}
+
+type Consentimento struct {
+	ID         int       `json:"id"`
+	UsuarioID  int       `json:"usuario_id"`
+	Email      string    `json:"email"`
+	Finalidade string    `json:"finalidade"`
+	Concedido  bool      `json:"concedido"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CriadoEm   time.Time `json:"criado_em"`
+}
+
+type RegistrarConsentimentoRequest struct {
+	Finalidade string `json:"finalidade" binding:"required,oneof=termos_uso politica_privacidade marketing_email marketing_whatsapp compartilhamento_parceiros"`
+	Concedido  *bool  `json:"concedido" binding:"required"`
+}
+
+type SolicitacaoLGPD struct {
+	ID              int        `json:"id"`
+	UsuarioID       int        `json:"usuario_id"`
+	Email           string     `json:"email"`
+	Tipo            string     `json:"tipo"`
+	Status          string     `json:"status"`
+	Motivo          string     `json:"motivo,omitempty"`
+	ObservacaoAdmin string     `json:"observacao_admin,omitempty"`
+	ProcessadoPor   *int       `json:"processado_por,omitempty"`
+	ProcessadoEm    *time.Time `json:"processado_em,omitempty"`
+	CriadoEm        time.Time  `json:"criado_em"`
+	AtualizadoEm    time.Time  `json:"atualizado_em"`
+}
+
+type SolicitarExclusaoRequest struct {
+	Senha  string `json:"senha" binding:"required"`
+	Motivo string `json:"motivo"`
+}
+
+type AvaliarSolicitacaoLGPDRequest struct {
+	Status     string `json:"status" binding:"required,oneof=aprovada rejeitada"`
+	Observacao string `json:"observacao"`
+}
+
+type IdentidadeExterna struct {
+	Provedor      string     `json:"provedor"`
+	Email         string     `json:"email"`
+	CriadoEm      time.Time  `json:"criado_em"`
+	UltimoLoginEm *time.Time `json:"ultimo_login_em,omitempty"`
+}
+
+type ExportacaoDadosPessoais struct {
+	GeradoEm       time.Time           `json:"gerado_em"`
+	Titular        DadosTitular        `json:"titular"`
+	Pedidos        []Pedido            `json:"pedidos"`
+	Suporte        []Suporte           `json:"suporte"`
+	Orcamentos     []Orcamento         `json:"orcamentos"`
+	Consentimentos []Consentimento     `json:"consentimentos"`
+	Solicitacoes   []SolicitacaoLGPD   `json:"solicitacoes"`
+	Enderecos      []Endereco          `json:"enderecos"`
+	Identidades    []IdentidadeExterna `json:"identidades_externas"`
+	Sessoes        []Sessao            `json:"sessoes"`
+	Avaliacoes     []Avaliacao         `json:"avaliacoes"`
+	ListaDesejos   []ItemListaDesejos  `json:"lista_desejos"`
+}
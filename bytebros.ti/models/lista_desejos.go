@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ItemListaDesejos é um produto salvo pelo cliente. Com AvisarDisponibilidade,
+// o cliente é avisado (email e notificação) quando o produto volta ao estoque.
+type ItemListaDesejos struct {
+	ProdutoID             int        `json:"produto_id"`
+	Nome                  string     `json:"nome"`
+	Imagem                string     `json:"imagem,omitempty"`
+	Preco                 float64    `json:"preco"`
+	PrecoEfetivo          float64    `json:"preco_efetivo"`
+	Oferta                bool       `json:"oferta"`
+	EmEstoque             bool       `json:"em_estoque"`
+	AvisarDisponibilidade bool       `json:"avisar_disponibilidade"`
+	AvisadoEm             *time.Time `json:"avisado_em"`
+	CriadoEm              time.Time  `json:"criado_em"`
+}
+
+type ItemListaDesejosRequest struct {
+	ProdutoID int `json:"produto_id" binding:"required,min=1"`
+	// Nulo mantém a preferência atual (falso para itens novos).
+	AvisarDisponibilidade *bool `json:"avisar_disponibilidade"`
+}
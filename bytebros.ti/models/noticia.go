@@ -5,6 +5,7 @@ import "time"
 type Noticia struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Titulo    string    `json:"titulo"`
+	Slug      string    `json:"slug"`
 	Subtitulo string    `json:"subtitulo"`
 	Conteudo  string    `json:"conteudo"`
 	Autor     string    `json:"autor"`
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Notificacao struct {
+	ID       int             `json:"id"`
+	Tipo     string          `json:"tipo"`
+	Titulo   string          `json:"titulo"`
+	Mensagem string          `json:"mensagem"`
+	Dados    json.RawMessage `json:"dados,omitempty"`
+	LidaEm   *time.Time      `json:"lida_em"`
+	CriadoEm time.Time       `json:"criado_em"`
+}
@@ -24,11 +24,15 @@ type PedidoItem struct {
 	NomeProduto   string  `json:"nome_produto"`
 	Quantidade    int     `json:"quantidade"`
 	ValorUnitario float64 `json:"valor_unitario"`
+	VarianteID    *int    `json:"variante_id,omitempty"`
+	SKU           string  `json:"sku,omitempty"`
 }
 
 type CriarPedidoRequest struct {
 	Itens           []PedidoItemRequest `json:"itens" binding:"required"`
-	EnderecoEntrega string              `json:"endereco_entrega" binding:"required"`
+	EnderecoID      *int                `json:"endereco_id"`
+	Endereco        *EnderecoRequest    `json:"endereco"`
+	EnderecoEntrega string              `json:"endereco_entrega"`
 	TipoFrete       string              `json:"tipo_frete" binding:"required"`
 	ValorFrete      float64             `json:"valor_frete" binding:"required,min=0"`
 	ValorTotal      float64             `json:"valor_total" binding:"required,min=0"`
@@ -41,4 +45,5 @@ type PedidoItemRequest struct {
 	NomeProduto   string  `json:"nome_produto" binding:"required"`
 	Quantidade    int     `json:"quantidade" binding:"required,min=1"`
 	ValorUnitario float64 `json:"valor_unitario" binding:"required,min=0"`
+	VarianteID    *int    `json:"variante_id"`
 }
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+type PerfilUsuario struct {
+	ID           int        `json:"id"`
+	Nome         string     `json:"nome_completo"`
+	Email        string     `json:"email"`
+	Telefone     string     `json:"telefone"`
+	CPF          string     `json:"cpf,omitempty"`
+	CriadoEm     time.Time  `json:"criado_em"`
+	AtualizadoEm time.Time  `json:"atualizado_em"`
+	Enderecos    []Endereco `json:"enderecos"`
+	Tipo         string     `json:"tipo"`
+}
+
+type AtualizarPerfilRequest struct {
+	Nome *string `json:"nome_completo" binding:"omitempty,min=2,max=100"`
+	CPF  *string `json:"cpf"`
+}
+
+type Endereco struct {
+	ID           int       `json:"id"`
+	UsuarioID    int       `json:"usuario_id"`
+	Apelido      string    `json:"apelido"`
+	Destinatario string    `json:"destinatario"`
+	CEP          string    `json:"cep"`
+	Logradouro   string    `json:"logradouro"`
+	Numero       string    `json:"numero"`
+	Complemento  string    `json:"complemento"`
+	Bairro       string    `json:"bairro"`
+	Cidade       string    `json:"cidade"`
+	Estado       string    `json:"estado"`
+	Padrao       bool      `json:"padrao"`
+	CriadoEm     time.Time `json:"criado_em"`
+	AtualizadoEm time.Time `json:"atualizado_em"`
+}
+
+type EnderecoRequest struct {
+	Apelido      string `json:"apelido" binding:"max=50"`
+	Destinatario string `json:"destinatario" binding:"max=100"`
+	CEP          string `json:"cep" binding:"required"`
+	Logradouro   string `json:"logradouro" binding:"max=200"`
+	Numero       string `json:"numero" binding:"required,max=20"`
+	Complemento  string `json:"complemento" binding:"max=100"`
+	Bairro       string `json:"bairro" binding:"max=100"`
+	Cidade       string `json:"cidade" binding:"max=100"`
+	Estado       string `json:"estado" binding:"omitempty,len=2"`
+	Padrao       bool   `json:"padrao"`
+}
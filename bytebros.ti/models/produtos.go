@@ -1,22 +1,119 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type Produto struct {
-	ID         int            `json:"id"`
-	Nome       string         `json:"name"`
-	Quantidade int            `json:"quantity"`
-	Preco      float64        `json:"value"`
-	Oferta     bool           `json:"oferta"`
-	Detalhes   sql.NullString `json:"details"`
-	Imagem     sql.NullString `json:"image"`
+	ID         int     `json:"id"`
+	Nome       string  `json:"name"`
+	Slug       string  `json:"slug"`
+	SKU        string  `json:"sku,omitempty"`
+	Quantidade int     `json:"quantity"`
+	Preco      float64 `json:"value"`
+	// PrecoEfetivo é o preço com a melhor promoção vigente (ou o próprio preço).
+	PrecoEfetivo float64           `json:"preco_efetivo"`
+	Promocao     *PromocaoAplicada `json:"promocao"`
+	// Média e total das avaliações aprovadas; a média é nula sem avaliações.
+	AvaliacaoMedia  *float64 `json:"avaliacao_media"`
+	TotalAvaliacoes int      `json:"total_avaliacoes"`
+	// MenorPreco30Dias só é informado para produtos em oferta.
+	MenorPreco30Dias *float64 `json:"menor_preco_30_dias,omitempty"`
+	// Oferta também fica verdadeiro enquanto houver promoção vigente.
+	Oferta bool `json:"oferta"`
+	// EstoqueMinimo dispara o alerta de reposição; 0 desativa.
+	EstoqueMinimo int               `json:"estoque_minimo"`
+	Detalhes      sql.NullString    `json:"details"`
+	Imagem        sql.NullString    `json:"image"`
+	Categorias    []CategoriaResumo `json:"categorias"`
+	Variantes     []Variante        `json:"variantes"`
+	Imagens       []ProdutoImagem   `json:"imagens"`
+	// Especificacoes só é preenchido no detalhe do produto.
+	Especificacoes []EspecificacaoProduto `json:"especificacoes,omitempty"`
+	CriadoEm       time.Time              `json:"criado_em"`
 }
 
 type ProdutoRequest struct {
-	Nome       string  `json:"name" binding:"required"`
-	Quantidade int     `json:"quantity" binding:"required,min=0"`
+	Nome string `json:"name" binding:"required"`
+	// SKU nulo/ausente mantém o código atual; "" remove.
+	SKU *string `json:"sku" binding:"omitempty,max=64"`
+	// Quantidade é o estoque inicial no cadastro. O PUT a ignora: o saldo só
+	// muda por POST /produtos/{id}/estoque.
+	Quantidade int     `json:"quantity" binding:"min=0"`
 	Preco      float64 `json:"value" binding:"required,min=0.01"`
 	Oferta     bool    `json:"oferta"`
 	Detalhes   string  `json:"details"`
 	Imagem     string  `json:"image"`
+	// CategoriaIDs nulo/ausente mantém as categorias atuais; [] remove todas.
+	CategoriaIDs []int `json:"categoria_ids"`
+	// EstoqueMinimo nulo/ausente mantém o limite atual.
+	EstoqueMinimo *int `json:"estoque_minimo" binding:"omitempty,min=0"`
+	// Especificacoes traz os valores por chave (ver GET
+	// /categorias/{id}/especificacoes). No PUT, nulo/ausente mantém os atuais.
+	Especificacoes map[string]interface{} `json:"especificacoes"`
+}
+
+type ListaProdutosResponse struct {
+	Produtos      []Produto        `json:"produtos"`
+	Total         int              `json:"total"`
+	Limite        int              `json:"limite"`
+	ProximoCursor *string          `json:"proximo_cursor"`
+	Facetas       *FacetasProdutos `json:"facetas,omitempty"`
+}
+
+type FacetasProdutos struct {
+	Preco           FaixaPreco               `json:"preco"`
+	Disponibilidade FacetaDisponibilidade    `json:"disponibilidade"`
+	Ofertas         int                      `json:"ofertas"`
+	Categorias      []FacetaCategoria        `json:"categorias"`
+	Atributos       map[string][]FacetaValor `json:"atributos"`
+}
+
+type FaixaPreco struct {
+	Minimo *float64 `json:"minimo"`
+	Maximo *float64 `json:"maximo"`
+}
+
+type FacetaDisponibilidade struct {
+	EmEstoque  int `json:"em_estoque"`
+	SemEstoque int `json:"sem_estoque"`
+}
+
+type FacetaCategoria struct {
+	ID    int    `json:"id"`
+	Nome  string `json:"nome"`
+	Slug  string `json:"slug"`
+	Total int    `json:"total"`
+}
+
+type FacetaValor struct {
+	Valor string `json:"valor"`
+	Total int    `json:"total"`
+}
+
+// PrecoHistorico registra o preço normal e o efetivo (com promoções) a partir
+// de CriadoEm, até o registro seguinte.
+type PrecoHistorico struct {
+	ID           int       `json:"id"`
+	Preco        float64   `json:"preco"`
+	PrecoEfetivo float64   `json:"preco_efetivo"`
+	Origem       string    `json:"origem"`
+	CriadoEm     time.Time `json:"criado_em"`
+}
+
+// ProdutoRelacionado é uma sugestão para a página do produto ou o carrinho.
+// Origem "comprados_juntos" vem dos pedidos; "mesma_categoria" completa a
+// lista quando não há pedidos suficientes.
+type ProdutoRelacionado struct {
+	ID              int      `json:"id"`
+	Nome            string   `json:"nome"`
+	Imagem          string   `json:"imagem,omitempty"`
+	Preco           float64  `json:"preco"`
+	PrecoEfetivo    float64  `json:"preco_efetivo"`
+	Oferta          bool     `json:"oferta"`
+	AvaliacaoMedia  *float64 `json:"avaliacao_media"`
+	TotalAvaliacoes int      `json:"total_avaliacoes"`
+	Origem          string   `json:"origem"`
+	PedidosEmComum  int      `json:"pedidos_em_comum,omitempty"`
 }
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Promocao reduz o preço dos alvos entre Inicio e Fim: Tipo "preco" define o
+// preço promocional e "percentual" aplica um desconto de Valor%.
+type Promocao struct {
+	ID           int            `json:"id"`
+	Nome         string         `json:"nome"`
+	Descricao    string         `json:"descricao,omitempty"`
+	Tipo         string         `json:"tipo"`
+	Valor        float64        `json:"valor"`
+	Inicio       time.Time      `json:"inicio"`
+	Fim          time.Time      `json:"fim"`
+	Ativo        bool           `json:"ativo"`
+	Situacao     string         `json:"situacao"`
+	Alvos        []AlvoPromocao `json:"alvos"`
+	IniciadaEm   *time.Time     `json:"iniciada_em"`
+	EncerradaEm  *time.Time     `json:"encerrada_em"`
+	CriadoEm     time.Time      `json:"criado_em"`
+	AtualizadoEm time.Time      `json:"atualizado_em"`
+}
+
+type AlvoPromocao struct {
+	Tipo string `json:"tipo" binding:"required,oneof=produto servico categoria"`
+	ID   int    `json:"id" binding:"required,min=1"`
+	Nome string `json:"nome,omitempty"`
+}
+
+type PromocaoRequest struct {
+	Nome      string         `json:"nome" binding:"required,max=150"`
+	Descricao string         `json:"descricao"`
+	Tipo      string         `json:"tipo" binding:"required,oneof=preco percentual"`
+	Valor     float64        `json:"valor" binding:"required,gt=0"`
+	Inicio    time.Time      `json:"inicio" binding:"required"`
+	Fim       time.Time      `json:"fim" binding:"required"`
+	Ativo     *bool          `json:"ativo"`
+	Alvos     []AlvoPromocao `json:"alvos" binding:"required,min=1,dive"`
+}
+
+// PromocaoAplicada resume, no catálogo, a promoção que definiu o preço efetivo.
+type PromocaoAplicada struct {
+	ID    int       `json:"id"`
+	Nome  string    `json:"nome"`
+	Tipo  string    `json:"tipo"`
+	Valor float64   `json:"valor"`
+	Fim   time.Time `json:"fim"`
+}
@@ -1,11 +1,14 @@
 package models
 
 type Servico struct {
-	ID       int     `json:"id"`
-	Nome     string  `json:"nome" binding:"required,min=3"`
-	Preco    float64 `json:"preco" binding:"required,min=0.01"`
-	Oferta   bool    `json:"oferta"`
-	Detalhes string  `json:"detalhes" binding:"required,min=10"`
+	ID           int               `json:"id"`
+	Nome         string            `json:"nome" binding:"required,min=3"`
+	Slug         string            `json:"slug"`
+	Preco        float64           `json:"preco" binding:"required,min=0.01"`
+	PrecoEfetivo float64           `json:"preco_efetivo"`
+	Promocao     *PromocaoAplicada `json:"promocao"`
+	Oferta       bool              `json:"oferta"`
+	Detalhes     string            `json:"detalhes" binding:"required,min=10"`
 }
 
 type ServicoRequest struct {
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Sessao struct {
+	ID                int       `json:"id"`
+	Dispositivo       string    `json:"dispositivo"`
+	UserAgent         string    `json:"user_agent"`
+	IP                string    `json:"ip"`
+	CriadoEm          time.Time `json:"criado_em"`
+	UltimaAtividadeEm time.Time `json:"ultima_atividade_em"`
+	ExpiraEm          time.Time `json:"expira_em"`
+	Atual             bool      `json:"atual"`
+}
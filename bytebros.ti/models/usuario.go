@@ -4,13 +4,13 @@ type Usuario struct {
 	ID       int    `json:"id"`
 	Nome     string `json:"nome_completo" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Senha    string `json:"senha" binding:"required,min=6"`
+	Senha    string `json:"senha" binding:"required"`
 	Telefone string `json:"telefone"`
 }
 
 type LoginRequest struct {
 	Email string `json:"email" binding:"required,email"`
-	Senha string `json:"senha" binding:"required,min=6"`
+	Senha string `json:"senha" binding:"required"`
 }
 
 type LoginResponse struct {
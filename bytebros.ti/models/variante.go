@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type Variante struct {
+	ID        int               `json:"id"`
+	ProdutoID int               `json:"produto_id"`
+	SKU       string            `json:"sku"`
+	Atributos map[string]string `json:"atributos"`
+	// Preco é o preço efetivo: o da variante ou, se ela não tiver preço próprio, o do produto.
+	Preco        float64  `json:"preco"`
+	PrecoProprio *float64 `json:"preco_proprio"`
+	// PrecoEfetivo aplica as promoções vigentes do produto; preço fixo
+	// promocional só vale para variantes sem preço próprio.
+	PrecoEfetivo float64   `json:"preco_efetivo"`
+	Quantidade   int       `json:"quantidade"`
+	Imagem       string    `json:"imagem,omitempty"`
+	Ativo        bool      `json:"ativo"`
+	Ordem        int       `json:"ordem"`
+	CriadoEm     time.Time `json:"criado_em"`
+	AtualizadoEm time.Time `json:"atualizado_em"`
+}
+
+type VarianteRequest struct {
+	SKU       string            `json:"sku" binding:"required,max=64"`
+	Atributos map[string]string `json:"atributos" binding:"required,min=1"`
+	Preco     *float64          `json:"preco" binding:"omitempty,min=0.01"`
+	// Quantidade é o estoque inicial na criação; na edição é ignorada (ver
+	// POST /produtos/{id}/estoque com variante_id).
+	Quantidade int    `json:"quantidade" binding:"min=0"`
+	Imagem     string `json:"imagem" binding:"max=255"`
+	Ativo      *bool  `json:"ativo"`
+	Ordem      int    `json:"ordem"`
+}